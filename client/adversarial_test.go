@@ -0,0 +1,281 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+// corruptingServer lets corrupt change the responses of server.
+type corruptingServer struct {
+	server  ocserver.OracleServer
+	corrupt func(req oracletypes.Request, res []byte) []byte
+}
+
+func (s corruptingServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	return s.corrupt(req, s.server.Get(key))
+}
+
+// corruptProofs applies corrupt to the proof ops of every abci_query response.
+func corruptProofs(corrupt func(res *ctypes.ResultABCIQuery)) func(oracletypes.Request, []byte) []byte {
+	return func(req oracletypes.Request, value []byte) []byte {
+		if req.Kind != oracletypes.KindABCIQuery {
+			return value
+		}
+		res := ctypes.ResultABCIQuery{}
+		if err := oracletypes.DecodeResponse(value, &res); err != nil {
+			panic(err)
+		}
+		if res.Response.ProofOps != nil {
+			corrupt(&res)
+		}
+		bz, err := oracletypes.EncodeResponse(res)
+		if err != nil {
+			panic(err)
+		}
+		return bz
+	}
+}
+
+// corruptValidators applies corrupt to the validators of the validators
+// response.
+func corruptValidators(corrupt func(vals []*types.Validator) []*types.Validator) func(oracletypes.Request, []byte) []byte {
+	return func(req oracletypes.Request, value []byte) []byte {
+		if req.Kind != oracletypes.KindValidators {
+			return value
+		}
+		res := ctypes.ResultValidators{}
+		if err := oracletypes.DecodeResponse(value, &res); err != nil {
+			panic(err)
+		}
+		res.Validators = corrupt(res.Validators)
+		res.Count, res.Total = len(res.Validators), len(res.Validators)
+		bz, err := oracletypes.EncodeResponse(res)
+		if err != nil {
+			panic(err)
+		}
+		return bz
+	}
+}
+
+// corruptIAVLProof applies corrupt to the IAVL proof of an abci_query response.
+func corruptIAVLProof(res *ctypes.ResultABCIQuery, corrupt func(proof *ics23.CommitmentProof)) {
+	op := &res.Response.ProofOps.Ops[0]
+	proof := &ics23.CommitmentProof{}
+	if err := proof.Unmarshal(op.Data); err != nil {
+		panic(err)
+	}
+	corrupt(proof)
+	bz, err := proof.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	op.Data = bz
+}
+
+func existenceProof(proof *ics23.CommitmentProof) *ics23.ExistenceProof {
+	if exist := proof.GetExist(); exist != nil {
+		return exist
+	}
+	if nonexist := proof.GetNonexist(); nonexist != nil && nonexist.Left != nil {
+		return nonexist.Left
+	}
+	if nonexist := proof.GetNonexist(); nonexist != nil {
+		return nonexist.Right
+	}
+	return nil
+}
+
+func TestExecuteStatelessWithMaliciousOracle(t *testing.T) {
+	const height = 10
+	chain, err := testapp.NewChain(11, 4)
+	require.NoError(t, err)
+	fork, err := testapp.NewChain(12, 4)
+	require.NoError(t, err)
+	for h := int64(1); h <= height; h++ {
+		// the last validator is absent from the last commit of the block
+		// at height, so that its signature does not bind it
+		opts := testapp.BlockOptions{Txs: 8}
+		if h == height-1 {
+			opts.Absent = 1
+		}
+		_, err = chain.NextBlock(opts)
+		require.NoError(t, err)
+		_, err = fork.NextBlock(testapp.BlockOptions{Txs: 8})
+		require.NoError(t, err)
+	}
+	cp := chain.ConsensusParams()
+	local := ocserver.NewLocalOracleServer(chain.App, chain.Block(height), chain.Validators(height-1).Validators, &cp)
+	local.AddBlock(chain.Block(height-1), chain.Validators(height-2).Validators, &cp)
+
+	execute := func(corrupt func(oracletypes.Request, []byte) []byte) (appHash []byte, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+				if e, ok := r.(error); ok {
+					err = e
+				}
+			}
+		}()
+		server := corruptingServer{server: local, corrupt: corrupt}
+		client := occlient.NewVerifyingOracleClient(server, height, chain.BlockID(height).Hash)
+		stateless := newStatelessClient(t, client)
+		appHash, _, err = stateless.Execute(client.Block().Block, client.Validators().Validators)
+		return appHash, err
+	}
+
+	appHash, err := execute(func(_ oracletypes.Request, res []byte) []byte { return res })
+	require.NoError(t, err)
+	require.Equal(t, chain.AppHash(), appHash)
+
+	// nodeResponses answers every node query of a store with the response to
+	// its first one, a valid proof of another node
+	nodeResponses := map[string][]byte{}
+
+	for _, tc := range []struct {
+		name    string
+		corrupt func(oracletypes.Request, []byte) []byte
+	}{
+		{"modified node value", corruptProofs(func(res *ctypes.ResultABCIQuery) {
+			corruptIAVLProof(res, func(proof *ics23.CommitmentProof) {
+				if ep := existenceProof(proof); ep != nil && len(ep.Value) > 0 {
+					ep.Value[0] ^= 0xff
+				}
+			})
+			if len(res.Response.Value) > 0 {
+				res.Response.Value[0] ^= 0xff
+			}
+		})},
+		{"dropped proof op", corruptProofs(func(res *ctypes.ResultABCIQuery) {
+			ops := res.Response.ProofOps.Ops
+			res.Response.ProofOps.Ops = ops[:len(ops)-1]
+		})},
+		{"swapped subtree", corruptProofs(func(res *ctypes.ResultABCIQuery) {
+			corruptIAVLProof(res, func(proof *ics23.CommitmentProof) {
+				ep := existenceProof(proof)
+				if ep == nil || len(ep.Path) == 0 {
+					return
+				}
+				// replace the sibling hash of the leaf by another subtree
+				inner := ep.Path[0]
+				if len(inner.Suffix) >= 32 {
+					copy(inner.Suffix[len(inner.Suffix)-32:], fork.AppHash())
+				} else {
+					copy(inner.Prefix[len(inner.Prefix)-32:], fork.AppHash())
+				}
+			})
+		})},
+		{"replaced absent validator", corruptValidators(func(vals []*types.Validator) []*types.Validator {
+			vals[len(vals)-1] = fork.Validators(height - 1).Validators[0]
+			return vals
+		})},
+		{"changed voting power", corruptValidators(func(vals []*types.Validator) []*types.Validator {
+			vals[0].VotingPower++
+			return vals
+		})},
+		{"extra validator", corruptValidators(func(vals []*types.Validator) []*types.Validator {
+			return append(vals, fork.Validators(height - 1).Validators[0])
+		})},
+		{"fork last header", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindBlock || req.Height != height-1 {
+				return value
+			}
+			bz, err := oracletypes.EncodeResponse(ctypes.ResultBlock{BlockID: fork.BlockID(height - 1), Block: fork.Block(height - 1)})
+			if err != nil {
+				panic(err)
+			}
+			return bz
+		}},
+		{"proofless error response", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindABCIQuery {
+				return value
+			}
+			bz, err := oracletypes.EncodeResponse(ctypes.ResultABCIQuery{Response: abci.ResponseQuery{Code: 1, Log: "not found"}})
+			if err != nil {
+				panic(err)
+			}
+			return bz
+		}},
+		{"partial proof of another node", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindABCIQuery || !strings.HasSuffix(req.Queries[0].Path, "/node") {
+				return value
+			}
+			// the proof ends at the leaf instead of the requested node
+			return corruptProofs(func(res *ctypes.ResultABCIQuery) {
+				corruptIAVLProof(res, func(proof *ics23.CommitmentProof) {
+					if exist := proof.GetExist(); exist != nil {
+						exist.Path = nil
+					}
+				})
+				res.Response.ProofOps.Ops = res.Response.ProofOps.Ops[:1]
+			})(req, value)
+		}},
+		{"store proof of another store", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindABCIQuery || req.Queries[0].Path != "store/key1/key" {
+				return value
+			}
+			other := oracletypes.NewABCIQueryRequest(height, "store/key2/key", req.Queries[0].Data)
+			res := ctypes.ResultABCIQuery{}
+			if err := oracletypes.DecodeResponse(local.Get(oracletypes.MustEncodeRequest(other)), &res); err != nil {
+				panic(err)
+			}
+			ops := res.Response.ProofOps.Ops
+			res.Response.ProofOps.Ops = ops[len(ops)-1:]
+			bz, err := oracletypes.EncodeResponse(res)
+			if err != nil {
+				panic(err)
+			}
+			return bz
+		}},
+		{"fork block", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindBlock {
+				return value
+			}
+			bz, err := oracletypes.EncodeResponse(ctypes.ResultBlock{BlockID: fork.BlockID(height), Block: fork.Block(height)})
+			if err != nil {
+				panic(err)
+			}
+			return bz
+		}},
+		{"proof of another node", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindABCIQuery || !strings.HasSuffix(req.Queries[0].Path, "/node") {
+				return value
+			}
+			if first, ok := nodeResponses[req.Queries[0].Path]; ok {
+				return first
+			}
+			nodeResponses[req.Queries[0].Path] = value
+			return value
+		}},
+		{"stale height", func(req oracletypes.Request, value []byte) []byte {
+			if req.Kind != oracletypes.KindABCIQuery {
+				return value
+			}
+			req.Height--
+			return local.Get(oracletypes.MustEncodeRequest(req))
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			appHash, err := execute(tc.corrupt)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, oracletypes.ErrVerificationFailed), err.Error())
+			require.Nil(t, appHash)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+)
+
+func TestGetBatchMatchesGet(t *testing.T) {
+	chain := newTestChain(t, dbm.NewMemDB(), 16, 8, 0)
+	oracle := occlient.NewLocalOracleClient(chain.server(chain.height()))
+	speculative := occlient.NewLocalOracleClientAtHeight(chain.server(chain.height()-1), chain.height()-1)
+
+	// collect the queries of the last block
+	stateless := newStatelessClient(t, oracle)
+	dryKeys, err := stateless.DryRun(chain.block(chain.height()), nil, speculative)
+	require.NoError(t, err)
+	keys := [][]byte{}
+	for _, key := range dryKeys {
+		if bytes.HasPrefix(key, []byte("abci_query?")) {
+			keys = append(keys, key)
+		}
+	}
+	require.Greater(t, len(keys), 1)
+
+	values, err := oracle.GetBatch(keys)
+	require.NoError(t, err)
+	require.Len(t, values, len(keys))
+	for i, key := range keys {
+		require.JSONEq(t, string(oracle.Get(key)), string(values[i]), "key %s", key)
+	}
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/iavl"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+var benchOut = flag.String("bench.out", "", "write the results of the benchmarks as JSON to this file")
+
+// benchResult is a result of a benchmark written by -bench.out.
+type benchResult struct {
+	Name      string `json:"name"`
+	Mode      string `json:"mode"`
+	StateKeys int    `json:"state_keys"`
+	Txs       int    `json:"txs"`
+	N         int    `json:"n"`
+	NsPerOp   int64  `json:"ns_per_op"`
+	// the oracle metrics are only set for stateless modes
+	OracleRequests    uint64  `json:"oracle_requests,omitempty"`
+	WitnessBytes      uint64  `json:"witness_bytes,omitempty"`
+	WitnessBytesPerTx float64 `json:"witness_bytes_per_tx,omitempty"`
+	// the hit rate is only set for prefetching modes
+	PrefetchHitRate float64 `json:"prefetch_hit_rate,omitempty"`
+}
+
+var (
+	benchResultsMtx sync.Mutex
+	benchResults    = map[string]benchResult{}
+)
+
+// recordBenchResult keeps the result of the last run of a benchmark, which
+// is the one go test reports, and rewrites -bench.out.
+func recordBenchResult(b *testing.B, res benchResult) {
+	if *benchOut == "" {
+		return
+	}
+	benchResultsMtx.Lock()
+	defer benchResultsMtx.Unlock()
+	res.Name = b.Name()
+	benchResults[res.Name] = res
+
+	results := []benchResult{}
+	for _, res := range benchResults {
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	bz, err := json.MarshalIndent(results, "", "  ")
+	require.NoError(b, err)
+	require.NoError(b, os.WriteFile(*benchOut, bz, 0o644))
+}
+
+// benchKey returns the key i of the state of a benchmark.
+func benchKey(i int) []byte {
+	return []byte(fmt.Sprintf("key%08d", i))
+}
+
+// benchTxs returns numTxs txs that each overwrite a random key and read
+// another of stateKeys keys.
+func benchTxs(b *testing.B, stateKeys, numTxs int) types.Txs {
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	r := rand.New(rand.NewSource(int64(stateKeys + numTxs)))
+	txs := types.Txs{}
+	for i := 0; i < numTxs; i++ {
+		tx, err := testapp.EncodeTx(txConfig,
+			&testapp.MsgSet{Key: benchKey(r.Intn(stateKeys)), Value: []byte(fmt.Sprint(i))},
+			&testapp.MsgGet{Key: benchKey(r.Intn(stateKeys))},
+		)
+		require.NoError(b, err)
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// benchState returns txs setting stateKeys keys.
+func benchState(b *testing.B, stateKeys int) types.Txs {
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	txs := types.Txs{}
+	for i := 0; i < stateKeys; i += 100 {
+		msgs := []sdk.Msg{}
+		for j := i; j < i+100 && j < stateKeys; j++ {
+			msgs = append(msgs, &testapp.MsgSet{Key: benchKey(j), Value: benchKey(j)})
+		}
+		tx, err := testapp.EncodeTx(txConfig, msgs...)
+		require.NoError(b, err)
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// BenchmarkExecute measures the execution of a block of txs over a state of
+// keys in the modes:
+//
+//	full:               execution and commit on a stateful app
+//	stateless:          execution with LocalOracleServer
+//	stateless_verified: execution with every oracle response verified
+//
+// The difference of the stateless modes is the cost of proof verification.
+// The results are also written as JSON with -bench.out:
+//
+//	go test ./client -run '^$' -bench 'BenchmarkExecute$' -bench.out bench.json
+func BenchmarkExecute(b *testing.B) {
+	for _, stateKeys := range []int{1_000, 10_000, 100_000} {
+		for _, numTxs := range []int{1, 10, 100} {
+			b.Run(fmt.Sprintf("state=%d/txs=%d", stateKeys, numTxs), func(b *testing.B) {
+				benchExecute(b, stateKeys, numTxs)
+			})
+		}
+	}
+}
+
+func benchExecute(b *testing.B, stateKeys, numTxs int) {
+	state, txs := benchState(b, stateKeys), benchTxs(b, stateKeys, numTxs)
+	result := func(b *testing.B, mode string, start time.Time) benchResult {
+		return benchResult{
+			Mode:      mode,
+			StateKeys: stateKeys,
+			Txs:       numTxs,
+			N:         b.N,
+			NsPerOp:   time.Since(start).Nanoseconds() / int64(b.N),
+		}
+	}
+
+	b.Run("full", func(b *testing.B) {
+		app, err := testapp.NewTestApp()
+		require.NoError(b, err)
+		app.InitChain(abci.RequestInitChain{})
+		_, err = testapp.ExecuteBlock(app, state, 1)
+		require.NoError(b, err)
+		app.Commit()
+
+		b.ResetTimer()
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			if _, err = testapp.ExecuteBlock(app, txs, int64(i)+2); err != nil {
+				b.Fatal(err)
+			}
+			app.Commit()
+		}
+		recordBenchResult(b, result(b, "full", start))
+	})
+
+	chain, err := testapp.NewChain(1, 4)
+	require.NoError(b, err)
+	for _, opts := range []testapp.BlockOptions{{Include: state}, {}, {Include: txs}} {
+		_, err = chain.NextBlock(opts)
+		require.NoError(b, err)
+	}
+	const height = 3
+	cp := chain.ConsensusParams()
+	local := ocserver.NewLocalOracleServer(chain.App, chain.Block(height), chain.Validators(height-1).Validators, &cp)
+	local.AddBlock(chain.Block(height-1), chain.Validators(height-2).Validators, &cp)
+	server := &recordingServer{
+		server:    local,
+		responses: map[string][]byte{},
+	}
+
+	for _, mode := range []string{"stateless", "stateless_verified"} {
+		b.Run(mode, func(b *testing.B) {
+			newClient := func() statelessOracle {
+				if mode == "stateless_verified" {
+					return occlient.NewVerifyingOracleClient(server, height, chain.BlockID(height).Hash)
+				}
+				return occlient.NewLocalOracleClientAtHeight(server, height)
+			}
+			// the first execution records the responses and their proofs
+			newapp, err := testapp.NewTestApp()
+			require.NoError(b, err)
+			appHash, _, err := benchExecuteStateless(newapp, newClient())
+			require.NoError(b, err)
+			require.Equal(b, chain.AppHash(), appHash)
+
+			var stats OracleStats
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				_, stats, err = benchExecuteStateless(newapp, newClient())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			res := result(b, mode, start)
+			res.OracleRequests = stats.Requests
+			res.WitnessBytes = stats.Bytes
+			res.WitnessBytesPerTx = float64(stats.Bytes) / float64(numTxs)
+			b.ReportMetric(float64(res.OracleRequests), "oracle_requests/op")
+			b.ReportMetric(float64(res.WitnessBytes), "witness_bytes/op")
+			b.ReportMetric(res.WitnessBytesPerTx, "witness_bytes/tx")
+			recordBenchResult(b, res)
+		})
+	}
+}
+
+// benchLatency is the round trip time of the oracle in
+// BenchmarkExecuteWithPrefetch.
+const benchLatency = time.Millisecond
+
+// BenchmarkExecuteWithPrefetch measures the latency of executing a block of
+// txs over a state of keys with an oracle that takes benchLatency per request,
+// like an RPC node, in the modes:
+//
+//	sequential: execution requesting each key when it is needed
+//	prefetch:   ExecuteWithPrefetch with a dry run against the data of the
+//	            previous height and 16 workers fetching batches of 8 keys
+//
+// The results are also written as JSON with -bench.out:
+//
+//	go test ./client -run '^$' -bench BenchmarkExecuteWithPrefetch -bench.out bench.json
+func BenchmarkExecuteWithPrefetch(b *testing.B) {
+	for _, stateKeys := range []int{1_000, 10_000} {
+		for _, numTxs := range []int{10, 100} {
+			b.Run(fmt.Sprintf("state=%d/txs=%d", stateKeys, numTxs), func(b *testing.B) {
+				benchExecuteWithPrefetch(b, stateKeys, numTxs)
+			})
+		}
+	}
+}
+
+func benchExecuteWithPrefetch(b *testing.B, stateKeys, numTxs int) {
+	// the previous block also changes the state, so that the speculative data
+	// is stale like the one of a real chain
+	chain, err := testapp.NewChain(1, 4)
+	require.NoError(b, err)
+	for _, opts := range []testapp.BlockOptions{{Include: benchState(b, stateKeys)}, {}, {Include: benchTxs(b, stateKeys, numTxs)}, {Include: benchTxs(b, stateKeys+1, numTxs)}} {
+		_, err = chain.NextBlock(opts)
+		require.NoError(b, err)
+	}
+	const height = 4
+	cp := chain.ConsensusParams()
+	server := &recordingServer{
+		server:    ocserver.NewLocalOracleServer(chain.App, chain.Block(height), chain.Validators(height-1).Validators, &cp),
+		responses: map[string][]byte{},
+	}
+	speculative := occlient.NewLocalOracleClient(&recordingServer{
+		server:    ocserver.NewLocalOracleServer(chain.App, chain.Block(height-1), chain.Validators(height-2).Validators, &cp),
+		responses: map[string][]byte{},
+	})
+	block, vals := chain.Block(height), chain.Validators(height-1).Validators
+
+	for _, mode := range []string{"sequential", "prefetch"} {
+		b.Run(mode, func(b *testing.B) {
+			newapp, err := testapp.NewTestApp()
+			require.NoError(b, err)
+			execute := func() (*StatelessClient, error) {
+				prefetch := ocserver.NewPrefetchOracleServer(&latencyServer{server: server, latency: benchLatency})
+				prefetch.SetBatchSize(8)
+				client := occlient.NewLocalOracleClientAtHeight(prefetch, height)
+				stateless, err := NewStatelessClient(newapp, client)
+				if err != nil {
+					return nil, err
+				}
+				var appHash []byte
+				if mode == "prefetch" {
+					appHash, _, err = stateless.ExecuteWithPrefetch(block, vals, speculative, client, 16)
+				} else {
+					appHash, _, err = stateless.Execute(block, vals)
+				}
+				if err == nil && !bytes.Equal(chain.AppHash(), appHash) {
+					err = fmt.Errorf("app hash %X, expected %X", appHash, chain.AppHash())
+				}
+				return stateless, err
+			}
+			// the first execution records the responses and their proofs
+			_, err = execute()
+			require.NoError(b, err)
+
+			var stateless *StatelessClient
+			b.ResetTimer()
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if stateless, err = execute(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			res := benchResult{
+				Mode:           mode,
+				StateKeys:      stateKeys,
+				Txs:            numTxs,
+				N:              b.N,
+				NsPerOp:        time.Since(start).Nanoseconds() / int64(b.N),
+				OracleRequests: stateless.OracleStats().Requests,
+			}
+			b.ReportMetric(float64(res.OracleRequests), "oracle_requests/op")
+			if mode == "prefetch" {
+				res.PrefetchHitRate = stateless.PrefetchStats().HitRate()
+				b.ReportMetric(res.PrefetchHitRate, "hit_rate")
+			}
+			recordBenchResult(b, res)
+		})
+	}
+}
+
+// latencyServer delays every request to server by latency.
+type latencyServer struct {
+	server  ocserver.OracleServer
+	latency time.Duration
+}
+
+func (s *latencyServer) Get(key []byte) []byte {
+	time.Sleep(s.latency)
+	return s.server.Get(key)
+}
+
+// recordingServer serves the responses of server recorded by earlier
+// requests, so that benchmarks do not measure the proof generation of
+// LocalOracleServer.
+type recordingServer struct {
+	server ocserver.OracleServer
+
+	mtx       sync.Mutex
+	responses map[string][]byte
+}
+
+func (s *recordingServer) Get(key []byte) []byte {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if res, ok := s.responses[string(key)]; ok {
+		return res
+	}
+	res := s.server.Get(key)
+	s.responses[string(key)] = res
+	return res
+}
+
+type statelessOracle interface {
+	iavl.OracleClientI
+	Block() *ctypes.ResultBlock
+	Validators() *ctypes.ResultValidators
+}
+
+func benchExecuteStateless(app *baseapp.BaseApp, oracle statelessOracle) ([]byte, OracleStats, error) {
+	stateless, err := NewStatelessClient(app, oracle)
+	if err != nil {
+		return nil, OracleStats{}, err
+	}
+	appHash, _, err := stateless.Execute(oracle.Block().Block, oracle.Validators().Validators)
+	if err != nil {
+		return nil, OracleStats{}, err
+	}
+	return appHash, stateless.OracleStats(), nil
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+func TestExecuteBundle(t *testing.T) {
+	chain, err := testapp.NewChain(3, 4)
+	require.NoError(t, err)
+	for h := int64(1); h <= 4; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 16})
+		require.NoError(t, err)
+	}
+	const height = 3
+	cp := chain.ConsensusParams()
+	server := ocserver.NewLocalOracleServer(chain.App, chain.Block(height), chain.Validators(height-1).Validators, &cp)
+	server.AddBlock(chain.Block(height-1), chain.Validators(height-2).Validators, &cp)
+	client := occlient.NewLocalOracleClientAtHeight(server, height)
+
+	rec := &witness.Recording{}
+	stateless := newStatelessClient(t, client, WithWitnessRecording(rec))
+	appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chain.Block(height+1).AppHash), appHash)
+
+	bundle, err := witness.NewBundle(client, rec, appHash)
+	require.NoError(t, err)
+	for _, c := range []witness.Compression{witness.CompressionNone, witness.CompressionZstd} {
+		bz, err := witness.EncodeBundle(bundle, c)
+		require.NoError(t, err)
+		require.Less(t, len(bz), int(stateless.OracleStats().Bytes))
+		decoded, err := witness.DecodeBundle(bz)
+		require.NoError(t, err)
+		require.Equal(t, bundle.String(), decoded.String())
+	}
+	_, err = witness.DecodeBundle([]byte("{}"))
+	require.ErrorIs(t, err, witness.ErrInvalidBundle)
+
+	execute := func(bundle *witness.Bundle) (appHash []byte, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		server, err := ocserver.NewBundleOracleServer(bundle)
+		if err != nil {
+			return nil, err
+		}
+		client := occlient.NewVerifyingOracleClient(server, height, chain.BlockID(height).Hash)
+		stateless := newStatelessClient(t, client)
+		appHash, _, err = stateless.Execute(client.Block().Block, client.Validators().Validators)
+		return appHash, err
+	}
+	executedAppHash, err := execute(bundle)
+	require.NoError(t, err)
+	require.Equal(t, bundle.AppHash, executedAppHash)
+
+	// the consensus params are served for the height of the block and the
+	// validators for the height of its last commit like the RPC oracle
+	// server does
+	bundleServer, err := ocserver.NewBundleOracleServer(bundle)
+	require.NoError(t, err)
+	require.Equal(t, int64(height), occlient.NewLocalOracleClient(bundleServer).ConsensusParams().BlockHeight)
+	require.Equal(t, int64(height-1), occlient.NewLocalOracleClient(bundleServer).Validators().BlockHeight)
+
+	// the validators are checked against the header of the block below
+	bundle.LastHeader.ValidatorsHash[0] ^= 0xff
+	_, err = execute(bundle)
+	require.True(t, errors.Is(err, oracletypes.ErrVerificationFailed), err)
+	bundle.LastHeader.ValidatorsHash[0] ^= 0xff
+
+	// a changed leaf is no longer a child of its parent
+	value := bundle.Leaves[0].Value
+	bundle.Leaves[0].Value = append(value, 1)
+	_, err = execute(bundle)
+	require.ErrorIs(t, err, witness.ErrInvalidBundle)
+	bundle.Leaves[0].Value = value
+
+	// a changed response value is not proven by its proof
+	for i, q := range bundle.Queries {
+		if q.Path == "store/key2/node" {
+			res, err := bundle.QueryResponse(i)
+			require.NoError(t, err)
+			bundle.Queries[i].LeafValue = false
+			bundle.Queries[i].Key, bundle.Queries[i].Value = res.Key, append(res.Value, 1)
+			break
+		}
+	}
+	_, err = execute(bundle)
+	require.True(t, errors.Is(err, oracletypes.ErrVerificationFailed), err)
+}
+
+func TestMergeBundles(t *testing.T) {
+	chain, err := testapp.NewChain(5, 4)
+	require.NoError(t, err)
+	const from, to = 3, 5
+	for h := int64(1); h <= to+1; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 8})
+		require.NoError(t, err)
+	}
+	cp := chain.ConsensusParams()
+
+	bundles := []*witness.Bundle{}
+	size := 0
+	for h := int64(from); h <= to; h++ {
+		server := ocserver.NewLocalOracleServer(chain.App, chain.Block(h), chain.Validators(h-1).Validators, &cp)
+		server.AddBlock(chain.Block(h-1), chain.Validators(h-2).Validators, &cp)
+		client := occlient.NewLocalOracleClientAtHeight(server, h)
+		rec := &witness.Recording{}
+		stateless := newStatelessClient(t, client, WithWitnessRecording(rec))
+		appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+		require.NoError(t, err)
+		bundle, err := witness.NewBundle(client, rec, appHash)
+		require.NoError(t, err)
+		bz, err := witness.EncodeBundle(bundle, witness.CompressionNone)
+		require.NoError(t, err)
+		size += len(bz)
+		bundles = append(bundles, bundle)
+	}
+
+	_, err = witness.MergeBundles(bundles[0], bundles[2])
+	require.Error(t, err)
+	merged, err := witness.MergeBundles(bundles...)
+	require.NoError(t, err)
+	bz, err := witness.EncodeMultiBundle(merged, witness.CompressionNone)
+	require.NoError(t, err)
+	// the nodes shared by the heights are stored once
+	require.Less(t, len(bz), size)
+	decoded, err := witness.DecodeMultiBundle(bz)
+	require.NoError(t, err)
+	require.Equal(t, merged.String(), decoded.String())
+	_, err = witness.DecodeBundle(bz)
+	require.ErrorIs(t, err, witness.ErrInvalidBundle)
+
+	// a bundle of a single block decodes as a multi-block bundle
+	bz, err = witness.EncodeBundle(bundles[0], witness.CompressionZstd)
+	require.NoError(t, err)
+	single, err := witness.DecodeMultiBundle(bz)
+	require.NoError(t, err)
+	require.Len(t, single.Bundles, 1)
+	require.Equal(t, bundles[0].String(), single.Bundle(0).String())
+
+	for i := range decoded.Bundles {
+		bundle := decoded.Bundle(i)
+		server, err := ocserver.NewBundleOracleServer(bundle)
+		require.NoError(t, err)
+		client := occlient.NewVerifyingOracleClient(server, bundle.Height, chain.BlockID(bundle.Height).Hash)
+		stateless := newStatelessClient(t, client)
+		appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+		require.NoError(t, err)
+		require.Equal(t, []byte(chain.Block(bundle.Height+1).AppHash), appHash)
+	}
+}
+
+// TestMergeOverlappingBundles checks that the nodes and values of blocks that
+// change little are stored once, so that merging grows with the changes
+// rather than with the number of blocks.
+func TestMergeOverlappingBundles(t *testing.T) {
+	chain, err := testapp.NewChain(9, 4)
+	require.NoError(t, err)
+	const from, to = 3, 10
+	for h := int64(1); h <= to+1; h++ {
+		txs := 1
+		if h < from {
+			txs = 32
+		}
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: txs})
+		require.NoError(t, err)
+	}
+	cp := chain.ConsensusParams()
+
+	bundles := []*witness.Bundle{}
+	single := 0
+	for h := int64(from); h <= to; h++ {
+		server := ocserver.NewLocalOracleServer(chain.App, chain.Block(h), chain.Validators(h-1).Validators, &cp)
+		server.AddBlock(chain.Block(h-1), chain.Validators(h-2).Validators, &cp)
+		client := occlient.NewLocalOracleClientAtHeight(server, h)
+		rec := &witness.Recording{}
+		stateless := newStatelessClient(t, client, WithWitnessRecording(rec))
+		appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+		require.NoError(t, err)
+		bundle, err := witness.NewBundle(client, rec, appHash)
+		require.NoError(t, err)
+		bz, err := witness.EncodeBundle(bundle, witness.CompressionNone)
+		require.NoError(t, err)
+		if single == 0 || len(bz) < single {
+			single = len(bz)
+		}
+		bundles = append(bundles, bundle)
+	}
+	merged, err := witness.MergeBundles(bundles...)
+	require.NoError(t, err)
+	bz, err := witness.EncodeMultiBundle(merged, witness.CompressionNone)
+	require.NoError(t, err)
+	require.Less(t, len(bz), len(bundles)*single)
+}
+
+func TestPruneBundleQueries(t *testing.T) {
+	chain, err := testapp.NewChain(7, 4)
+	require.NoError(t, err)
+	const height = 3
+	for h := int64(1); h <= height+1; h++ {
+		// a small block over a larger state visits few of its nodes
+		txs := 2
+		if h < height {
+			txs = 32
+		}
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: txs})
+		require.NoError(t, err)
+	}
+	cp := chain.ConsensusParams()
+	server := ocserver.NewLocalOracleServer(chain.App, chain.Block(height), chain.Validators(height-1).Validators, &cp)
+	server.AddBlock(chain.Block(height-1), chain.Validators(height-2).Validators, &cp)
+	client := occlient.NewLocalOracleClientAtHeight(server, height)
+
+	rec := &witness.Recording{}
+	stateless := newStatelessClient(t, client, WithWitnessRecording(rec))
+	appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+	require.NoError(t, err)
+	recorded, err := witness.NewBundle(client, rec, appHash)
+	require.NoError(t, err)
+
+	// queries the execution does not need, e.g. of a mispredicted prefetch
+	for _, q := range []oracletypes.Query{
+		{Path: "store/key2/key", Data: []byte("unused")},
+		{Path: "store/key1/keys", Data: []byte("unused")},
+	} {
+		req := oracletypes.MustEncodeRequest(oracletypes.NewABCIQueryRequest(height, q.Path, q.Data))
+		rec.Add(witness.Access{Request: string(req), Response: server.Get(req)})
+	}
+	bundle, err := witness.NewBundle(client, rec, appHash)
+	require.NoError(t, err)
+
+	execute := func(bundle *witness.Bundle) (appHash []byte, server *ocserver.BundleOracleServer, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		server, err = ocserver.NewBundleOracleServer(bundle)
+		require.NoError(t, err)
+		client := occlient.NewVerifyingOracleClient(server, height, chain.BlockID(height).Hash)
+		stateless := newStatelessClient(t, client)
+		appHash, _, err = stateless.Execute(client.Block().Block, client.Validators().Validators)
+		return appHash, server, err
+	}
+	// a bundle recorded by a single execution needs all of its queries, but
+	// not all nodes of their proofs
+	_, recordedServer, err := execute(recorded)
+	require.NoError(t, err)
+	prunedRecorded, err := witness.PruneQueries(recorded, recordedServer.UsedQueries())
+	require.NoError(t, err)
+	require.Len(t, prunedRecorded.Queries, len(recorded.Queries))
+	require.Less(t, len(prunedRecorded.Leaves)+len(prunedRecorded.InnerNodes), len(recorded.Leaves)+len(recorded.InnerNodes))
+	recordedBz, err := witness.EncodeBundle(recorded, witness.CompressionNone)
+	require.NoError(t, err)
+	prunedRecordedBz, err := witness.EncodeBundle(prunedRecorded, witness.CompressionNone)
+	require.NoError(t, err)
+	require.Less(t, len(prunedRecordedBz), len(recordedBz))
+	prunedAppHash, _, err := execute(prunedRecorded)
+	require.NoError(t, err)
+	require.Equal(t, appHash, prunedAppHash)
+	// pruning a pruned bundle changes nothing
+	again, err := witness.PruneQueries(prunedRecorded, recordedServer.UsedQueries())
+	require.NoError(t, err)
+	require.Equal(t, prunedRecorded.String(), again.String())
+
+	replayedAppHash, bundleServer, err := execute(bundle)
+	require.NoError(t, err)
+	require.Equal(t, appHash, replayedAppHash)
+	used := bundleServer.UsedQueries()
+	require.Len(t, used, len(bundle.Queries)-2)
+
+	pruned, err := witness.PruneQueries(bundle, used)
+	require.NoError(t, err)
+	require.Len(t, pruned.Queries, len(used))
+	bz, err := witness.EncodeBundle(bundle, witness.CompressionNone)
+	require.NoError(t, err)
+	prunedBz, err := witness.EncodeBundle(pruned, witness.CompressionNone)
+	require.NoError(t, err)
+	require.Less(t, len(prunedBz), len(bz))
+
+	// the pruned bundle still reproduces the app hash
+	prunedAppHash, _, err = execute(pruned)
+	require.NoError(t, err)
+	require.Equal(t, appHash, prunedAppHash)
+
+	// and every query left is needed
+	for key := range used {
+		delete(used, key)
+		break
+	}
+	pruned, err = witness.PruneQueries(bundle, used)
+	require.NoError(t, err)
+	_, _, err = execute(pruned)
+	require.Error(t, err)
+}
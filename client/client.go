@@ -1,19 +1,54 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/iavl"
 	abci "github.com/tendermint/tendermint/abci/types"
+	tmlog "github.com/tendermint/tendermint/libs/log"
 	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
 )
 
+// ErrConcurrentExecution is returned by Execute while the client executes
+// another block.
+var ErrConcurrentExecution = errors.New("stateless client is already executing a block")
+
+// StatelessClient executes blocks statelessly on an app. It executes one
+// block at a time, as the app, the recording and the statistics of an
+// execution are shared by all executions of the client.
 type StatelessClient struct {
 	app    interface{}
 	oracle iavl.OracleClientI
+	hooks  []Hook
+
+	// executing is set while Execute runs
+	executing int32
+	mtx       sync.Mutex
+	// stats are the statistics of the last execution
+	stats *statsOracle
+	// prefetch are the statistics of the last ExecuteWithPrefetch
+	prefetch PrefetchStats
+
+	logger            tmlog.Logger
+	ctx               context.Context
+	maxOracleRequests uint64
+	maxWitnessBytes   uint64
+	initialHeight     int64
+	upgradeHeight     int64
+	storeUpgrades     *storetypes.StoreUpgrades
+	recording         *witness.Recording
 }
 
-func NewStatelessClient(app interface{}, oracle iavl.OracleClientI) (*StatelessClient, error) {
+func NewStatelessClient(app interface{}, oracle iavl.OracleClientI, opts ...Option) (*StatelessClient, error) {
 	switch app.(type) {
 	case CosmosBaseApp:
 		break
@@ -21,75 +56,188 @@ func NewStatelessClient(app interface{}, oracle iavl.OracleClientI) (*StatelessC
 		return nil, fmt.Errorf("this application type is not supported")
 	}
 
-	return &StatelessClient{
+	c := &StatelessClient{
 		app:    app,
 		oracle: oracle,
-	}, nil
+		logger: tmlog.NewNopLogger(),
+		ctx:    context.Background(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
 // TODO: cannnot execute initial height block
-func (c *StatelessClient) Execute(block *types.Block, vals []*types.Validator) ([]byte, ExecutionLog, error) {
-	log := ExecutionLog{}
+func (c *StatelessClient) Execute(block *types.Block, vals []*types.Validator) (appHash []byte, log ExecutionLog, err error) {
+	return c.execute(block, vals, nil)
+}
+
+// execute executes block, counting the oracle requests for keys in
+// prefetched as prefetch hits.
+func (c *StatelessClient) execute(block *types.Block, vals []*types.Validator, prefetched map[string]struct{}) (appHash []byte, log ExecutionLog, err error) {
 	cosmos := getCosmosApp(c.app)
 	if cosmos == nil {
 		return nil, log, fmt.Errorf("this application type is not supported")
 	}
+	if !atomic.CompareAndSwapInt32(&c.executing, 0, 1) {
+		return nil, log, ErrConcurrentExecution
+	}
+	defer atomic.StoreInt32(&c.executing, 0)
+
+	stats := &statsOracle{
+		oracle:      c.oracle,
+		ctx:         c.ctx,
+		maxRequests: c.maxOracleRequests,
+		maxBytes:    c.maxWitnessBytes,
+		recording:   c.recording,
+		prefetched:  prefetched,
+	}
+	c.mtx.Lock()
+	c.stats = stats
+	c.mtx.Unlock()
+	if c.recording != nil {
+		c.recording.Reset(block.Height)
+	}
+	// oracle requests abort by panicking, which baseapp may recover, so the
+	// error is also kept by stats and checked after every phase
+	defer func() {
+		if r := recover(); r != nil {
+			abort, ok := r.(abortError)
+			if !ok {
+				panic(r)
+			}
+			appHash, err = nil, abort.err
+		}
+		if err != nil {
+			c.logger.Error("stateless execution aborted", "height", block.Height, "err", err)
+		}
+	}()
+	c.logger.Info("executing block", "height", block.Height, "txs", len(block.Data.Txs))
 
 	// convert to stateless app
-	var stateless CosmosBaseApp
-	stateless, err := cosmos.StatelessApp(block.Height, c.oracle)
+	start := time.Now()
+	var oracle iavl.OracleClientI = stats
+	upgrade := c.storeUpgrades != nil && block.Height == c.upgradeHeight
+	if upgrade {
+		oracle = newUpgradeOracle(stats, c.storeUpgrades)
+	}
+	stateless, err := cosmos.StatelessApp(block.Height, emptyOracle{})
 	if err != nil {
 		return nil, log, err
 	}
+	if err = mountTransientStores(c.app, stateless); err != nil {
+		return nil, log, err
+	}
+	if err = loadStatelessStores(stateless, oracle, block.Height-1); err != nil {
+		return nil, log, err
+	}
+	if upgrade {
+		if err = applyStoreUpgrades(stateless, oracle, block.Height-1, c.storeUpgrades); err != nil {
+			return nil, log, err
+		}
+	}
+	observePhase("stateless_app", start)
 
 	// initialize chain
 	var abcivu []abci.ValidatorUpdate
 	if vals != nil {
 		abcivu = types.TM2PB.ValidatorUpdates(types.NewValidatorSet(vals))
 	}
-	stateless.InitChain(abci.RequestInitChain{
+	reqInitChain := abci.RequestInitChain{
 		Time:    block.Time,
 		ChainId: block.ChainID,
 		// ConsensusParams: nil, // ConsensusParams is not needed as it comes from oracle.
 		Validators: abcivu,
 		// AppStateBytes: nil, // AppStateBytes is not needed as it comes from oracle.
 		InitialHeight: block.Height,
+	}
+	_, err = c.runPhase(stats, PhaseInfo{Phase: PhaseInitChain, Height: block.Height, Request: reqInitChain}, func() interface{} {
+		return stateless.InitChain(reqInitChain)
 	})
+	if err != nil {
+		return nil, log, err
+	}
 
 	// begin block
 	byzVals := make([]abci.Evidence, 0)
 	for _, evidence := range block.Evidence.Evidence {
 		byzVals = append(byzVals, evidence.ABCI()...)
 	}
-	log.ResponseBeginBlock = stateless.BeginBlock(abci.RequestBeginBlock{
+	lastCommitInfo, err := getBeginBlockValidatorInfo(block, vals, c.initialHeight)
+	if err != nil {
+		return nil, log, err
+	}
+	reqBeginBlock := abci.RequestBeginBlock{
 		Hash:                block.Hash(),
 		Header:              *block.Header.ToProto(),
-		LastCommitInfo:      getBeginBlockValidatorInfo(block, vals, 0),
+		LastCommitInfo:      lastCommitInfo,
 		ByzantineValidators: byzVals,
+	}
+	res, err := c.runPhase(stats, PhaseInfo{Phase: PhaseBeginBlock, Height: block.Height, Request: reqBeginBlock}, func() interface{} {
+		return stateless.BeginBlock(reqBeginBlock)
 	})
+	if res != nil {
+		log.ResponseBeginBlock = res.(abci.ResponseBeginBlock)
+	}
+	if err != nil {
+		return nil, log, err
+	}
 
 	// deliver txs
-	for _, tx := range block.Data.Txs {
-		res := stateless.DeliverTx(abci.RequestDeliverTx{
+	for i, tx := range block.Data.Txs {
+		reqDeliverTx := abci.RequestDeliverTx{
 			Tx: tx,
+		}
+		res, err := c.runPhase(stats, PhaseInfo{Phase: PhaseDeliverTx, Height: block.Height, TxIndex: i, Request: reqDeliverTx}, func() interface{} {
+			return stateless.DeliverTx(reqDeliverTx)
 		})
-		log.ResponseDeliverTxs = append(log.ResponseDeliverTxs, res)
+		if res != nil {
+			log.ResponseDeliverTxs = append(log.ResponseDeliverTxs, res.(abci.ResponseDeliverTx))
+		}
+		if err != nil {
+			return nil, log, err
+		}
 	}
 
 	// end block
-	log.ResponseEndBlock = stateless.EndBlock(abci.RequestEndBlock{
+	reqEndBlock := abci.RequestEndBlock{
 		Height: block.Header.Height,
+	}
+	res, err = c.runPhase(stats, PhaseInfo{Phase: PhaseEndBlock, Height: block.Height, Request: reqEndBlock}, func() interface{} {
+		return stateless.EndBlock(reqEndBlock)
 	})
+	if res != nil {
+		log.ResponseEndBlock = res.(abci.ResponseEndBlock)
+	}
+	if err != nil {
+		return nil, log, err
+	}
 
 	// commit
-	log.ResponseCommit = stateless.Commit()
-	appHash := log.ResponseCommit.Data
+	res, err = c.runPhase(stats, PhaseInfo{Phase: PhaseCommit, Height: block.Height, Request: abci.RequestCommit{}}, func() interface{} {
+		return stateless.Commit()
+	})
+	if res != nil {
+		log.ResponseCommit = res.(abci.ResponseCommit)
+	}
+	if err != nil {
+		return nil, log, err
+	}
+	appHash = log.ResponseCommit.Data
+	loaded := stats.load()
+	c.logger.Info("executed block", "height", block.Height, "app_hash", fmt.Sprintf("%X", appHash), "oracle_requests", loaded.Requests, "oracle_bytes", loaded.Bytes)
 
 	// output
 	return appHash, log, nil
 }
 
-func getBeginBlockValidatorInfo(block *types.Block, vals []*types.Validator, initialHeight int64) abci.LastCommitInfo {
+// observePhase records the time since start.
+func observePhase(phase string, start time.Time) {
+	metrics.PhaseSeconds.WithLabelValues(phase).Observe(time.Since(start).Seconds())
+}
+
+func getBeginBlockValidatorInfo(block *types.Block, vals []*types.Validator, initialHeight int64) (abci.LastCommitInfo, error) {
 	voteInfos := make([]abci.VoteInfo, block.LastCommit.Size())
 	// Initial block -> LastCommitInfo.Votes are empty.
 	// Remember that the first LastCommit is intentionally empty, so it makes
@@ -102,10 +250,10 @@ func getBeginBlockValidatorInfo(block *types.Block, vals []*types.Validator, ini
 			valSetLen  = len(vals)
 		)
 		if commitSize != valSetLen {
-			panic(fmt.Sprintf(
-				"commit size (%d) doesn't match valset length (%d) at height %d\n\n%v\n\n%v",
-				commitSize, valSetLen, block.Height, block.LastCommit.Signatures, vals,
-			))
+			return abci.LastCommitInfo{}, fmt.Errorf(
+				"commit size (%d) doesn't match valset length (%d) at height %d",
+				commitSize, valSetLen, block.Height,
+			)
 		}
 
 		for i, val := range vals {
@@ -120,7 +268,7 @@ func getBeginBlockValidatorInfo(block *types.Block, vals []*types.Validator, ini
 	return abci.LastCommitInfo{
 		Round: block.LastCommit.Round,
 		Votes: voteInfos,
-	}
+	}, nil
 }
 
 type ExecutionLog struct {
@@ -1,18 +1,32 @@
 package client
 
 import (
+	"context"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
+	cmtdb "github.com/cometbft/cometbft-db"
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/snapshots"
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/cosmos/iavl"
 	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/tendermint/abci/types"
+	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
 
 	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
 	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
 	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp/mockrpc"
 )
 
 func TestExecuteStateless(t *testing.T) {
@@ -33,42 +47,434 @@ func TestExecuteStatelessRandom(t *testing.T) {
 }
 
 func executeStatelessWithLocalApp(t *testing.T, seed int64) {
-	// setup oracle server
-	app, err := testapp.NewTestApp()
+	chain := newTestChain(t, dbm.NewMemDB(), 128, 8, seed)
+	client := occlient.NewLocalOracleClient(chain.server(chain.height()))
+	stateless := newStatelessClient(t, client)
+
+	// execute stateless
+	resultBlock := client.Block()
+	resultVals := client.Validators()
+	executedAppHash, _, err := stateless.Execute(resultBlock.Block, resultVals.Validators)
+	require.NoError(t, err)
+
+	// test
+	require.NotEqual(t, chain.appHash(chain.height()-1), executedAppHash)
+	require.Equal(t, chain.appHash(chain.height()), executedAppHash)
+}
+
+// testChain is a test app that committed blocks of random txs.
+type testChain struct {
+	app         *baseapp.BaseApp
+	blocks      []*types.Block
+	appHashes   [][]byte
+	txsPerBlock int
+	r           *rand.Rand
+}
+
+// newTestChain initializes a test app on db and commits numBlocks blocks of
+// txsPerBlock random txs generated from seed.
+func newTestChain(t *testing.T, db dbm.DB, numBlocks, txsPerBlock int, seed int64) *testChain {
+	app, err := testapp.NewTestAppWithDB(db)
 	require.NoError(t, err)
 	app.InitChain(abci.RequestInitChain{})
-	challengeHeihgt := int64(128)
-	challengeBlock := &types.Block{}
-	challengeAppHash := []byte{}
-	agreementAppHash := []byte{}
-	r := rand.New(rand.NewSource(seed))
-	for i := range make([]int, challengeHeihgt) {
-		challengeBlock, err = testapp.ExecuteBlockWithTxs(app, 8, int64(i)+1, r)
+	c := &testChain{app: app, txsPerBlock: txsPerBlock, r: rand.New(rand.NewSource(seed))}
+	c.commitBlocks(t, numBlocks)
+	return c
+}
+
+func (c *testChain) commitBlocks(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		block, err := testapp.ExecuteBlockWithTxs(c.app, c.txsPerBlock, c.height()+1, c.r)
 		require.NoError(t, err)
-		res := app.Commit()
-		challengeAppHash = res.Data
-		if i+1 == int(challengeHeihgt)-1 {
-			agreementAppHash = res.Data
-		}
+		c.appHashes = append(c.appHashes, c.app.Commit().Data)
+		c.blocks = append(c.blocks, block)
 	}
-	server := ocserver.NewLocalOracleServer(app, challengeBlock, nil, nil)
+}
+
+func (c *testChain) height() int64 {
+	return int64(len(c.blocks))
+}
 
-	// setup oracle client
-	client := occlient.NewLocalOracleClient(server)
+func (c *testChain) block(height int64) *types.Block {
+	return c.blocks[height-1]
+}
+
+// appHash returns the app hash after the block of height.
+func (c *testChain) appHash(height int64) []byte {
+	return c.appHashes[height-1]
+}
 
-	// setup stateless client
-	newapp, err := testapp.NewTestApp()
+// server returns a local oracle server serving the blocks from height from,
+// by default the last one.
+func (c *testChain) server(from int64) *ocserver.LocalOracleServer {
+	server := ocserver.NewLocalOracleServer(c.app, c.block(c.height()), nil, nil)
+	for h := from; h < c.height(); h++ {
+		server.AddBlock(c.block(h), nil, nil)
+	}
+	return server
+}
+
+// newStatelessClient returns a stateless client of a new test app.
+func newStatelessClient(t testing.TB, oracle iavl.OracleClientI, opts ...Option) *StatelessClient {
+	app, err := testapp.NewTestApp()
 	require.NoError(t, err)
-	stateless, err := NewStatelessClient(newapp, client)
+	stateless, err := NewStatelessClient(app, oracle, opts...)
 	require.NoError(t, err)
+	return stateless
+}
+
+func TestExecuteStatelessWithPrefetch(t *testing.T) {
+	chain := newTestChain(t, dbm.NewMemDB(), 64, 8, 0)
+	challengeAppHash := chain.appHash(chain.height())
+	challengeBlock := chain.block(chain.height())
+	server := ocserver.NewPrefetchOracleServer(chain.server(chain.height()))
+	server.SetBatchSize(8)
+	speculative := occlient.NewLocalOracleClientAtHeight(chain.server(chain.height()-1), chain.height()-1)
+	stateless := newStatelessClient(t, occlient.NewLocalOracleClient(server))
 
 	// execute stateless
-	resultBlock := client.Block()
-	resultVals := client.Validators()
-	executedAppHash, _, err := stateless.Execute(resultBlock.Block, resultVals.Validators)
+	executedAppHash, _, err := stateless.ExecuteWithPrefetch(challengeBlock, nil, speculative, server, 4)
 	require.NoError(t, err)
 
 	// test
-	require.NotEqual(t, agreementAppHash, executedAppHash)
 	require.Equal(t, challengeAppHash, executedAppHash)
+	hits, misses := server.Stats()
+	require.Greater(t, hits, misses)
+	stats := stateless.PrefetchStats()
+	require.NoError(t, stats.DryRunErr)
+	require.NotZero(t, stats.Fetched)
+	require.Equal(t, stateless.OracleStats().Requests, stats.Requests)
+	require.Greater(t, stats.HitRate(), 0.5)
+}
+
+func TestExecuteStatelessMultipleHeights(t *testing.T) {
+	// a single oracle server serves all heights
+	chain := newTestChain(t, dbm.NewMemDB(), 32, 8, 0)
+	server := chain.server(2)
+
+	for h := chain.height(); h > 1; h -= 7 {
+		client := occlient.NewLocalOracleClientAtHeight(server, h)
+		stateless := newStatelessClient(t, client)
+
+		resultBlock := client.Block()
+		require.Equal(t, h, resultBlock.Block.Height)
+		executedAppHash, _, err := stateless.Execute(resultBlock.Block, nil)
+		require.NoError(t, err)
+		require.Equal(t, chain.appHash(h), executedAppHash)
+	}
+}
+
+func TestExecuteStatelessFromSnapshot(t *testing.T) {
+	// setup a snapshot of the previous height
+	chain := newTestChain(t, dbm.NewMemDB(), 32, 8, 0)
+	app := chain.app
+	snapshotHeight := chain.height()
+	store, err := snapshots.NewStore(dbm.NewMemDB(), t.TempDir())
+	require.NoError(t, err)
+	ch := make(chan io.ReadCloser)
+	go func() {
+		writer := snapshots.NewStreamWriter(ch)
+		if err := app.CommitMultiStore().(*rootmulti.Store).Snapshot(uint64(snapshotHeight), writer); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		writer.Close()
+	}()
+	_, err = store.Save(uint64(snapshotHeight), snapshottypes.CurrentFormat, ch)
+	require.NoError(t, err)
+
+	// setup oracle server serving blocks locally and state from the snapshot
+	appHash := app.LastCommitID().Hash
+	chain.commitBlocks(t, 1)
+	server, err := ocserver.NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), appHash, nil)
+	require.NoError(t, err)
+	stateless := newStatelessClient(t, occlient.NewLocalOracleClient(server))
+
+	// execute stateless
+	executedAppHash, _, err := stateless.Execute(chain.block(chain.height()), nil)
+	require.NoError(t, err)
+
+	// test
+	require.Equal(t, chain.appHash(chain.height()), executedAppHash)
+}
+
+func TestExecuteStatelessFromDB(t *testing.T) {
+	// setup the databases of a stopped node
+	appDB := dbm.NewMemDB()
+	chain := newTestChain(t, appDB, 16, 8, 0)
+	blocks := blockSource{}
+	for _, block := range chain.blocks {
+		blocks[block.Height] = block
+	}
+	local := chain.server(1)
+	server, err := ocserver.NewDBOracleServerWithDBs(appDB, blocks, sm.NewStore(cmtdb.NewMemDB(), sm.StoreOptions{}))
+	require.NoError(t, err)
+
+	for _, h := range []int64{3, 9, 16} {
+		// execute stateless and record the queries
+		queries := [][]byte{}
+		recording := corruptingServer{server: server, corrupt: func(req oracletypes.Request, res []byte) []byte {
+			if req.Kind == oracletypes.KindABCIQuery {
+				queries = append(queries, oracletypes.MustEncodeRequest(req))
+			}
+			return res
+		}}
+		client := occlient.NewLocalOracleClientAtHeight(recording, h)
+		stateless := newStatelessClient(t, client)
+		block := client.Block()
+		require.True(t, blocks.LoadBlockMeta(h).BlockID.Equals(block.BlockID))
+		executedAppHash, _, err := stateless.Execute(block.Block, nil)
+		require.NoError(t, err)
+		require.Equal(t, chain.appHash(h), executedAppHash, "height %d", h)
+
+		// node queries are answered like by the node itself
+		nodes := 0
+		for _, key := range queries {
+			req, err := oracletypes.DecodeRequest(key)
+			require.NoError(t, err)
+			if strings.HasSuffix(req.Queries[0].Path, "/node") {
+				nodes++
+			}
+			require.JSONEq(t, string(local.Get(key)), string(server.Get(key)), "height %d", h)
+		}
+		require.NotZero(t, nodes)
+	}
+}
+
+// blockSource serves the blocks of the test app like a block store.
+type blockSource map[int64]*types.Block
+
+func (m blockSource) LoadBlock(height int64) *types.Block {
+	return m[height]
+}
+
+func (m blockSource) LoadBlockMeta(height int64) *types.BlockMeta {
+	block := m[height]
+	if block == nil {
+		return nil
+	}
+	// fill the header before splitting the block into parts
+	block.Hash()
+	return types.NewBlockMeta(block, block.MakePartSet(types.BlockPartSizeBytes))
+}
+
+func TestExecuteStatelessWithHooks(t *testing.T) {
+	chain := newTestChain(t, dbm.NewMemDB(), 16, 4, 0)
+	challengeBlock := chain.block(chain.height())
+	server := chain.server(chain.height())
+	newStateless := func() *StatelessClient {
+		return newStatelessClient(t, occlient.NewLocalOracleClient(server))
+	}
+
+	// hooks see every phase with its request and response
+	stateless := newStateless()
+	phases := []string{}
+	stateless.AddHook(HookFuncs{
+		Before: func(info PhaseInfo) error {
+			require.NotNil(t, info.Request)
+			require.Nil(t, info.Response)
+			return nil
+		},
+		After: func(info PhaseInfo) error {
+			require.NotNil(t, info.Response)
+			require.Equal(t, challengeBlock.Height, info.Height)
+			phases = append(phases, info.Phase.String())
+			return nil
+		},
+	})
+	_, _, err := stateless.Execute(challengeBlock, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"init_chain", "begin_block", "deliver_tx", "deliver_tx", "deliver_tx", "deliver_tx", "end_block", "commit"}, phases)
+	require.NotZero(t, stateless.OracleStats().Requests)
+
+	// a hook aborts the execution
+	stateless = newStateless()
+	errAbort := fmt.Errorf("abort")
+	stateless.AddHook(HookFuncs{
+		Before: func(info PhaseInfo) error {
+			if info.Phase == PhaseDeliverTx && info.TxIndex == 2 {
+				return errAbort
+			}
+			return nil
+		},
+	})
+	_, log, err := stateless.Execute(challengeBlock, nil)
+	require.ErrorIs(t, err, errAbort)
+	require.Len(t, log.ResponseDeliverTxs, 2)
+
+	// a client executes one block at a time
+	stateless = newStateless()
+	stateless.AddHook(HookFuncs{
+		Before: func(info PhaseInfo) error {
+			_, _, err := stateless.Execute(challengeBlock, nil)
+			require.ErrorIs(t, err, ErrConcurrentExecution)
+			return nil
+		},
+	})
+	_, _, err = stateless.Execute(challengeBlock, nil)
+	require.NoError(t, err)
+	_, _, err = stateless.Execute(challengeBlock, nil)
+	require.NoError(t, err)
+}
+
+func TestExecuteStatelessWithLimits(t *testing.T) {
+	chain := newTestChain(t, dbm.NewMemDB(), 16, 8, 0)
+	server := chain.server(chain.height())
+	execute := func(opts ...Option) error {
+		stateless := newStatelessClient(t, occlient.NewLocalOracleClient(server), opts...)
+		_, _, err := stateless.Execute(chain.block(chain.height()), nil)
+		return err
+	}
+
+	require.NoError(t, execute(WithMaxOracleRequests(1000), WithMaxWitnessBytes(1<<30)))
+
+	err := execute(WithMaxOracleRequests(5))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	limitErr := &LimitError{}
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, "oracle requests", limitErr.Limit)
+
+	err = execute(WithMaxWitnessBytes(1024))
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, execute(WithContext(ctx)), context.Canceled)
+}
+
+func TestExecuteStatelessSignedChain(t *testing.T) {
+	chain, err := testapp.NewChain(3, 4)
+	require.NoError(t, err)
+	cp := chain.ConsensusParams()
+	appHashes := [][]byte{}
+	for h := int64(1); h <= 24; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 8, Evidence: h%6 == 0, Absent: int(h % 2)})
+		require.NoError(t, err)
+		appHashes = append(appHashes, chain.AppHash())
+	}
+	// validators of the previous height signed the last commit
+	server := ocserver.NewLocalOracleServer(chain.App, chain.Block(3), chain.Validators(2).Validators, &cp)
+	for h := int64(4); h <= chain.Height(); h++ {
+		server.AddBlock(chain.Block(h), chain.Validators(h-1).Validators, &cp)
+	}
+
+	for h := int64(3); h <= chain.Height(); h++ {
+		client := occlient.NewLocalOracleClientAtHeight(server, h)
+		stateless := newStatelessClient(t, client)
+
+		resultBlock := client.Block()
+		resultVals := client.Validators()
+		require.Equal(t, chain.BlockID(h).Hash, resultBlock.Block.Hash())
+		executedAppHash, _, err := stateless.Execute(resultBlock.Block, resultVals.Validators)
+		require.NoError(t, err)
+		require.Equal(t, appHashes[h-1], executedAppHash)
+	}
+
+	// a validator set that does not match the last commit fails the execution
+	client := occlient.NewLocalOracleClientAtHeight(server, chain.Height())
+	stateless := newStatelessClient(t, client)
+	_, _, err = stateless.Execute(client.Block().Block, client.Validators().Validators[1:])
+	require.ErrorContains(t, err, "doesn't match valset length")
+}
+
+func TestExecuteStatelessOverMockRPC(t *testing.T) {
+	chain, err := testapp.NewChain(5, 4)
+	require.NoError(t, err)
+	for h := int64(1); h <= 12; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 8, Evidence: h == 10})
+		require.NoError(t, err)
+	}
+
+	execute := func(height int64, basedir string, mocks ...*mockrpc.Server) (appHash []byte, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				var ok bool
+				if err, ok = r.(error); !ok {
+					err = fmt.Errorf("%v", r)
+				}
+			}
+		}()
+		addrs := []string{}
+		for _, mock := range mocks {
+			addrs = append(addrs, mock.URL)
+		}
+		config := ocserver.DefaultRPCPoolConfig()
+		config.MaxRetries = 2
+		config.InitialBackoff = time.Millisecond
+		config.MaxBackoff = time.Millisecond
+		config.FailureThreshold = 1000
+		pool, err := ocserver.NewRPCPool(addrs, config)
+		require.NoError(t, err)
+		server, err := ocserver.NewRPCOracleServerWithClient(int(height), hex.EncodeToString(chain.BlockID(height).Hash), pool, basedir)
+		if err != nil {
+			return nil, err
+		}
+		client := occlient.NewLocalOracleClientAtHeight(ocserver.NewPrefetchOracleServer(server), height)
+		stateless := newStatelessClient(t, client)
+		appHash, _, err = stateless.Execute(client.Block().Block, client.Validators().Validators)
+		return appHash, err
+	}
+
+	mock := mockrpc.NewServer(chain)
+	defer mock.Close()
+	for _, h := range []int64{4, 10, 11} {
+		appHash, err := execute(h, t.TempDir(), mock)
+		require.NoError(t, err)
+		require.Equal(t, []byte(chain.Block(h+1).AppHash), appHash)
+	}
+
+	// responses of a lying endpoint are fetched again from the next one and
+	// never cached
+	liar := mockrpc.NewServer(chain)
+	defer liar.Close()
+	liar.SetFaults(mockrpc.Faults{WrongBlockHash: true, TruncateValidators: true, ForgeProofs: true})
+	basedir := t.TempDir()
+	appHash, err := execute(11, basedir, liar, mock)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chain.Block(12).AppHash), appHash)
+	require.NotZero(t, liar.Calls("block"))
+	require.NotZero(t, liar.Calls("abci_query"))
+	// all responses are served from the cache, which is verified again
+	calls := liar.Calls("abci_query")
+	appHash, err = execute(11, basedir, liar)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chain.Block(12).AppHash), appHash)
+	require.Equal(t, calls, liar.Calls("abci_query"))
+
+	// forged proofs fail verification
+	mock.SetFaults(mockrpc.Faults{ForgeProofs: true})
+	appHash, err = execute(11, t.TempDir(), mock)
+	require.ErrorIs(t, err, oracletypes.ErrVerificationFailed)
+	require.Nil(t, appHash)
+}
+
+// executeStatelessBlocks executes blocks with a stateful app and checks that
+// executing each block from height 3 statelessly results in the same app
+// hash.
+func executeStatelessBlocks(t *testing.T, blocks []types.Txs) *baseapp.BaseApp {
+	app, err := testapp.NewTestApp()
+	require.NoError(t, err)
+	app.InitChain(abci.RequestInitChain{})
+	var server *ocserver.LocalOracleServer
+	appHashes := [][]byte{}
+	for i, txs := range blocks {
+		block, err := testapp.ExecuteBlock(app, txs, int64(i)+1)
+		require.NoError(t, err)
+		appHashes = append(appHashes, app.Commit().Data)
+		if server == nil {
+			server = ocserver.NewLocalOracleServer(app, block, nil, nil)
+		} else {
+			server.AddBlock(block, nil, nil)
+		}
+	}
+
+	for h := int64(3); h <= int64(len(blocks)); h++ {
+		client := occlient.NewLocalOracleClientAtHeight(server, h)
+		stateless := newStatelessClient(t, client)
+
+		executedAppHash, _, err := stateless.Execute(client.Block().Block, nil)
+		require.NoError(t, err)
+		require.Equal(t, appHashes[h-1], executedAppHash, "height %d", h)
+	}
+	return app
 }
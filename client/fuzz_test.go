@@ -0,0 +1,96 @@
+package client
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+// fuzzTxs decodes ops into the txs of numBlocks blocks of up to txsPerBlock
+// txs each. Every tx takes one op byte followed by its key and value. The
+// lowest three bits of the op byte choose the tx, bits 3-4 the length of the
+// key, 1 to 4 bytes, and bits 5-7 the length of the value, 1 to 8 bytes:
+//
+//	0-4: set the key to the value
+//	5:   get the key
+//	6:   remove the key
+//	7:   remove every key that was set
+//
+// Keys and values cut off by the end of ops are padded with zeros, and
+// blocks after the end of ops are empty.
+func fuzzTxs(t *testing.T, txConfig client.TxConfig, numBlocks, txsPerBlock int, ops []byte) []types.Txs {
+	live := map[string]bool{}
+	next := func(n int) []byte {
+		b := make([]byte, n)
+		ops = ops[copy(b, ops):]
+		return b
+	}
+
+	blocks := make([]types.Txs, numBlocks)
+	for i := range blocks {
+		for j := 0; j < txsPerBlock && len(ops) > 0; j++ {
+			op := next(1)[0]
+			keyLen, valueLen := int(op>>3&3)+1, int(op>>5)+1
+			msgs := []sdk.Msg{}
+			switch op % 8 {
+			case 5:
+				msgs = append(msgs, &testapp.MsgGet{Key: next(keyLen)})
+			case 6:
+				key := next(keyLen)
+				delete(live, string(key))
+				msgs = append(msgs, &testapp.MsgRemove{Key: key})
+			case 7:
+				keys := []string{}
+				for key := range live {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					msgs = append(msgs, &testapp.MsgRemove{Key: []byte(key)})
+				}
+				live = map[string]bool{}
+				if len(msgs) == 0 {
+					msgs = append(msgs, &testapp.MsgGet{Key: []byte{0}})
+				}
+			default:
+				key := next(keyLen)
+				live[string(key)] = true
+				msgs = append(msgs, &testapp.MsgSet{Key: key, Value: next(valueLen)})
+			}
+			tx, err := testapp.EncodeTx(txConfig, msgs...)
+			require.NoError(t, err)
+			blocks[i] = append(blocks[i], tx)
+		}
+	}
+	return blocks
+}
+
+// executeFuzzCase checks that the blocks of ops execute statelessly.
+func executeFuzzCase(t *testing.T, txConfig client.TxConfig, numBlocks, txsPerBlock int, ops []byte) {
+	executeStatelessBlocks(t, fuzzTxs(t, txConfig, numBlocks, txsPerBlock, ops))
+}
+
+func FuzzExecuteStateless(f *testing.F) {
+	f.Add(uint8(4), uint8(8), []byte{0, 1, 2, 8, 3, 4, 5, 1, 6, 3, 16, 5, 6})
+	// empty blocks
+	f.Add(uint8(5), uint8(0), []byte{})
+	// the last block deletes every key
+	f.Add(uint8(3), uint8(4), []byte{0, 1, 1, 0, 2, 2, 0, 3, 3, 0, 4, 4, 7})
+
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	f.Fuzz(func(t *testing.T, numBlocks uint8, txsPerBlock uint8, ops []byte) {
+		executeFuzzCase(t, txConfig, int(numBlocks)%6+3, int(txsPerBlock)%9, ops)
+	})
+}
+
+func TestExecuteStatelessRemoveToSingleLeaf(t *testing.T) {
+	// set a key at height 1, another at height 2 and remove one at height 3
+	executeFuzzCase(t, simapp.MakeTestEncodingConfig().TxConfig, 3, 1, []byte("\xc000000000\xc0\x030000000\x060"))
+}
@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/iavl"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+type Phase int
+
+const (
+	PhaseInitChain Phase = iota
+	PhaseBeginBlock
+	PhaseDeliverTx
+	PhaseEndBlock
+	PhaseCommit
+)
+
+var phaseNames = map[Phase]string{
+	PhaseInitChain:  "init_chain",
+	PhaseBeginBlock: "begin_block",
+	PhaseDeliverTx:  "deliver_tx",
+	PhaseEndBlock:   "end_block",
+	PhaseCommit:     "commit",
+}
+
+func (p Phase) String() string {
+	if name, ok := phaseNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(p))
+}
+
+// PhaseInfo describes an ABCI call made by Execute.
+type PhaseInfo struct {
+	Phase  Phase
+	Height int64
+	// TxIndex is the index of the transaction in the block for PhaseDeliverTx.
+	TxIndex int
+	// Request is the abci.RequestXxx value of the phase.
+	Request interface{}
+	// Response is the abci.ResponseXxx value of the phase. It is nil before
+	// the phase.
+	Response interface{}
+	// Oracle holds the oracle statistics of the execution so far.
+	Oracle OracleStats
+}
+
+// Hook is called before and after each phase of Execute. An error returned
+// by a hook aborts the execution.
+type Hook interface {
+	BeforePhase(info PhaseInfo) error
+	AfterPhase(info PhaseInfo) error
+}
+
+// HookFuncs implements Hook with optional functions.
+type HookFuncs struct {
+	Before func(info PhaseInfo) error
+	After  func(info PhaseInfo) error
+}
+
+func (h HookFuncs) BeforePhase(info PhaseInfo) error {
+	if h.Before == nil {
+		return nil
+	}
+	return h.Before(info)
+}
+
+func (h HookFuncs) AfterPhase(info PhaseInfo) error {
+	if h.After == nil {
+		return nil
+	}
+	return h.After(info)
+}
+
+// AddHook registers h. Hooks are called in the order they are added.
+func (c *StatelessClient) AddHook(h Hook) {
+	c.hooks = append(c.hooks, h)
+}
+
+// OracleStats counts the oracle requests of an execution.
+type OracleStats struct {
+	Requests uint64
+	Bytes    uint64
+}
+
+// OracleStats returns the statistics of the last execution.
+func (c *StatelessClient) OracleStats() OracleStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.stats.load()
+}
+
+// statsOracle counts the requests made to oracle and enforces the limits of
+// an execution.
+type statsOracle struct {
+	oracle      iavl.OracleClientI
+	ctx         context.Context
+	maxRequests uint64
+	maxBytes    uint64
+
+	requests uint64
+	bytes    uint64
+
+	// prefetched, if set, are the keys fetched before the execution, and
+	// hits counts the requests for them
+	prefetched map[string]struct{}
+	hits       uint64
+
+	// recording, if set, records the requests with the current phase
+	recording *witness.Recording
+
+	mtx     sync.Mutex
+	err     error
+	phase   Phase
+	txIndex int
+	loaded  bool
+}
+
+// abortError is the panic value of an oracle request made after a limit was
+// exceeded or whose response failed verification.
+type abortError struct {
+	err error
+}
+
+func (o *statsOracle) Get(key []byte) []byte {
+	if err := o.check(); err != nil {
+		panic(abortError{err})
+	}
+	if _, ok := o.prefetched[string(key)]; ok {
+		atomic.AddUint64(&o.hits, 1)
+	}
+	value := o.get(key)
+	o.record(key, value)
+	requests := atomic.AddUint64(&o.requests, 1)
+	bytes := atomic.AddUint64(&o.bytes, uint64(len(value)))
+	if o.maxRequests > 0 && requests > o.maxRequests {
+		o.abort(&LimitError{Limit: "oracle requests", Max: o.maxRequests})
+	}
+	if o.maxBytes > 0 && bytes > o.maxBytes {
+		o.abort(&LimitError{Limit: "witness bytes", Max: o.maxBytes})
+	}
+	return value
+}
+
+// get turns verification failures of the oracle into aborts, so that they are
+// returned by Execute even if baseapp recovers the panic.
+func (o *statsOracle) get(key []byte) []byte {
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); ok && errors.Is(err, oracletypes.ErrVerificationFailed) {
+				o.abort(err)
+			}
+			panic(r)
+		}
+	}()
+	return o.oracle.Get(key)
+}
+
+func (o *statsOracle) record(key, value []byte) {
+	if o.recording == nil {
+		return
+	}
+	o.mtx.Lock()
+	access := witness.Access{Phase: "stateless_app", Request: string(key), Response: value}
+	if o.loaded {
+		access.Phase = o.phase.String()
+		if o.phase == PhaseDeliverTx {
+			access.TxIndex = o.txIndex
+		}
+	}
+	o.mtx.Unlock()
+	o.recording.Add(access)
+}
+
+// setPhase sets the phase of the following requests.
+func (o *statsOracle) setPhase(info PhaseInfo) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.phase, o.txIndex, o.loaded = info.Phase, info.TxIndex, true
+}
+
+func (o *statsOracle) abort(err error) {
+	o.mtx.Lock()
+	if o.err == nil {
+		o.err = err
+	}
+	o.mtx.Unlock()
+	panic(abortError{err})
+}
+
+// check returns the error that aborted the execution, if any.
+func (o *statsOracle) check() error {
+	if o == nil {
+		return nil
+	}
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if o.err == nil && o.ctx != nil && o.ctx.Err() != nil {
+		o.err = o.ctx.Err()
+	}
+	return o.err
+}
+
+func (o *statsOracle) load() OracleStats {
+	if o == nil {
+		return OracleStats{}
+	}
+	return OracleStats{
+		Requests: atomic.LoadUint64(&o.requests),
+		Bytes:    atomic.LoadUint64(&o.bytes),
+	}
+}
+
+func (o *statsOracle) prefetchHits() uint64 {
+	if o == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&o.hits)
+}
+
+// runPhase calls call between the hooks of the phase.
+func (c *StatelessClient) runPhase(stats *statsOracle, info PhaseInfo, call func() interface{}) (interface{}, error) {
+	if err := stats.check(); err != nil {
+		return nil, err
+	}
+	info.Oracle = stats.load()
+	for _, h := range c.hooks {
+		if err := h.BeforePhase(info); err != nil {
+			return nil, fmt.Errorf("before %s: %w", info.Phase, err)
+		}
+	}
+
+	stats.setPhase(info)
+	start := time.Now()
+	info.Response = call()
+	observePhase(info.Phase.String(), start)
+	c.logger.Debug("executed phase", "phase", info.Phase, "height", info.Height, "tx", info.TxIndex)
+	if err := stats.check(); err != nil {
+		return info.Response, err
+	}
+
+	info.Oracle = stats.load()
+	for _, h := range c.hooks {
+		if err := h.AfterPhase(info); err != nil {
+			return info.Response, fmt.Errorf("after %s: %w", info.Phase, err)
+		}
+	}
+	return info.Response, nil
+}
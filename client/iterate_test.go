@@ -0,0 +1,77 @@
+package client
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+func TestExecuteStatelessIteration(t *testing.T) {
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	tx := func(msgs ...sdk.Msg) []byte {
+		bz, err := testapp.EncodeTx(txConfig, msgs...)
+		require.NoError(t, err)
+		return bz
+	}
+	set := func(key string) sdk.Msg {
+		return &testapp.MsgSet{Key: []byte(key), Value: []byte("value of " + key)}
+	}
+
+	// 4 prefixes of 64 keys, so that every prefix spans several subtrees of
+	// which a single block only touches a part
+	genesis := types.Txs{}
+	for _, prefix := range "abcd" {
+		msgs := []sdk.Msg{}
+		for i := 0; i < 64; i++ {
+			msgs = append(msgs, set(fmt.Sprintf("%c%02d", prefix, i)))
+		}
+		genesis = append(genesis, tx(msgs...))
+	}
+
+	blocks := []types.Txs{
+		genesis,
+		{tx(set("a70"), set("b70")), tx(&testapp.MsgRemove{Key: []byte("d00")})},
+		// a whole prefix
+		{tx(&testapp.MsgIterate{Start: []byte("b"), End: []byte("c")})},
+		// across prefixes, with a limit
+		{tx(&testapp.MsgReverseIterate{Start: []byte("a50"), End: []byte("c10"), Limit: 20})},
+		// unbounded with limits at both ends of the tree
+		{
+			tx(&testapp.MsgIterate{Limit: 5}),
+			tx(&testapp.MsgReverseIterate{Limit: 5}),
+		},
+		// writes of the same block are merged into the iteration
+		{
+			tx(set("b05x"), &testapp.MsgRemove{Key: []byte("b07")}, &testapp.MsgIterate{Start: []byte("b03"), End: []byte("b12")}),
+			tx(set("c99"), &testapp.MsgReverseIterate{Start: []byte("c50")}),
+		},
+		// prefix deletion followed by scans over the deleted range
+		{
+			tx(&testapp.MsgDeletePrefix{Prefix: []byte("c")}),
+			tx(&testapp.MsgIterate{Start: []byte("b60"), End: []byte("d05")}),
+			tx(&testapp.MsgDeletePrefix{Prefix: []byte("z")}),
+		},
+		// a deleted prefix set again
+		{
+			tx(set("c00"), set("c01")),
+			tx(&testapp.MsgDeletePrefix{Prefix: []byte("a1")}, &testapp.MsgReverseIterate{End: []byte("c")}),
+		},
+	}
+	app := executeStatelessBlocks(t, blocks)
+
+	res := app.Query(abci.RequestQuery{Path: "/store/key1/key", Data: testapp.IterateResultKey})
+	require.True(t, res.IsOK(), res.Log)
+	empty := sha256.Sum256(nil)
+	require.NotEqual(t, empty[:], res.Value)
+	res = app.Query(abci.RequestQuery{Path: "/store/key2/key", Data: []byte("c30")})
+	require.True(t, res.IsOK(), res.Log)
+	require.Nil(t, res.Value)
+}
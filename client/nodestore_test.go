@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp/mockrpc"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+func TestNodeStore(t *testing.T) {
+	chain, err := testapp.NewChain(11, 4)
+	require.NoError(t, err)
+	const from, to = 3, 7
+	for h := int64(1); h <= to+1; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 4})
+		require.NoError(t, err)
+	}
+	mock := mockrpc.NewServer(chain)
+	defer mock.Close()
+
+	// execute the heights with and without a node store and count the
+	// fetched queries of each height
+	fetched := func(nodes *witness.NodeStore) map[int64]int {
+		pool, err := ocserver.NewRPCPool([]string{mock.URL}, ocserver.DefaultRPCPoolConfig())
+		require.NoError(t, err)
+		basedir := t.TempDir()
+		server := ocserver.NewHeightAgnosticRPCOracleServer(pool, basedir)
+		require.NoError(t, server.Trust(to+1, chain.BlockID(to+1).Hash))
+		if nodes != nil {
+			server.SetNodeStore(nodes)
+		}
+
+		res := map[int64]int{}
+		for h := int64(from); h <= to; h++ {
+			client := occlient.NewLocalOracleClientAtHeight(server, h)
+			stateless := newStatelessClient(t, client)
+			appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+			require.NoError(t, err)
+			require.Equal(t, []byte(chain.Block(h+1).AppHash), appHash, "height %d", h)
+
+			files, err := os.ReadDir(fmt.Sprintf("%s/output/%d", basedir, h))
+			require.NoError(t, err)
+			for _, f := range files {
+				if strings.HasPrefix(f.Name(), "abci_query") {
+					res[h]++
+				}
+			}
+		}
+		return res
+	}
+	without := fetched(nil)
+	with := fetched(witness.NewNodeStore(dbm.NewMemDB()))
+
+	// the first height fetches everything, the later ones only the nodes
+	// that changed
+	require.Equal(t, without[from], with[from])
+	for h := int64(from + 1); h <= to; h++ {
+		require.Less(t, with[h], without[h], "height %d", h)
+	}
+}
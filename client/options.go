@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+// ErrLimitExceeded is the error every LimitError unwraps to, so errors.Is
+// matches any exceeded limit.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError is returned by Execute when a limit set by an option is exceeded.
+type LimitError struct {
+	Limit string
+	Max   uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded the limit of %d", e.Limit, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Option configures a StatelessClient created by NewStatelessClient.
+type Option func(*StatelessClient)
+
+// WithLogger sets the logger of the client, by default a nop logger.
+func WithLogger(logger log.Logger) Option {
+	return func(c *StatelessClient) {
+		c.logger = logger
+	}
+}
+
+// WithContext aborts the execution when ctx is done.
+func WithContext(ctx context.Context) Option {
+	return func(c *StatelessClient) {
+		c.ctx = ctx
+	}
+}
+
+// WithMaxOracleRequests aborts the execution after n oracle requests.
+func WithMaxOracleRequests(n uint64) Option {
+	return func(c *StatelessClient) {
+		c.maxOracleRequests = n
+	}
+}
+
+// WithMaxWitnessBytes aborts the execution after n bytes of oracle responses.
+func WithMaxWitnessBytes(n uint64) Option {
+	return func(c *StatelessClient) {
+		c.maxWitnessBytes = n
+	}
+}
+
+// WithWitnessRecording records the oracle requests of each execution in rec,
+// which is reset by every execution.
+func WithWitnessRecording(rec *witness.Recording) Option {
+	return func(c *StatelessClient) {
+		c.recording = rec
+	}
+}
+
+// WithInitialHeight sets the initial height of the chain. The last commit of
+// the block at the initial height is not checked against the validators.
+func WithInitialHeight(height int64) Option {
+	return func(c *StatelessClient) {
+		c.initialHeight = height
+	}
+}
+
+// WithStoreUpgrades applies upgrades before the block at height like the
+// StoreLoader of upgradetypes.UpgradeStoreLoader does. The app must already
+// mount the stores after the upgrade.
+func WithStoreUpgrades(height int64, upgrades storetypes.StoreUpgrades) Option {
+	return func(c *StatelessClient) {
+		c.upgradeHeight = height
+		c.storeUpgrades = &upgrades
+	}
+}
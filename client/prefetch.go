@@ -0,0 +1,131 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cosmos/iavl"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+	"github.com/tendermint/tendermint/types"
+)
+
+type Prefetcher interface {
+	Prefetch(keys [][]byte, workers int) int
+}
+
+// PrefetchStats describes the prefetching of the last ExecuteWithPrefetch.
+type PrefetchStats struct {
+	// Keys is the number of keys requested by the dry run.
+	Keys int
+	// Fetched is the number of keys the prefetcher fetched.
+	Fetched int
+	// Requests is the number of oracle requests of the execution and Hits the
+	// number of them that the dry run discovered.
+	Requests uint64
+	Hits     uint64
+	// DryRunErr is the error the dry run failed with. Speculative data is
+	// stale along the paths the block changes, so the dry run may fail and
+	// discover only part of the keys.
+	DryRunErr error
+}
+
+// HitRate returns the share of the requests of the execution that the dry run
+// discovered.
+func (s PrefetchStats) HitRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Requests)
+}
+
+// DryRun executes block against a speculative oracle, e.g. one serving the
+// data of a previous height, and returns the oracle keys that were requested.
+// The result of the execution is discarded. If the speculative data is
+// inconsistent and the execution fails, the keys requested until then are
+// returned with the error.
+func (c *StatelessClient) DryRun(block *types.Block, vals []*types.Validator, speculative iavl.OracleClientI) (keys [][]byte, err error) {
+	recorder := &recordingOracle{oracle: speculative, seen: map[string]struct{}{}}
+	dry := &StatelessClient{
+		app:           c.app,
+		oracle:        recorder,
+		logger:        tmlog.NewNopLogger(),
+		ctx:           c.ctx,
+		initialHeight: c.initialHeight,
+		upgradeHeight: c.upgradeHeight,
+		storeUpgrades: c.storeUpgrades,
+	}
+	func() {
+		// the speculative oracle panics on keys it does not have
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("dry run: %v", r)
+			}
+		}()
+		_, _, err = dry.Execute(block, vals)
+	}()
+	return recorder.keys, err
+}
+
+// ExecuteWithPrefetch discovers the access list of block with DryRun, fetches
+// it in parallel with prefetcher, which must be the cache behind the oracle
+// of c, and then executes block. A failed dry run does not fail the
+// execution, the keys it missed are fetched on demand.
+func (c *StatelessClient) ExecuteWithPrefetch(block *types.Block, vals []*types.Validator, speculative iavl.OracleClientI, prefetcher Prefetcher, workers int) ([]byte, ExecutionLog, error) {
+	if prefetcher == nil {
+		return nil, ExecutionLog{}, fmt.Errorf("prefetcher is nil")
+	}
+	keys, dryErr := c.DryRun(block, vals, speculative)
+	if dryErr != nil {
+		c.logger.Info("dry run failed", "height", block.Height, "keys", len(keys), "err", dryErr)
+	}
+	fetched := prefetcher.Prefetch(keys, workers)
+	c.logger.Info("prefetched keys", "height", block.Height, "keys", len(keys), "fetched", fetched)
+
+	prefetched := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		prefetched[string(key)] = struct{}{}
+	}
+	appHash, log, err := c.execute(block, vals, prefetched)
+	if errors.Is(err, ErrConcurrentExecution) {
+		return appHash, log, err
+	}
+
+	c.mtx.Lock()
+	c.prefetch = PrefetchStats{
+		Keys:      len(keys),
+		Fetched:   fetched,
+		Requests:  c.stats.load().Requests,
+		Hits:      c.stats.prefetchHits(),
+		DryRunErr: dryErr,
+	}
+	stats := c.prefetch
+	c.mtx.Unlock()
+	c.logger.Info("prefetch hit rate", "height", block.Height, "hits", stats.Hits, "requests", stats.Requests, "rate", stats.HitRate())
+	return appHash, log, err
+}
+
+// PrefetchStats returns the statistics of the last ExecuteWithPrefetch.
+func (c *StatelessClient) PrefetchStats() PrefetchStats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.prefetch
+}
+
+type recordingOracle struct {
+	oracle iavl.OracleClientI
+
+	mtx  sync.Mutex
+	keys [][]byte
+	seen map[string]struct{}
+}
+
+func (o *recordingOracle) Get(key []byte) []byte {
+	o.mtx.Lock()
+	if _, ok := o.seen[string(key)]; !ok {
+		o.seen[string(key)] = struct{}{}
+		o.keys = append(o.keys, key)
+	}
+	o.mtx.Unlock()
+	return o.oracle.Get(key)
+}
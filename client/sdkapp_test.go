@@ -0,0 +1,78 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp/sdkapp"
+)
+
+func TestExecuteStatelessSDKApp(t *testing.T) {
+	chain, err := sdkapp.NewChain(1, 4)
+	require.NoError(t, err)
+	cp := chain.ConsensusParams()
+	appHashes := map[int64][]byte{}
+	for h := int64(1); h <= 20; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 8, Evidence: h%7 == 0, Absent: int(h % 3 / 2)})
+		require.NoError(t, err)
+		appHashes[h] = chain.AppHash()
+	}
+	require.NotEqual(t, chain.Validators(1).Hash(), chain.Validators(chain.Height()).Hash())
+
+	server := ocserver.NewLocalOracleServer(chain.App, chain.Block(3), chain.Validators(2).Validators, &cp)
+	for h := int64(4); h <= chain.Height(); h++ {
+		server.AddBlock(chain.Block(h), chain.Validators(h-1).Validators, &cp)
+	}
+
+	updated, rewarded := 0, 0
+	for h := int64(3); h <= chain.Height(); h++ {
+		t.Run(fmt.Sprintf("height %d", h), func(t *testing.T) {
+			client := occlient.NewLocalOracleClientAtHeight(server, h)
+			newapp, err := sdkapp.NewApp(dbm.NewMemDB())
+			require.NoError(t, err)
+			stateless, err := NewStatelessClient(newapp, client)
+			require.NoError(t, err)
+
+			executedAppHash, log, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+			require.NoError(t, err)
+			require.Equal(t, appHashes[h], executedAppHash)
+			for _, res := range log.ResponseDeliverTxs {
+				require.True(t, res.IsOK(), res.Log)
+			}
+
+			// the validator updates of EndBlock are the ones the chain
+			// applied two heights later
+			vals := chain.Validators(h + 1).Copy()
+			changes, err := types.PB2TM.ValidatorUpdates(log.ResponseEndBlock.ValidatorUpdates)
+			require.NoError(t, err)
+			require.NoError(t, vals.UpdateWithChangeSet(changes))
+			require.Equal(t, chain.Validators(h+2).Hash(), vals.Hash())
+			if len(changes) > 0 {
+				updated++
+			}
+			if hasEvent(log.ResponseBeginBlock.Events, distrtypes.EventTypeRewards) {
+				rewarded++
+			}
+		})
+	}
+	require.NotZero(t, updated)
+	require.NotZero(t, rewarded)
+}
+
+func hasEvent(events []abci.Event, eventType string) bool {
+	for _, event := range events {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	iavlstore "github.com/cosmos/cosmos-sdk/store/iavl"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/iavl"
+	dbm "github.com/tendermint/tm-db"
+)
+
+// statelessTree fixes removals that replace the root of a StatelessTree by
+// one of its children. StatelessTree looks the child up in its node db,
+// which only holds the nodes fetched so far, and the IAVL store drops the
+// error, so the tree keeps its old root.
+type statelessTree struct {
+	*iavl.StatelessTree
+}
+
+func (t statelessTree) Remove(key []byte) ([]byte, bool, error) {
+	// only a root of height 1 or 2 can be replaced by a child, fetch both
+	// children through the leftmost and the rightmost leaf
+	if height := t.Height(); height > 0 && height <= 2 {
+		if _, _, err := t.GetByIndex(0); err != nil {
+			return nil, false, err
+		}
+		if _, _, err := t.GetByIndex(t.Size() - 1); err != nil {
+			return nil, false, err
+		}
+	}
+	return t.StatelessTree.Remove(key)
+}
+
+func newStatelessStore(oracle iavl.OracleClientI, version int64, name string) (storetypes.CommitKVStore, error) {
+	tree := iavl.NewStatelessTree(dbm.NewMemDB(), 100, false, version, oracle, name)
+	return iavlstore.LoadStoreWithStatelessTree(statelessTree{tree})
+}
+
+// loadStatelessStores replaces the IAVL stores of stateless, which
+// StatelessApp loads from plain StatelessTrees, by stores of statelessTree
+// reading the stores at version from oracle.
+func loadStatelessStores(stateless *baseapp.BaseApp, oracle iavl.OracleClientI, version int64) error {
+	cms, ok := stateless.CommitMultiStore().(*rootmulti.Store)
+	if !ok {
+		return fmt.Errorf("stateless application requires a rootmulti store")
+	}
+	stores := cms.GetStores()
+	for name, key := range cms.GetKVStoreKeys() {
+		store, err := newStatelessStore(oracle, version, name)
+		if err != nil {
+			return err
+		}
+		stores[key] = store
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/iavl"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// upgradeOracle serves the stores added by a store upgrade, which do not
+// exist at the height below the upgrade, as empty stores.
+type upgradeOracle struct {
+	oracle iavl.OracleClientI
+	added  map[string]bool
+}
+
+func newUpgradeOracle(oracle iavl.OracleClientI, upgrades *storetypes.StoreUpgrades) *upgradeOracle {
+	added := map[string]bool{}
+	for _, name := range upgrades.Added {
+		added[name] = true
+	}
+	for _, rename := range upgrades.Renamed {
+		added[rename.NewKey] = true
+	}
+	return &upgradeOracle{oracle: oracle, added: added}
+}
+
+func (o *upgradeOracle) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	if req.Kind != oracletypes.KindABCIQuery {
+		return o.oracle.Get(key)
+	}
+	// paths are store/<name>/<subpath>
+	path := strings.Split(req.Queries[0].Path, "/")
+	if len(path) != 3 || path[0] != "store" || !o.added[path[1]] {
+		return o.oracle.Get(key)
+	}
+	return emptyStoreResponse(req)
+}
+
+// emptyOracle serves every store as an empty store. StatelessApp loads its
+// stores from it, as loadStatelessStores replaces them anyway, so that the
+// roots of the stores are fetched once.
+type emptyOracle struct{}
+
+func (emptyOracle) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	if req.Kind != oracletypes.KindABCIQuery {
+		panic(fmt.Errorf("%s request for an empty store", req.Kind))
+	}
+	return emptyStoreResponse(req)
+}
+
+// emptyStoreResponse answers the store query of req as if the store was
+// empty.
+func emptyStoreResponse(req oracletypes.Request) []byte {
+	q := req.Queries[0]
+	path := strings.Split(q.Path, "/")
+	res := abci.ResponseQuery{Height: req.Height - 1}
+	if len(path) == 3 && path[2] == "key" && bytes.Equal(q.Data, oracletypes.RootHashKey) {
+		// the IAVL oracle client takes the root hash from the store proof
+		proof := &ics23.CommitmentProof{
+			Proof: &ics23.CommitmentProof_Exist{
+				Exist: &ics23.ExistenceProof{Key: []byte(path[1]), Value: oracletypes.EmptyTreeHash},
+			},
+		}
+		bz, err := proof.Marshal()
+		if err != nil {
+			panic(err)
+		}
+		res.ProofOps = &tmcrypto.ProofOps{Ops: []tmcrypto.ProofOp{
+			{Type: storetypes.ProofOpSimpleMerkleCommitment, Key: []byte(path[1]), Data: bz},
+		}}
+	}
+	bz, err := oracletypes.EncodeResponse(ctypes.ResultABCIQuery{Response: res})
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
+
+// mountTransientStores mounts the transient stores of app, which are not
+// mounted by StatelessApp, to stateless and reloads its stores.
+func mountTransientStores(app interface{}, stateless *baseapp.BaseApp) error {
+	withCms, ok := app.(interface {
+		CommitMultiStore() sdk.CommitMultiStore
+	})
+	if !ok {
+		return nil
+	}
+	cms, ok := withCms.CommitMultiStore().(*rootmulti.Store)
+	if !ok {
+		return nil
+	}
+	statelessCms, ok := stateless.CommitMultiStore().(*rootmulti.Store)
+	if !ok {
+		return fmt.Errorf("stateless application requires a rootmulti store")
+	}
+
+	mounted := false
+	for key, store := range cms.GetStores() {
+		if store.GetStoreType() == storetypes.StoreTypeTransient {
+			statelessCms.MountStoreWithDB(key, storetypes.StoreTypeTransient, nil)
+			mounted = true
+		}
+	}
+	if !mounted {
+		return nil
+	}
+	// KV stores are reloaded from the same stateless trees
+	return statelessCms.LoadLatestVersion()
+}
+
+// applyStoreUpgrades applies upgrades to the stores of stateless like
+// rootmulti.Store.LoadVersionAndUpgrade. Renamed stores are read from the
+// oracle at version.
+func applyStoreUpgrades(stateless *baseapp.BaseApp, oracle iavl.OracleClientI, version int64, upgrades *storetypes.StoreUpgrades) error {
+	cms, ok := stateless.CommitMultiStore().(*rootmulti.Store)
+	if !ok {
+		return fmt.Errorf("stateless application requires a rootmulti store")
+	}
+
+	// in the order of rootmulti
+	keys := []*sdk.KVStoreKey{}
+	for _, key := range cms.GetKVStoreKeys() {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name() < keys[j].Name()
+	})
+
+	stores := cms.GetStores()
+	for _, key := range keys {
+		oldName := upgrades.RenamedFrom(key.Name())
+		if upgrades.IsAdded(key.Name()) || oldName != "" {
+			// the nodes of the first version of a tree have version 1
+			// regardless of its initial version, so added stores start
+			// from version 0 instead of the version of the other stores
+			store, err := newStatelessStore(oracle, 0, key.Name())
+			if err != nil {
+				return err
+			}
+			stores[key] = store
+		}
+
+		store := cms.GetKVStore(key)
+		if upgrades.IsDeleted(key.Name()) {
+			deleteKVStore(store)
+		} else if oldName != "" {
+			oldStore, err := newStatelessStore(oracle, version, oldName)
+			if err != nil {
+				return err
+			}
+			moveKVStoreData(oldStore, store)
+		}
+	}
+	return nil
+}
+
+func deleteKVStore(kv storetypes.KVStore) {
+	// keys cannot be deleted while iterating
+	keys := [][]byte{}
+	iter := kv.Iterator(nil, nil)
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		kv.Delete(key)
+	}
+}
+
+func moveKVStoreData(oldDB, newDB storetypes.KVStore) {
+	iter := oldDB.Iterator(nil, nil)
+	for ; iter.Valid(); iter.Next() {
+		newDB.Set(iter.Key(), iter.Value())
+	}
+	iter.Close()
+	// the old store is not committed, so it is not emptied
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/simapp"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+func TestExecuteStatelessStoreUpgrade(t *testing.T) {
+	const upgradeHeight, lastHeight = 6, 9
+	before := testapp.Config{
+		Stores:          []string{"old", "gone", "dropped", "kept"},
+		TransientStores: []string{"tkey"},
+	}
+	after := testapp.Config{
+		Stores:          []string{"new", "gone", "kept", "added"},
+		TransientStores: []string{"tkey", "tkey2"},
+		UpgradeHeight:   upgradeHeight,
+		Upgrades: storetypes.StoreUpgrades{
+			Added:   []string{"added"},
+			Renamed: []storetypes.StoreRename{{OldKey: "old", NewKey: "new"}},
+			// gone is still mounted and emptied, dropped is unmounted
+			Deleted: []string{"gone", "dropped"},
+		},
+	}
+
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	tx := func(msgs ...sdk.Msg) []byte {
+		bz, err := testapp.EncodeTx(txConfig, msgs...)
+		require.NoError(t, err)
+		return bz
+	}
+	set := func(store string, key string) sdk.Msg {
+		return &testapp.MsgSet{Store: store, Key: []byte(key), Value: []byte(store + "/" + key)}
+	}
+	blockTxs := func(h int64) types.Txs {
+		txs := types.Txs{tx(set("", fmt.Sprint(h)), set("tkey", fmt.Sprint(h)))}
+		if h < upgradeHeight {
+			for i := int64(0); i < 4; i++ {
+				key := fmt.Sprint(h*10 + i)
+				txs = append(txs, tx(set("old", key), set("gone", key), set("dropped", key), set("kept", key)))
+			}
+			return txs
+		}
+		return append(txs,
+			tx(set("added", fmt.Sprint(h)), set("tkey2", fmt.Sprint(h))),
+			tx(&testapp.MsgRemove{Store: "new", Key: []byte(fmt.Sprint(h*10 - 59))}, set("new", fmt.Sprint(h))),
+			tx(&testapp.MsgIterate{Store: "new", Start: []byte("2")}, set("gone", fmt.Sprint(h))),
+			tx(&testapp.MsgReverseIterate{Store: "kept", Limit: 3}),
+		)
+	}
+
+	// the app is restarted with the upgrades after the block below the upgrade
+	db := dbm.NewMemDB()
+	app, err := testapp.NewTestAppWithConfig(db, before)
+	require.NoError(t, err)
+	app.InitChain(abci.RequestInitChain{})
+	var server *ocserver.LocalOracleServer
+	appHashes := map[int64][]byte{}
+	for h := int64(1); h <= lastHeight; h++ {
+		if h == upgradeHeight {
+			app, err = testapp.NewTestAppWithConfig(db, after)
+			require.NoError(t, err)
+		}
+		block, err := testapp.ExecuteBlock(app, blockTxs(h), h)
+		require.NoError(t, err)
+		appHashes[h] = app.Commit().Data
+		if server == nil {
+			server = ocserver.NewLocalOracleServer(app, block, nil, nil)
+		} else {
+			server.AddBlock(block, nil, nil)
+		}
+		// the block of the upgrade height is executed on the state of the
+		// app before the upgrade
+		server.SetApp(app)
+	}
+
+	// renamed data is moved, deleted data is removed
+	for store, value := range map[string][]byte{"new": []byte("old/12"), "gone": nil, "kept": []byte("kept/12")} {
+		res := app.Query(abci.RequestQuery{Path: fmt.Sprintf("/store/%s/key", store), Data: []byte("12")})
+		require.True(t, res.IsOK(), res.Log)
+		require.Equal(t, value, res.Value, store)
+	}
+
+	for h := int64(3); h <= lastHeight; h++ {
+		t.Run(fmt.Sprintf("height %d", h), func(t *testing.T) {
+			cfg, opts := before, []Option{}
+			if h >= upgradeHeight {
+				cfg, opts = after, []Option{WithStoreUpgrades(upgradeHeight, after.Upgrades)}
+			}
+			newapp, err := testapp.NewTestAppWithConfig(dbm.NewMemDB(), cfg)
+			require.NoError(t, err)
+			client := occlient.NewLocalOracleClientAtHeight(server, h)
+			stateless, err := NewStatelessClient(newapp, client, opts...)
+			require.NoError(t, err)
+
+			executedAppHash, _, err := stateless.Execute(client.Block().Block, nil)
+			require.NoError(t, err)
+			require.Equal(t, appHashes[h], executedAppHash)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/simapp"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/types"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp/mockrpc"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+func TestWitnessStats(t *testing.T) {
+	chain, err := testapp.NewChain(9, 4)
+	require.NoError(t, err)
+	const height = 4
+	get, err := testapp.EncodeTx(simapp.MakeTestEncodingConfig().TxConfig, &testapp.MsgGet{Key: []byte{1}})
+	require.NoError(t, err)
+	for h := int64(1); h <= 5; h++ {
+		opts := testapp.BlockOptions{Txs: 8}
+		if h == height {
+			opts.Include = types.Txs{get}
+		}
+		_, err = chain.NextBlock(opts)
+		require.NoError(t, err)
+	}
+	mock := mockrpc.NewServer(chain)
+	defer mock.Close()
+	pool, err := ocserver.NewRPCPool([]string{mock.URL}, ocserver.DefaultRPCPoolConfig())
+	require.NoError(t, err)
+	basedir := t.TempDir()
+	server, err := ocserver.NewRPCOracleServerWithClient(height, hex.EncodeToString(chain.BlockID(height).Hash), pool, basedir)
+	require.NoError(t, err)
+
+	client := occlient.NewLocalOracleClientAtHeight(server, height)
+	rec := &witness.Recording{}
+	stateless := newStatelessClient(t, client, WithWitnessRecording(rec))
+	appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+	require.NoError(t, err)
+	require.Equal(t, []byte(chain.Block(height+1).AppHash), appHash)
+
+	// the recording holds every request of the execution
+	stats, err := witness.Analyze(rec)
+	require.NoError(t, err)
+	oracleStats := stateless.OracleStats()
+	require.Equal(t, int64(height), stats.Height)
+	require.Equal(t, int(oracleStats.Requests), stats.Total.Requests)
+	require.Equal(t, int(oracleStats.Bytes), stats.Total.Bytes)
+	require.Equal(t, stats.Total, *stats.Kinds["abci_query"])
+	require.Positive(t, stats.Stores["key2"].Nodes)
+	require.Positive(t, stats.Stores["key2"].MaxDepth)
+	require.Positive(t, stats.Phases["stateless_app"].Requests)
+	require.Positive(t, stats.Phases["deliver_tx/0"].Requests)
+	require.Positive(t, stats.Phases["commit"].Requests)
+	storeBytes := 0
+	for _, u := range stats.Stores {
+		storeBytes += u.Bytes
+	}
+	require.Equal(t, stats.Total.Bytes, storeBytes)
+
+	name := filepath.Join(t.TempDir(), "witness.json")
+	require.NoError(t, rec.WriteFile(name))
+	read, err := witness.Read(name)
+	require.NoError(t, err)
+	readStats, err := witness.Analyze(read)
+	require.NoError(t, err)
+	require.Equal(t, stats, readStats)
+
+	// the cache directory of the RPC oracle holds the same queries without
+	// phases, besides the blocks and validators
+	cached, err := witness.Read(fmt.Sprintf("%s/output/%d", basedir, height))
+	require.NoError(t, err)
+	cachedStats, err := witness.Analyze(cached)
+	require.NoError(t, err)
+	require.Equal(t, int64(height), cachedStats.Height)
+	require.Equal(t, stats.Stores["key2"].Nodes, cachedStats.Stores["key2"].Nodes)
+	require.Equal(t, []string{"unknown"}, keys(cachedStats.Phases))
+	require.Contains(t, cachedStats.Kinds, "block")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, stats.WriteTable(buf))
+	require.Contains(t, buf.String(), "deliver_tx/0")
+	require.Contains(t, buf.String(), "key2")
+}
+
+func keys(m map[string]*witness.Usage) []string {
+	res := []string{}
+	for key := range m {
+		res = append(res, key)
+	}
+	return res
+}
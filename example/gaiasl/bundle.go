@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ulbqb/cosmos-stateless-poc/example/gaiasl/exec"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+// bundle creates, merges, prunes and verifies self-contained execution
+// bundles.
+func bundle(args []string) error {
+	if len(args) > 0 && args[0] == "create" {
+		return createBundle(args[1:])
+	}
+	if len(args) > 0 && args[0] == "merge" {
+		return mergeBundles(args[1:])
+	}
+	if len(args) > 0 && args[0] == "prune-queries" {
+		return pruneBundleQueries(args[1:])
+	}
+	if len(args) > 0 && args[0] == "verify" {
+		return verifyBundle(args[1:])
+	}
+	return fmt.Errorf("usage: %s bundle create|merge|prune-queries|verify [flags]", os.Args[0])
+}
+
+func createBundle(args []string) error {
+	var basedir string
+	var trustHeight int
+	var trustBlockHash string
+	var rpcAddrs string
+	var dataDir string
+	var out string
+	var compress bool
+	var verbose bool
+	var initialHeight int64
+
+	fs := flag.NewFlagSet("bundle create", flag.ExitOnError)
+	fs.StringVar(&basedir, "basedir", "/tmp/stateless", "Directory to cache oracle data.")
+	fs.IntVar(&trustHeight, "height", 1, "Height of block to execute")
+	fs.StringVar(&trustBlockHash, "hash", "", "Hash of block to execute")
+	fs.StringVar(&rpcAddrs, "rpc", "http://localhost", "Comma-separated RPC hosts. Requests fail over to the next host on error.")
+	fs.StringVar(&dataDir, "data-dir", "", "Data directory of a stopped node. If set, oracle data is read from its databases instead of RPC and verified against -hash, which is then required.")
+	fs.StringVar(&out, "o", "", "Bundle file to write. Defaults to <height>.slb.")
+	fs.BoolVar(&compress, "zstd", true, "Compress the bundle with zstd.")
+	fs.BoolVar(&verbose, "verbose", false, "Log the execution to stderr.")
+	fs.Int64Var(&initialHeight, "initial-height", 1, "Initial height of the chain.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if out == "" {
+		out = fmt.Sprintf("%d.slb", trustHeight)
+	}
+	compression := witness.CompressionNone
+	if compress {
+		compression = witness.CompressionZstd
+	}
+
+	appHash, _, err := exec.Execute(exec.Config{
+		Basedir:           basedir,
+		TrustHeight:       trustHeight,
+		TrustBlockHash:    trustBlockHash,
+		RPCAddrs:          strings.Split(rpcAddrs, ","),
+		RPCPool:           ocserver.DefaultRPCPoolConfig(),
+		DataDir:           dataDir,
+		Logger:            newLogger(verbose),
+		InitialHeight:     initialHeight,
+		BundleFile:        out,
+		BundleCompression: compression,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%X\n", appHash)
+	return nil
+}
+
+func mergeBundles(args []string) error {
+	var out string
+	var compress bool
+
+	fs := flag.NewFlagSet("bundle merge", flag.ExitOnError)
+	fs.StringVar(&out, "o", "merged.slb", "Bundle file to write.")
+	fs.BoolVar(&compress, "zstd", true, "Compress the bundle with zstd.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: %s bundle merge [-o <file>] <bundle files of consecutive blocks>", os.Args[0])
+	}
+	compression := witness.CompressionNone
+	if compress {
+		compression = witness.CompressionZstd
+	}
+
+	bundles := []*witness.Bundle{}
+	for _, name := range fs.Args() {
+		m, err := witness.ReadMultiBundle(name)
+		if err != nil {
+			return err
+		}
+		for i := range m.Bundles {
+			bundles = append(bundles, m.Bundle(i))
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].Height < bundles[j].Height })
+	merged, err := witness.MergeBundles(bundles...)
+	if err != nil {
+		return err
+	}
+	return witness.WriteMultiBundle(out, merged, compression)
+}
+
+func pruneBundleQueries(args []string) error {
+	var trustBlockHash string
+	var out string
+	var compress bool
+	var verbose bool
+	var timeout time.Duration
+	var initialHeight int64
+
+	fs := flag.NewFlagSet("bundle prune-queries", flag.ExitOnError)
+	fs.StringVar(&trustBlockHash, "hash", "", "Trusted hash of the last block of the bundle (required).")
+	fs.StringVar(&out, "o", "pruned.slb", "Bundle file to write.")
+	fs.BoolVar(&compress, "zstd", true, "Compress the bundle with zstd.")
+	fs.BoolVar(&verbose, "verbose", false, "Log the executions to stderr.")
+	fs.DurationVar(&timeout, "timeout", 0, "If positive, abort an execution after this duration.")
+	fs.Int64Var(&initialHeight, "initial-height", 1, "Initial height of the chain.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || trustBlockHash == "" {
+		return fmt.Errorf("usage: %s bundle prune-queries -hash <block hash> [-o <file>] <bundle file>", os.Args[0])
+	}
+	compression := witness.CompressionNone
+	if compress {
+		compression = witness.CompressionZstd
+	}
+
+	bz, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	m, err := witness.DecodeMultiBundle(bz)
+	if err != nil {
+		return err
+	}
+	pruned, err := exec.PruneMultiBundleQueries(exec.Config{
+		TrustBlockHash: trustBlockHash,
+		Logger:         newLogger(verbose),
+		Timeout:        timeout,
+		InitialHeight:  initialHeight,
+	}, m)
+	if err != nil {
+		return err
+	}
+
+	queries, prunedQueries := 0, 0
+	for i := range m.Bundles {
+		queries += len(m.Bundles[i].Queries)
+		prunedQueries += len(pruned.Bundles[i].Queries)
+	}
+	var prunedBz []byte
+	if len(pruned.Bundles) == 1 {
+		prunedBz, err = witness.EncodeBundle(pruned.Bundle(0), compression)
+	} else {
+		prunedBz, err = witness.EncodeMultiBundle(pruned, compression)
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(out, prunedBz, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("%d of %d queries, %d of %d nodes, %d -> %d bytes\n",
+		prunedQueries, queries, len(pruned.Leaves)+len(pruned.InnerNodes), len(m.Leaves)+len(m.InnerNodes), len(bz), len(prunedBz))
+	return nil
+}
+
+func verifyBundle(args []string) error {
+	var trustBlockHash string
+	var verbose bool
+	var timeout time.Duration
+	var initialHeight int64
+
+	fs := flag.NewFlagSet("bundle verify", flag.ExitOnError)
+	fs.StringVar(&trustBlockHash, "hash", "", "Trusted hash of the last block of the bundle (required).")
+	fs.BoolVar(&verbose, "verbose", false, "Log the execution to stderr.")
+	fs.DurationVar(&timeout, "timeout", 0, "If positive, abort the execution after this duration.")
+	fs.Int64Var(&initialHeight, "initial-height", 1, "Initial height of the chain.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || trustBlockHash == "" {
+		return fmt.Errorf("usage: %s bundle verify -hash <block hash> <bundle file>", os.Args[0])
+	}
+
+	m, err := witness.ReadMultiBundle(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	appHashes, err := exec.VerifyMultiBundle(exec.Config{
+		TrustBlockHash: trustBlockHash,
+		Logger:         newLogger(verbose),
+		Timeout:        timeout,
+		InitialHeight:  initialHeight,
+	}, m)
+	if err != nil {
+		return err
+	}
+	if len(appHashes) == 1 {
+		fmt.Printf("%X\n", appHashes[0])
+		return nil
+	}
+	for i, appHash := range appHashes {
+		fmt.Printf("height %d: %X\n", m.Bundles[i].Height, appHash)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+// VerifyBundle executes the block of bundle, checking every response of the
+// bundle against cfg.TrustBlockHash, and returns the resulting app hash. It
+// fails if the app hash differs from the one claimed by the bundle.
+func VerifyBundle(cfg Config, bundle *witness.Bundle) ([]byte, error) {
+	appHash, _, err := executeBundle(cfg, bundle)
+	return appHash, err
+}
+
+// executeBundle verifies bundle like VerifyBundle and returns the server that
+// served the execution.
+func executeBundle(cfg Config, bundle *witness.Bundle) ([]byte, *ocserver.BundleOracleServer, error) {
+	trustHash, err := hex.DecodeString(cfg.TrustBlockHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := ocserver.NewBundleOracleServer(bundle)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := occlient.NewVerifyingOracleClient(server, bundle.Height, trustHash)
+
+	logger := cfg.logger()
+	gaia, closeGaia, err := cfg.app(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeGaia()
+	execCtx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(execCtx, cfg.Timeout)
+		defer cancel()
+	}
+	stateless, err := cfg.newStatelessClient(gaia, client, logger, execCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	appHash, _, err := stateless.Execute(client.Block().Block, client.Validators().Validators)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(appHash, bundle.AppHash) {
+		return appHash, nil, fmt.Errorf("app hash %X does not match the app hash %X of the bundle", appHash, bundle.AppHash)
+	}
+	return appHash, server, nil
+}
+
+// PruneBundleQueries verifies bundle like VerifyBundle while tracking the
+// queries the execution requests, and returns the bundle pruned to them with
+// witness.PruneQueries. The pruned bundle is verified again before it is
+// returned.
+func PruneBundleQueries(cfg Config, bundle *witness.Bundle) (*witness.Bundle, error) {
+	_, server, err := executeBundle(cfg, bundle)
+	if err != nil {
+		return nil, err
+	}
+	pruned, err := witness.PruneQueries(bundle, server.UsedQueries())
+	if err != nil {
+		return nil, err
+	}
+	if _, err = VerifyBundle(cfg, pruned); err != nil {
+		return nil, fmt.Errorf("pruned bundle: %w", err)
+	}
+	return pruned, nil
+}
+
+// VerifyMultiBundle verifies the blocks of m from the last one down to the
+// first one. cfg.TrustBlockHash is the hash of the last block, the hashes of
+// the others are the LastBlockIDs of the blocks above them. It returns the
+// app hashes in the order of the blocks.
+func VerifyMultiBundle(cfg Config, m *witness.MultiBundle) ([][]byte, error) {
+	cfg, closeGaia, err := cfg.withGaia()
+	if err != nil {
+		return nil, err
+	}
+	defer closeGaia()
+	appHashes := make([][]byte, len(m.Bundles))
+	for i := len(m.Bundles) - 1; i >= 0; i-- {
+		bundle := m.Bundle(i)
+		appHash, err := VerifyBundle(cfg, bundle)
+		if err != nil {
+			return nil, fmt.Errorf("height %d: %w", bundle.Height, err)
+		}
+		appHashes[i] = appHash
+		cfg.TrustBlockHash = hex.EncodeToString(bundle.Block.Header.LastBlockId.Hash)
+	}
+	return appHashes, nil
+}
+
+// PruneMultiBundleQueries prunes the blocks of m like PruneBundleQueries,
+// trusting their hashes like VerifyMultiBundle, and merges the pruned
+// bundles again.
+func PruneMultiBundleQueries(cfg Config, m *witness.MultiBundle) (*witness.MultiBundle, error) {
+	cfg, closeGaia, err := cfg.withGaia()
+	if err != nil {
+		return nil, err
+	}
+	defer closeGaia()
+	pruned := make([]*witness.Bundle, len(m.Bundles))
+	for i := len(m.Bundles) - 1; i >= 0; i-- {
+		bundle := m.Bundle(i)
+		if pruned[i], err = PruneBundleQueries(cfg, bundle); err != nil {
+			return nil, fmt.Errorf("height %d: %w", bundle.Height, err)
+		}
+		cfg.TrustBlockHash = hex.EncodeToString(bundle.Block.Header.LastBlockId.Hash)
+	}
+	return witness.MergeBundles(pruned...)
+}
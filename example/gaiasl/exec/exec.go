@@ -1,41 +1,221 @@
 package exec
 
 import (
+	"context"
+	"encoding/hex"
+	"fmt"
 	"io"
-	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
-	"github.com/cosmos/cosmos-sdk/client/flags"
-	csmsserver "github.com/cosmos/cosmos-sdk/server"
-	"github.com/cosmos/cosmos-sdk/store/types"
+	servertypes "github.com/cosmos/cosmos-sdk/server/types"
+	"github.com/cosmos/cosmos-sdk/snapshots"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/iavl"
 	"github.com/tendermint/tendermint/libs/log"
 	dbm "github.com/tendermint/tm-db"
 	"github.com/ulbqb/cosmos-stateless-poc/client"
 	slclient "github.com/ulbqb/cosmos-stateless-poc/client"
 	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
 	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
 )
 
-func Execute(basedir string, trustHeight int, trustBlockHash string, rpcAddr string) ([]byte, *client.ExecutionLog, error) {
+type Config struct {
+	Basedir        string
+	TrustHeight    int
+	TrustBlockHash string
+	RPCAddrs       []string
+	RPCPool        ocserver.RPCPoolConfig
+	// Quorum, if positive, sends every RPC request to all RPCAddrs and
+	// requires that many identical answers.
+	Quorum int
+	// PrefetchWorkers, if positive, enables a dry run against the oracle data
+	// cached in SpeculativeDir (by default the one of the previous height) and
+	// fetches the discovered keys with that many workers before execution.
+	PrefetchWorkers int
+	SpeculativeDir  string
+	// PrefetchBatchSize is the number of ABCI queries sent in one JSON-RPC batch while prefetching.
+	PrefetchBatchSize int
+	// DataDir, if set, is the data directory of a stopped node whose
+	// databases are used instead of RPC.
+	DataDir string
+	// SnapshotDir, if set, is a state-sync snapshot directory holding a
+	// snapshot of the previous height, which then serves the ABCI queries.
+	SnapshotDir    string
+	SnapshotFormat uint32
+	// Logger defaults to discarding logs.
+	Logger log.Logger
+	// Timeout, MaxOracleRequests and MaxWitnessBytes bound an execution if
+	// positive.
+	Timeout           time.Duration
+	MaxOracleRequests uint64
+	MaxWitnessBytes   uint64
+	InitialHeight     int64
+	// RecordFile, if set, is the file the witness of the execution is
+	// written to.
+	RecordFile string
+	// BundleFile, if set, is the file a bundle of the execution is written
+	// to.
+	BundleFile        string
+	BundleCompression witness.Compression
+	// NodeStore shares the proof nodes of all heights in <Basedir>/nodes and
+	// answers node queries from them where possible instead of fetching
+	// them.
+	NodeStore bool
+	// Gaia, if set, is the app of the executions. Otherwise every execution
+	// creates its own app and removes it afterwards.
+	Gaia *Gaia
+}
+
+func newRPCClient(cfg Config) (ocserver.RPCClient, error) {
+	if cfg.Quorum <= 0 {
+		return ocserver.NewRPCPool(cfg.RPCAddrs, cfg.RPCPool)
+	}
+
+	providers := []ocserver.RPCProvider{}
+	for _, addr := range cfg.RPCAddrs {
+		pool, err := ocserver.NewRPCPool([]string{addr}, cfg.RPCPool)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, ocserver.RPCProvider{Name: addr, Client: pool})
+	}
+	quorum, err := ocserver.NewQuorumRPC(providers, cfg.Quorum)
+	if err != nil {
+		return nil, err
+	}
+	quorum.SetDivergenceHandler(func(e *ocserver.QuorumError) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", e)
+	})
+	return quorum, nil
+}
+
+// openNodeStore opens the node store of cfg, or returns nil if it is
+// disabled.
+func openNodeStore(cfg Config) (*witness.NodeStore, error) {
+	if !cfg.NodeStore {
+		return nil, nil
+	}
+	db, err := dbm.NewGoLevelDB("nodes", cfg.Basedir)
+	if err != nil {
+		return nil, err
+	}
+	return witness.NewNodeStore(db), nil
+}
+
+// newOracleServer returns the oracle server of cfg and a function closing
+// the databases it opened.
+func newOracleServer(cfg Config, nodes *witness.NodeStore) (ocserver.OracleServer, func(), error) {
+	var server ocserver.OracleServer
+	closeServer := func() {}
+	if cfg.DataDir != "" {
+		dbServer, err := ocserver.NewDBOracleServer(cfg.DataDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		server, closeServer = dbServer, func() { dbServer.Close() }
+	} else {
+		// setup rpc client
+		rpc, err := newRPCClient(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		rpcServer, err := ocserver.NewRPCOracleServerWithClient(cfg.TrustHeight, cfg.TrustBlockHash, rpc, cfg.Basedir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if nodes != nil {
+			rpcServer.SetNodeStore(nodes)
+		}
+		server = rpcServer
+	}
+	if cfg.SnapshotDir == "" {
+		return server, closeServer, nil
+	}
+
+	// the snapshot is restored in memory by NewSnapshotOracleServer, its
+	// store is not needed afterwards
+	snapshotDB, err := sdk.NewLevelDB("metadata", cfg.SnapshotDir)
+	if err != nil {
+		closeServer()
+		return nil, nil, err
+	}
+	defer snapshotDB.Close()
+	snapshotStore, err := snapshots.NewStore(snapshotDB, cfg.SnapshotDir)
+	if err != nil {
+		closeServer()
+		return nil, nil, err
+	}
+	snapshotServer, err := ocserver.NewSnapshotOracleServer(snapshotStore, uint64(cfg.TrustHeight-1), cfg.SnapshotFormat, dbm.NewMemDB(), nil, server)
+	if err != nil {
+		closeServer()
+		return nil, nil, err
+	}
+	return snapshotServer, closeServer, nil
+}
+
+// oracleClient is the client of an execution, which also prefetches for it
+// and is the block source of its bundle.
+type oracleClient interface {
+	iavl.OracleClientI
+	slclient.Prefetcher
+	witness.BlockSource
+}
+
+func Execute(cfg Config) ([]byte, *client.ExecutionLog, error) {
+	if cfg.DataDir != "" && cfg.TrustBlockHash == "" {
+		return nil, nil, fmt.Errorf("the hash of the block is required with a data directory")
+	}
+
 	// setup oracle server
-	server, err := ocserver.NewRPCOracleServer(trustHeight, trustBlockHash, rpcAddr, basedir)
+	nodes, err := openNodeStore(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if nodes != nil {
+		defer nodes.Close()
+	}
+	server, closeServer, err := newOracleServer(cfg, nodes)
 	if err != nil {
 		return nil, nil, err
 	}
+	defer closeServer()
 
 	// setup oracle client
-	client := occlient.NewLocalOracleClient(server)
+	prefetch := ocserver.NewPrefetchOracleServer(server)
+	prefetch.SetBatchSize(cfg.PrefetchBatchSize)
+	var client oracleClient = occlient.NewLocalOracleClientAtHeight(prefetch, int64(cfg.TrustHeight))
+	if cfg.DataDir != "" {
+		// the databases are served as they are, so every response is checked
+		// against the trusted hash like RPCOracleServer checks them
+		trustHash, err := hex.DecodeString(cfg.TrustBlockHash)
+		if err != nil {
+			return nil, nil, err
+		}
+		client = occlient.NewVerifyingOracleClient(prefetch, int64(cfg.TrustHeight), trustHash)
+	}
 
 	// setup stateless client
-	db := dbm.NewMemDB()
-	tempDir, err := ioutil.TempDir("/tmp", "gaiasl")
+	logger := cfg.logger()
+	gaia, closeGaia, err := cfg.app(logger)
 	if err != nil {
 		return nil, nil, err
 	}
-	ctx := csmsserver.NewDefaultContext()
-	ctx.Viper.Set(flags.FlagHome, tempDir)
-	ctx.Viper.Set(csmsserver.FlagPruning, types.PruningOptionNothing)
-	gaia := newApp(log.NewTMLogger(log.NewSyncWriter(io.Discard)), db, nil, ctx.Viper)
-	stateless, err := slclient.NewStatelessClient(gaia, client)
+	defer closeGaia()
+	execCtx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(execCtx, cfg.Timeout)
+		defer cancel()
+	}
+	var recording *witness.Recording
+	if cfg.RecordFile != "" || cfg.BundleFile != "" {
+		recording = &witness.Recording{}
+	}
+	stateless, err := cfg.newStatelessClient(gaia, client, logger, execCtx, slclient.WithWitnessRecording(recording))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -43,9 +223,111 @@ func Execute(basedir string, trustHeight int, trustBlockHash string, rpcAddr str
 	// execute stateless
 	resultBlock := client.Block()
 	resultVals := client.Validators()
-	appHash, log, err := stateless.Execute(resultBlock.Block, resultVals.Validators)
+	speculative := newSpeculativeClient(cfg)
+	var appHash []byte
+	var log slclient.ExecutionLog
+	if speculative != nil {
+		appHash, log, err = stateless.ExecuteWithPrefetch(resultBlock.Block, resultVals.Validators, speculative, client, cfg.PrefetchWorkers)
+	} else {
+		appHash, log, err = stateless.Execute(resultBlock.Block, resultVals.Validators)
+	}
 	if err != nil {
 		return nil, &log, err
 	}
+	if cfg.RecordFile != "" {
+		if err = recording.WriteFile(cfg.RecordFile); err != nil {
+			return nil, &log, err
+		}
+	}
+	if cfg.BundleFile != "" {
+		bundle, err := witness.NewBundle(client, recording, appHash)
+		if err != nil {
+			return nil, &log, err
+		}
+		if err = witness.WriteBundle(cfg.BundleFile, bundle, cfg.BundleCompression); err != nil {
+			return nil, &log, err
+		}
+	}
 	return appHash, &log, nil
 }
+
+func (cfg Config) logger() log.Logger {
+	if cfg.Logger == nil {
+		return log.NewTMLogger(log.NewSyncWriter(io.Discard))
+	}
+	return cfg.Logger
+}
+
+// PruneOutputs removes the oracle caches <basedir>/output/<height> of the
+// heights below height.
+func PruneOutputs(basedir string, height int64) error {
+	dir := filepath.Join(basedir, "output")
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		h, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil || h >= height {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// app returns the app of cfg.Gaia, or a new one and a function removing it.
+func (cfg Config) app(logger log.Logger) (servertypes.Application, func(), error) {
+	if cfg.Gaia != nil {
+		return cfg.Gaia.App(), func() {}, nil
+	}
+	gaia, err := NewGaia(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gaia.App(), func() { gaia.Close() }, nil
+}
+
+// withGaia returns cfg with an app shared by its executions and a function
+// removing the app if it was created for them.
+func (cfg Config) withGaia() (Config, func(), error) {
+	if cfg.Gaia != nil {
+		return cfg, func() {}, nil
+	}
+	gaia, err := NewGaia(cfg.logger())
+	if err != nil {
+		return cfg, nil, err
+	}
+	cfg.Gaia = gaia
+	return cfg, func() { gaia.Close() }, nil
+}
+
+func (cfg Config) newStatelessClient(app servertypes.Application, oracle iavl.OracleClientI, logger log.Logger, ctx context.Context, opts ...slclient.Option) (*slclient.StatelessClient, error) {
+	return slclient.NewStatelessClient(app, oracle, append([]slclient.Option{
+		slclient.WithLogger(logger),
+		slclient.WithContext(ctx),
+		slclient.WithMaxOracleRequests(cfg.MaxOracleRequests),
+		slclient.WithMaxWitnessBytes(cfg.MaxWitnessBytes),
+		slclient.WithInitialHeight(cfg.InitialHeight),
+	}, opts...)...)
+}
+
+func newSpeculativeClient(cfg Config) *occlient.LocalOracleClient {
+	if cfg.PrefetchWorkers <= 0 {
+		return nil
+	}
+	dir := cfg.SpeculativeDir
+	if dir == "" {
+		dir = fmt.Sprintf("%s/output/%d", cfg.Basedir, cfg.TrustHeight-1)
+	}
+	server, err := ocserver.NewSpeculativeOracleServer(dir)
+	if err != nil {
+		// nothing to speculate with, execute without prefetching
+		return nil
+	}
+	return occlient.NewLocalOracleClient(server)
+}
@@ -2,6 +2,7 @@ package exec
 
 import (
 	"io"
+	"os"
 	"path/filepath"
 
 	"github.com/cosmos/cosmos-sdk/baseapp"
@@ -10,6 +11,7 @@ import (
 	servertypes "github.com/cosmos/cosmos-sdk/server/types"
 	"github.com/cosmos/cosmos-sdk/snapshots"
 	"github.com/cosmos/cosmos-sdk/store"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/spf13/cast"
 	"github.com/tendermint/tendermint/libs/log"
@@ -18,6 +20,37 @@ import (
 	gaia "github.com/cosmos/gaia/v10/app"
 )
 
+// Gaia is a gaia app for stateless executions with its home in a temporary
+// directory. The executions of a long-running command share one Gaia, as the
+// app is not changed by them.
+type Gaia struct {
+	app  servertypes.Application
+	home string
+}
+
+func NewGaia(logger log.Logger) (*Gaia, error) {
+	home, err := os.MkdirTemp("", "gaiasl")
+	if err != nil {
+		return nil, err
+	}
+	ctx := server.NewDefaultContext()
+	ctx.Viper.Set(flags.FlagHome, home)
+	ctx.Viper.Set(server.FlagPruning, storetypes.PruningOptionNothing)
+	return &Gaia{
+		app:  newApp(logger, dbm.NewMemDB(), nil, ctx.Viper),
+		home: home,
+	}, nil
+}
+
+func (g *Gaia) App() servertypes.Application {
+	return g.app
+}
+
+// Close removes the home directory of the app.
+func (g *Gaia) Close() error {
+	return os.RemoveAll(g.home)
+}
+
 func newApp(
 	logger log.Logger,
 	db dbm.DB,
@@ -40,12 +73,10 @@ func newApp(
 		panic(err)
 	}
 
+	// stateless executions take no snapshots, so the snapshot metadata is
+	// kept in memory instead of a database that would have to be closed
 	snapshotDir := filepath.Join(cast.ToString(appOpts.Get(flags.FlagHome)), "data", "snapshots")
-	snapshotDB, err := sdk.NewLevelDB("metadata", snapshotDir)
-	if err != nil {
-		panic(err)
-	}
-	snapshotStore, err := snapshots.NewStore(snapshotDB, snapshotDir)
+	snapshotStore, err := snapshots.NewStore(dbm.NewMemDB(), snapshotDir)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,101 @@
+package exec
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	occlient "github.com/ulbqb/cosmos-stateless-poc/oracle/client"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/verifier"
+)
+
+// ExecuteRange executes the blocks of heights concurrently with the given
+// number of workers and compares each app hash with the one in the header of
+// the next block. cfg.TrustHeight and cfg.TrustBlockHash must name a block
+// above all heights; the hashes of the other blocks are derived from it.
+// Every worker has its own gaia app, all of them share one oracle server and
+// its cache, and the node store if cfg.NodeStore is set. onResult is called
+// with the results in the order of heights.
+func ExecuteRange(ctx context.Context, cfg Config, heights []int64, workers int, onResult func(verifier.Result)) ([]verifier.Result, verifier.RangeStats, error) {
+	for _, h := range heights {
+		if h >= int64(cfg.TrustHeight) {
+			return nil, verifier.RangeStats{}, fmt.Errorf("height %d is not below trusted height %d", h, cfg.TrustHeight)
+		}
+	}
+	rpc, err := newRPCClient(cfg)
+	if err != nil {
+		return nil, verifier.RangeStats{}, err
+	}
+	trustHash, err := hex.DecodeString(cfg.TrustBlockHash)
+	if err != nil {
+		return nil, verifier.RangeStats{}, err
+	}
+	server := ocserver.NewHeightAgnosticRPCOracleServer(rpc, cfg.Basedir)
+	if err = server.Trust(int64(cfg.TrustHeight), trustHash); err != nil {
+		return nil, verifier.RangeStats{}, err
+	}
+	// derive the hashes of all heights at once rather than in every worker
+	lowest := int64(cfg.TrustHeight)
+	executed := map[int64]bool{}
+	for _, h := range heights {
+		if h < lowest {
+			lowest = h
+		}
+		executed[h] = true
+	}
+	if err = server.TrustDownTo(lowest); err != nil {
+		return nil, verifier.RangeStats{}, err
+	}
+	nodes, err := openNodeStore(cfg)
+	if err != nil {
+		return nil, verifier.RangeStats{}, err
+	}
+	if nodes != nil {
+		defer nodes.Close()
+		server.SetNodeStore(nodes)
+	}
+
+	logger := cfg.logger()
+	gaias := []*Gaia{}
+	defer func() {
+		for _, gaia := range gaias {
+			gaia.Close()
+		}
+	}()
+	newWorker := func() (verifier.HeightFunc, error) {
+		gaia, err := NewGaia(logger)
+		if err != nil {
+			return nil, err
+		}
+		gaias = append(gaias, gaia)
+		return func(height int64) ([]byte, []byte, error) {
+			next, err := server.VerifiedBlock(height + 1)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			execCtx := ctx
+			if cfg.Timeout > 0 {
+				var cancel context.CancelFunc
+				execCtx, cancel = context.WithTimeout(execCtx, cfg.Timeout)
+				defer cancel()
+			}
+			client := occlient.NewLocalOracleClientAtHeight(server, height)
+			stateless, err := cfg.newStatelessClient(gaia.App(), client, logger, execCtx)
+			if err != nil {
+				return nil, nil, err
+			}
+			resultBlock := client.Block()
+			resultVals := client.Validators()
+			appHash, _, err := stateless.Execute(resultBlock.Block, resultVals.Validators)
+			// the blocks stay trusted, their data can be fetched again from the disk cache
+			server.Forget(height)
+			if !executed[height+1] {
+				server.Forget(height + 1)
+			}
+			return appHash, next.Block.AppHash, err
+		}, nil
+	}
+	return verifier.RunRange(ctx, heights, workers, newWorker, onResult)
+}
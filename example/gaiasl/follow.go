@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/ulbqb/cosmos-stateless-poc/example/gaiasl/exec"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/verifier"
+)
+
+// follow verifies every new block of the chain until it is interrupted.
+func follow(args []string) error {
+	var basedir string
+	var rpcAddrs string
+	var checkpoint string
+	var quorum int
+	var prefetchWorkers int
+	var prefetchBatchSize int
+	var metricsAddr string
+	var nodeStore bool
+	var timeout time.Duration
+	var maxOracleRequests uint64
+	var maxWitnessBytes uint64
+	var retry verifier.RetryPolicy
+	poolConfig := ocserver.DefaultRPCPoolConfig()
+
+	fs := flag.NewFlagSet("follow", flag.ExitOnError)
+	fs.StringVar(&basedir, "basedir", "/tmp/stateless", "Directory to cache oracle data.")
+	fs.StringVar(&rpcAddrs, "rpc", "http://localhost", "Comma-separated RPC hosts. New blocks are subscribed from the first one.")
+	fs.StringVar(&checkpoint, "checkpoint", "", "File recording the last verified height. Defaults to <basedir>/follow.json.")
+	fs.IntVar(&poolConfig.MaxRetries, "rpc-retries", poolConfig.MaxRetries, "Number of retries for a failed RPC request.")
+	fs.DurationVar(&poolConfig.RequestTimeout, "rpc-timeout", poolConfig.RequestTimeout, "Timeout of a single RPC request.")
+	fs.IntVar(&quorum, "quorum", 0, "If positive, query every RPC host and require this many identical responses.")
+	fs.IntVar(&prefetchWorkers, "prefetch-workers", 0, "If positive, dry-run each block against the oracle data of the previous height and prefetch the accessed keys with this many workers.")
+	fs.IntVar(&prefetchBatchSize, "prefetch-batch", 1, "Number of ABCI queries sent in one JSON-RPC batch while prefetching.")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "If set, serve Prometheus metrics on this address at /metrics.")
+	fs.BoolVar(&nodeStore, "node-store", false, "Store the proof nodes of all heights by hash in <basedir>/nodes and serve node queries from there where possible.")
+	fs.DurationVar(&timeout, "timeout", 0, "If positive, abort the execution of a height after this duration.")
+	fs.Uint64Var(&maxOracleRequests, "max-oracle-requests", 0, "If positive, abort the execution of a height after this many oracle requests.")
+	fs.Uint64Var(&maxWitnessBytes, "max-witness-bytes", 0, "If positive, abort the execution of a height after this many bytes of oracle responses.")
+	fs.IntVar(&retry.MaxAttempts, "max-attempts", 0, "If positive, give up a height after this many failed executions. Zero retries forever.")
+	fs.DurationVar(&retry.Backoff, "retry-backoff", 0, "If positive, execute a failed height again after this duration, doubled for every further attempt. Otherwise it is executed again with the next block.")
+	fs.DurationVar(&retry.MaxBackoff, "max-retry-backoff", 5*time.Minute, "Upper bound of -retry-backoff.")
+	fs.BoolVar(&retry.Skip, "skip-failed", false, "Skip a given up height, record it in the checkpoint and continue, instead of exiting with an error.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	serveMetrics(metricsAddr)
+	if checkpoint == "" {
+		checkpoint = filepath.Join(basedir, "follow.json")
+	}
+
+	addrs := strings.Split(rpcAddrs, ",")
+	chain, err := verifier.NewRPCChain(addrs[0])
+	if err != nil {
+		return err
+	}
+	defer chain.Stop()
+
+	// all blocks are executed by one app
+	gaia, err := exec.NewGaia(log.NewNopLogger())
+	if err != nil {
+		return err
+	}
+	defer gaia.Close()
+	execute := func(height int64, blockHash []byte) ([]byte, error) {
+		appHash, _, err := exec.Execute(exec.Config{
+			Basedir:           basedir,
+			TrustHeight:       int(height),
+			TrustBlockHash:    hex.EncodeToString(blockHash),
+			RPCAddrs:          addrs,
+			RPCPool:           poolConfig,
+			Quorum:            quorum,
+			PrefetchWorkers:   prefetchWorkers,
+			PrefetchBatchSize: prefetchBatchSize,
+			NodeStore:         nodeStore,
+			Timeout:           timeout,
+			MaxOracleRequests: maxOracleRequests,
+			MaxWitnessBytes:   maxWitnessBytes,
+			Gaia:              gaia,
+		})
+		return appHash, err
+	}
+	follower := verifier.NewFollower(chain, execute, checkpoint)
+	follower.SetRetryPolicy(retry)
+	// the cache of the verified height is kept for the dry run of the next
+	follower.SetCheckpointHandler(func(height int64) {
+		if err := exec.PruneOutputs(basedir, height); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	})
+	follower.SetResultHandler(func(r verifier.Result) {
+		switch {
+		case r.Skipped:
+			fmt.Fprintf(os.Stderr, "height %d: skipped after %d attempts: %v\n", r.Height, r.Attempt, r.Err)
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "height %d: execution failed (attempt %d): %v\n", r.Height, r.Attempt, r.Err)
+		case r.Mismatch():
+			fmt.Fprintf(os.Stderr, "height %d: app hash mismatch: executed %X, expected %X\n", r.Height, r.Executed, r.Expected)
+		default:
+			fmt.Printf("height %d: %X (%s)\n", r.Height, r.Executed, r.Duration)
+		}
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if err = follower.Run(ctx); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -3,27 +3,119 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/tendermint/tendermint/libs/log"
 	"github.com/ulbqb/cosmos-stateless-poc/example/gaiasl/exec"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
 )
 
 // It is assumed that Hash of block to execute is correct.
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "follow" {
+		if err := follow(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "range" {
+		if err := verifyRange(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		if err := bundle(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		if err := stats(os.Args[2:]); err != nil {
+			panic(err)
+		}
+		return
+	}
+
 	var basedir string
 	var trustHeight int
 	var trustBlockHash string
-	var rpcAddr string
+	var rpcAddrs string
+	var quorum int
+	var prefetchWorkers int
+	var speculativeDir string
+	var prefetchBatchSize int
+	var dataDir string
+	var snapshotDir string
+	var snapshotFormat uint
+	var metricsAddr string
+	var verbose bool
+	var timeout time.Duration
+	var maxOracleRequests uint64
+	var maxWitnessBytes uint64
+	var initialHeight int64
+	var recordFile string
+	var nodeStore bool
+	poolConfig := ocserver.DefaultRPCPoolConfig()
 
 	flag.StringVar(&basedir, "basedir", "/tmp/stateless", "Directory to cache oracle data.")
 	flag.IntVar(&trustHeight, "height", 1, "Height of block to execute")
 	flag.StringVar(&trustBlockHash, "hash", "", "Hash of block to execute")
-	flag.StringVar(&rpcAddr, "rpc", "http://localhost", "RPC host.")
+	flag.StringVar(&rpcAddrs, "rpc", "http://localhost", "Comma-separated RPC hosts. Requests fail over to the next host on error.")
+	flag.IntVar(&poolConfig.MaxRetries, "rpc-retries", poolConfig.MaxRetries, "Number of retries for a failed RPC request.")
+	flag.DurationVar(&poolConfig.RequestTimeout, "rpc-timeout", poolConfig.RequestTimeout, "Timeout of a single RPC request.")
+	flag.IntVar(&quorum, "quorum", 0, "If positive, query every RPC host and require this many identical responses.")
+	flag.IntVar(&prefetchWorkers, "prefetch-workers", 0, "If positive, dry-run the block against cached oracle data and prefetch the accessed keys with this many workers.")
+	flag.StringVar(&speculativeDir, "speculative-dir", "", "Oracle cache directory used for the dry run. Defaults to the one of the previous height.")
+	flag.IntVar(&prefetchBatchSize, "prefetch-batch", 1, "Number of ABCI queries sent in one JSON-RPC batch while prefetching.")
+	flag.StringVar(&dataDir, "data-dir", "", "Data directory of a stopped node. If set, oracle data is read from its databases instead of RPC and verified against -hash, which is then required.")
+	flag.StringVar(&snapshotDir, "snapshot-dir", "", "State-sync snapshot directory (data/snapshots of a node) holding a snapshot of the previous height. If set, ABCI queries are served from the restored snapshot.")
+	flag.UintVar(&snapshotFormat, "snapshot-format", uint(snapshottypes.CurrentFormat), "Format of the snapshot.")
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "If set, serve Prometheus metrics on this address at /metrics.")
+	flag.BoolVar(&verbose, "verbose", false, "Log the execution to stderr.")
+	flag.DurationVar(&timeout, "timeout", 0, "If positive, abort the execution after this duration.")
+	flag.Uint64Var(&maxOracleRequests, "max-oracle-requests", 0, "If positive, abort the execution after this many oracle requests.")
+	flag.Uint64Var(&maxWitnessBytes, "max-witness-bytes", 0, "If positive, abort the execution after this many bytes of oracle responses.")
+	flag.Int64Var(&initialHeight, "initial-height", 1, "Initial height of the chain.")
+	flag.StringVar(&recordFile, "record", "", "If set, write the witness of the execution to this file, e.g. for the stats command.")
+	flag.BoolVar(&nodeStore, "node-store", false, "Store the proof nodes of all heights by hash in <basedir>/nodes and serve node queries from there where possible.")
 	flag.Parse()
+	serveMetrics(metricsAddr)
 
-	appHash, _, err := exec.Execute(basedir, trustHeight, trustBlockHash, rpcAddr)
+	appHash, _, err := exec.Execute(exec.Config{
+		Basedir:           basedir,
+		TrustHeight:       trustHeight,
+		TrustBlockHash:    trustBlockHash,
+		RPCAddrs:          strings.Split(rpcAddrs, ","),
+		RPCPool:           poolConfig,
+		Quorum:            quorum,
+		PrefetchWorkers:   prefetchWorkers,
+		SpeculativeDir:    speculativeDir,
+		PrefetchBatchSize: prefetchBatchSize,
+		DataDir:           dataDir,
+		SnapshotDir:       snapshotDir,
+		SnapshotFormat:    uint32(snapshotFormat),
+		Logger:            newLogger(verbose),
+		Timeout:           timeout,
+		MaxOracleRequests: maxOracleRequests,
+		MaxWitnessBytes:   maxWitnessBytes,
+		InitialHeight:     initialHeight,
+		RecordFile:        recordFile,
+		NodeStore:         nodeStore,
+	})
 	if err != nil {
 		panic(err)
 	}
 
 	fmt.Printf("%X\n", appHash)
 }
+
+func newLogger(verbose bool) log.Logger {
+	if !verbose {
+		return log.NewNopLogger()
+	}
+	return log.NewTMLogger(log.NewSyncWriter(os.Stderr))
+}
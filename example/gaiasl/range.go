@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ulbqb/cosmos-stateless-poc/example/gaiasl/exec"
+	ocserver "github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	"github.com/ulbqb/cosmos-stateless-poc/verifier"
+)
+
+// verifyRange executes a range or list of heights concurrently and prints
+// the results in order.
+func verifyRange(args []string) error {
+	var basedir string
+	var from, to int64
+	var heightList string
+	var trustHeight int
+	var trustBlockHash string
+	var rpcAddrs string
+	var quorum int
+	var workers int
+	var metricsAddr string
+	var nodeStore bool
+	var timeout time.Duration
+	var maxOracleRequests uint64
+	var maxWitnessBytes uint64
+	poolConfig := ocserver.DefaultRPCPoolConfig()
+
+	fs := flag.NewFlagSet("range", flag.ExitOnError)
+	fs.StringVar(&basedir, "basedir", "/tmp/stateless", "Directory to cache oracle data.")
+	fs.Int64Var(&from, "from", 0, "First height to execute.")
+	fs.Int64Var(&to, "to", 0, "Last height to execute.")
+	fs.StringVar(&heightList, "heights", "", "Comma-separated heights to execute instead of -from and -to.")
+	fs.IntVar(&trustHeight, "trust-height", 0, "Height of the trusted block. Defaults to one above the highest height.")
+	fs.StringVar(&trustBlockHash, "hash", "", "Hash of the trusted block.")
+	fs.StringVar(&rpcAddrs, "rpc", "http://localhost", "Comma-separated RPC hosts. Requests fail over to the next host on error.")
+	fs.IntVar(&poolConfig.MaxRetries, "rpc-retries", poolConfig.MaxRetries, "Number of retries for a failed RPC request.")
+	fs.DurationVar(&poolConfig.RequestTimeout, "rpc-timeout", poolConfig.RequestTimeout, "Timeout of a single RPC request.")
+	fs.IntVar(&quorum, "quorum", 0, "If positive, query every RPC host and require this many identical responses.")
+	fs.IntVar(&workers, "workers", 4, "Number of concurrent executions.")
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "If set, serve Prometheus metrics on this address at /metrics.")
+	fs.BoolVar(&nodeStore, "node-store", false, "Store the proof nodes of all heights by hash in <basedir>/nodes and serve node queries from there where possible.")
+	fs.DurationVar(&timeout, "timeout", 0, "If positive, abort the execution of a height after this duration.")
+	fs.Uint64Var(&maxOracleRequests, "max-oracle-requests", 0, "If positive, abort the execution of a height after this many oracle requests.")
+	fs.Uint64Var(&maxWitnessBytes, "max-witness-bytes", 0, "If positive, abort the execution of a height after this many bytes of oracle responses.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	serveMetrics(metricsAddr)
+
+	heights, err := parseHeights(from, to, heightList)
+	if err != nil {
+		return err
+	}
+	if trustHeight == 0 {
+		trustHeight = int(heights[len(heights)-1]) + 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	_, stats, err := exec.ExecuteRange(ctx, exec.Config{
+		Basedir:           basedir,
+		TrustHeight:       trustHeight,
+		TrustBlockHash:    trustBlockHash,
+		RPCAddrs:          strings.Split(rpcAddrs, ","),
+		RPCPool:           poolConfig,
+		Quorum:            quorum,
+		NodeStore:         nodeStore,
+		Timeout:           timeout,
+		MaxOracleRequests: maxOracleRequests,
+		MaxWitnessBytes:   maxWitnessBytes,
+	}, heights, workers, func(r verifier.Result) {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("height %d: execution failed: %v\n", r.Height, r.Err)
+		case r.Mismatch():
+			fmt.Printf("height %d: app hash mismatch: executed %X, expected %X\n", r.Height, r.Executed, r.Expected)
+		default:
+			fmt.Printf("height %d: %X (%s)\n", r.Height, r.Executed, r.Duration)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d blocks, %d mismatches, %d failures in %s (%.2f blocks/s)\n",
+		stats.Blocks, stats.Mismatches, stats.Failures, stats.Elapsed, stats.BlocksPerSecond())
+	if stats.Mismatches > 0 || stats.Failures > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// parseHeights returns the sorted heights of list, or of from to to if list
+// is empty.
+func parseHeights(from, to int64, list string) ([]int64, error) {
+	heights := []int64{}
+	if list != "" {
+		for _, s := range strings.Split(list, ",") {
+			h, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid height %q: %w", s, err)
+			}
+			heights = append(heights, h)
+		}
+		sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	} else {
+		for h := from; h <= to; h++ {
+			heights = append(heights, h)
+		}
+	}
+	if len(heights) == 0 {
+		return nil, fmt.Errorf("no heights given, set -from and -to or -heights")
+	}
+	if heights[0] < 1 {
+		return nil, fmt.Errorf("invalid height %d", heights[0])
+	}
+	return heights, nil
+}
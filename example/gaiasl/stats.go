@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+// stats prints the size of a witness by request kind, store and phase.
+func stats(args []string) error {
+	var asJSON bool
+
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.BoolVar(&asJSON, "json", false, "Print the statistics as JSON.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s stats [-json] <recording file | cache directory>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected a recording file or a cache directory")
+	}
+
+	recording, err := witness.Read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s, err := witness.Analyze(recording)
+	if err != nil {
+		return err
+	}
+	if !asJSON {
+		return s.WriteTable(os.Stdout)
+	}
+	bz, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(bz))
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package metrics holds the Prometheus metrics of stateless execution.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "stateless"
+
+var (
+	OracleRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "oracle",
+		Name:      "requests_total",
+		Help:      "Number of oracle requests by server and kind.",
+	}, []string{"server", "kind"})
+
+	CacheHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "oracle",
+		Name:      "cache_hits_total",
+		Help:      "Number of oracle cache hits by cache.",
+	}, []string{"cache"})
+
+	CacheMisses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "oracle",
+		Name:      "cache_misses_total",
+		Help:      "Number of oracle cache misses by cache.",
+	}, []string{"cache"})
+
+	BytesFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "oracle",
+		Name:      "fetched_bytes_total",
+		Help:      "Bytes of RPC responses fetched by method.",
+	}, []string{"method"})
+
+	VerificationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "oracle",
+		Name:      "verification_seconds",
+		Help:      "Time spent verifying oracle data against the trusted block hash.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+	}, []string{"target"})
+
+	PhaseSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "execution",
+		Name:      "phase_seconds",
+		Help:      "Time spent in each phase of a stateless execution.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"phase"})
+
+	AppHashMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "execution",
+		Name:      "app_hash_mismatches_total",
+		Help:      "Number of executed blocks whose app hash differs from the next header.",
+	})
+)
+
+// Registry holds all metrics of this package.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		OracleRequests,
+		CacheHits,
+		CacheMisses,
+		BytesFetched,
+		VerificationSeconds,
+		PhaseSeconds,
+		AppHashMismatches,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
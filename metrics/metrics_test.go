@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	AppHashMismatches.Inc()
+	OracleRequests.WithLabelValues("rpc", "block").Inc()
+	VerificationSeconds.WithLabelValues("block").Observe(0.001)
+
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	res, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, 200, res.StatusCode)
+	require.Contains(t, res.Header.Get("Content-Type"), "text/plain")
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "stateless_execution_app_hash_mismatches_total 1")
+	require.Contains(t, string(body), `stateless_oracle_requests_total{kind="block",server="rpc"} 1`)
+	require.Contains(t, string(body), `stateless_oracle_verification_seconds_count{target="block"} 1`)
+}
@@ -2,16 +2,20 @@ package client
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/cosmos/iavl"
 	tmjson "github.com/tendermint/tendermint/libs/json"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 
 	"github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
 )
 
 type LocalOracleClient struct {
 	server server.OracleServer
+	// height is set on requests without a height when it is not zero.
+	height int64
 }
 
 var _ iavl.OracleClientI = LocalOracleClient{}
@@ -22,12 +26,101 @@ func NewLocalOracleClient(server server.OracleServer) *LocalOracleClient {
 	}
 }
 
+// NewLocalOracleClientAtHeight returns a client whose requests are all for
+// the block of height, so that one server can serve many executions.
+func NewLocalOracleClientAtHeight(server server.OracleServer, height int64) *LocalOracleClient {
+	return &LocalOracleClient{
+		server: server,
+		height: height,
+	}
+}
+
 func (c LocalOracleClient) Get(key []byte) []byte {
-	return c.server.Get(key)
+	return c.server.Get(c.pin(key))
+}
+
+// Prefetch forwards keys to the server if it supports prefetching.
+func (c LocalOracleClient) Prefetch(keys [][]byte, workers int) int {
+	p, ok := c.server.(interface {
+		Prefetch(keys [][]byte, workers int) int
+	})
+	if !ok {
+		return 0
+	}
+	pinned := make([][]byte, len(keys))
+	for i, key := range keys {
+		pinned[i] = c.pin(key)
+	}
+	return p.Prefetch(pinned, workers)
+}
+
+func (c LocalOracleClient) pin(key []byte) []byte {
+	if c.height == 0 {
+		return key
+	}
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil || req.Height != 0 {
+		return key
+	}
+	req.Height = c.height
+	return oracletypes.MustEncodeRequest(req)
+}
+
+// GetBatch requests many abci_query keys from the server in a single call.
+func (c LocalOracleClient) GetBatch(keys [][]byte) ([][]byte, error) {
+	queries := []oracletypes.Query{}
+	height := int64(0)
+	for i, key := range keys {
+		req, err := oracletypes.DecodeRequest(key)
+		if err != nil {
+			return nil, err
+		}
+		if req.Kind != oracletypes.KindABCIQuery {
+			return nil, fmt.Errorf("cannot batch %s requests", req.Kind)
+		}
+		if i > 0 && req.Height != height {
+			return nil, fmt.Errorf("cannot batch requests for heights %d and %d", height, req.Height)
+		}
+		height = req.Height
+		queries = append(queries, req.Queries...)
+	}
+	key, err := oracletypes.EncodeRequest(oracletypes.NewABCIQueryBatchRequest(height, queries))
+	if err != nil {
+		return nil, err
+	}
+	values, err := oracletypes.DecodeBatchResponse(c.Get(key))
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != len(keys) {
+		return nil, fmt.Errorf("batch returned %d responses for %d keys", len(values), len(keys))
+	}
+	return values, nil
 }
 
 func (o LocalOracleClient) Block() *ctypes.ResultBlock {
-	b := o.Get([]byte("block"))
+	return getBlock(o, 0)
+}
+
+// LastBlock returns the block below the one of the client.
+func (o LocalOracleClient) LastBlock() *ctypes.ResultBlock {
+	height := o.height
+	if height == 0 {
+		height = o.Block().Block.Height
+	}
+	return getBlock(o, height-1)
+}
+
+func (o LocalOracleClient) ConsensusParams() *ctypes.ResultConsensusParams {
+	return getConsensusParams(o)
+}
+
+func (o LocalOracleClient) Validators() *ctypes.ResultValidators {
+	return getValidators(o)
+}
+
+func getBlock(o iavl.OracleClientI, height int64) *ctypes.ResultBlock {
+	b := o.Get(oracletypes.MustEncodeRequest(oracletypes.NewBlockRequest(height)))
 	block := ctypes.ResultBlock{}
 	if err := tmjson.Unmarshal(b, &block); err != nil {
 		panic(err)
@@ -35,8 +128,8 @@ func (o LocalOracleClient) Block() *ctypes.ResultBlock {
 	return &block
 }
 
-func (o LocalOracleClient) ConsensusParams() *ctypes.ResultConsensusParams {
-	b := o.Get([]byte("consensus_params"))
+func getConsensusParams(o iavl.OracleClientI) *ctypes.ResultConsensusParams {
+	b := o.Get(oracletypes.MustEncodeRequest(oracletypes.NewConsensusParamsRequest(0)))
 	cp := ctypes.ResultConsensusParams{}
 	if err := tmjson.Unmarshal(b, &cp); err != nil {
 		panic(err)
@@ -44,8 +137,8 @@ func (o LocalOracleClient) ConsensusParams() *ctypes.ResultConsensusParams {
 	return &cp
 }
 
-func (o LocalOracleClient) Validators() *ctypes.ResultValidators {
-	b := o.Get([]byte("validators"))
+func getValidators(o iavl.OracleClientI) *ctypes.ResultValidators {
+	b := o.Get(oracletypes.MustEncodeRequest(oracletypes.NewValidatorsRequest(0)))
 	raw := json.RawMessage(b)
 	vals := ctypes.ResultValidators{}
 	if err := tmjson.Unmarshal(raw, &vals); err != nil {
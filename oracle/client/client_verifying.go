@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/cosmos/iavl"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/oracle/server"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var _ iavl.OracleClientI = &VerifyingOracleClient{}
+
+// VerifyingOracleClient trusts nothing but the hash of the block to execute.
+// Every response of the server is checked before it is returned:
+//   - the block must hash to the trusted hash,
+//   - the header of the block below must hash to the last block ID of the
+//     block,
+//   - the validators must hash to the validators hash of that header and
+//     must have signed the last commit of the block,
+//   - the consensus params must hash to the consensus hash of the block,
+//   - every ABCI query must be proven against the app hash of the block,
+//     or, with the partial proofs of a pruned bundle, against the nodes
+//     proven before (see oracletypes.ProvenNodes).
+//
+// Responses failing a check panic with an error wrapping
+// oracletypes.ErrVerificationFailed, which StatelessClient returns from
+// Execute.
+type VerifyingOracleClient struct {
+	client      *LocalOracleClient
+	height      int64
+	trustedHash []byte
+	proven      *oracletypes.ProvenNodes
+
+	mtx   sync.Mutex
+	block *types.Block
+}
+
+func NewVerifyingOracleClient(server server.OracleServer, height int64, trustedHash []byte) *VerifyingOracleClient {
+	return &VerifyingOracleClient{
+		client:      NewLocalOracleClientAtHeight(server, height),
+		height:      height,
+		trustedHash: trustedHash,
+		proven:      oracletypes.NewProvenNodes(),
+	}
+}
+
+func (c *VerifyingOracleClient) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	if req.Height != 0 && req.Height != c.height {
+		panic(fmt.Errorf("%w: %s request for height %d, only %d is trusted", oracletypes.ErrVerificationFailed, req.Kind, req.Height, c.height))
+	}
+	value := c.client.Get(key)
+	if err = c.verify(req, value); err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Prefetch forwards keys to the server. The prefetched responses are
+// verified when they are requested.
+func (c *VerifyingOracleClient) Prefetch(keys [][]byte, workers int) int {
+	return c.client.Prefetch(keys, workers)
+}
+
+func (c *VerifyingOracleClient) Block() *ctypes.ResultBlock {
+	return getBlock(c, 0)
+}
+
+func (c *VerifyingOracleClient) ConsensusParams() *ctypes.ResultConsensusParams {
+	return getConsensusParams(c)
+}
+
+func (c *VerifyingOracleClient) Validators() *ctypes.ResultValidators {
+	return getValidators(c)
+}
+
+// LastBlock returns the block below the trusted one. Only its header is
+// verified, its data may be missing.
+func (c *VerifyingOracleClient) LastBlock() *ctypes.ResultBlock {
+	block, err := c.verifiedBlock()
+	if err != nil {
+		panic(err)
+	}
+	last, err := c.lastBlock(block)
+	if err != nil {
+		panic(err)
+	}
+	return last
+}
+
+func (c *VerifyingOracleClient) verify(req oracletypes.Request, value []byte) error {
+	if req.Kind == oracletypes.KindBlock {
+		res := ctypes.ResultBlock{}
+		if err := oracletypes.DecodeResponse(value, &res); err != nil {
+			return err
+		}
+		return c.verifyBlock(res.Block)
+	}
+
+	block, err := c.verifiedBlock()
+	if err != nil {
+		return err
+	}
+	switch req.Kind {
+	case oracletypes.KindValidators:
+		res := ctypes.ResultValidators{}
+		if err = oracletypes.DecodeResponse(value, &res); err != nil {
+			return err
+		}
+		return c.verifyValidators(block, res.Validators)
+	case oracletypes.KindConsensusParams:
+		res := ctypes.ResultConsensusParams{}
+		if err = oracletypes.DecodeResponse(value, &res); err != nil {
+			return err
+		}
+		if !bytes.Equal(types.HashConsensusParams(res.ConsensusParams), block.ConsensusHash) {
+			return fmt.Errorf("%w: consensus params do not match the consensus hash", oracletypes.ErrVerificationFailed)
+		}
+		return nil
+	case oracletypes.KindABCIQuery:
+		res := ctypes.ResultABCIQuery{}
+		if err = oracletypes.DecodeResponse(value, &res); err != nil {
+			return err
+		}
+		return c.proven.Verify(block.AppHash, c.height-1, req.Queries[0], res.Response)
+	case oracletypes.KindABCIQueryBatch:
+		values, err := oracletypes.DecodeBatchResponse(value)
+		if err != nil {
+			return err
+		}
+		if len(values) != len(req.Queries) {
+			return fmt.Errorf("%w: %d responses for %d queries", oracletypes.ErrVerificationFailed, len(values), len(req.Queries))
+		}
+		for i, q := range req.Queries {
+			res := ctypes.ResultABCIQuery{}
+			if err = oracletypes.DecodeResponse(values[i], &res); err != nil {
+				return err
+			}
+			if err = c.proven.Verify(block.AppHash, c.height-1, q, res.Response); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: cannot verify %s responses", oracletypes.ErrVerificationFailed, req.Kind)
+	}
+}
+
+func (c *VerifyingOracleClient) verifyBlock(block *types.Block) error {
+	if block == nil || block.Height != c.height {
+		return fmt.Errorf("%w: no block of height %d", oracletypes.ErrVerificationFailed, c.height)
+	}
+	if err := block.ValidateBasic(); err != nil {
+		return fmt.Errorf("%w: %v", oracletypes.ErrVerificationFailed, err)
+	}
+	if hash := block.Hash(); !bytes.Equal(hash, c.trustedHash) {
+		return fmt.Errorf("%w: block hash %X does not match trusted hash %X", oracletypes.ErrVerificationFailed, hash, c.trustedHash)
+	}
+	c.mtx.Lock()
+	c.block = block
+	c.mtx.Unlock()
+	return nil
+}
+
+func (c *VerifyingOracleClient) verifiedBlock() (*types.Block, error) {
+	c.mtx.Lock()
+	block := c.block
+	c.mtx.Unlock()
+	if block != nil {
+		return block, nil
+	}
+	value := c.client.Get(oracletypes.MustEncodeRequest(oracletypes.NewBlockRequest(0)))
+	res := ctypes.ResultBlock{}
+	if err := oracletypes.DecodeResponse(value, &res); err != nil {
+		return nil, err
+	}
+	if err := c.verifyBlock(res.Block); err != nil {
+		return nil, err
+	}
+	return res.Block, nil
+}
+
+// lastBlock returns the block below block, whose header must hash to the
+// last block ID of block.
+func (c *VerifyingOracleClient) lastBlock(block *types.Block) (*ctypes.ResultBlock, error) {
+	value := c.client.Get(oracletypes.MustEncodeRequest(oracletypes.NewBlockRequest(c.height - 1)))
+	res := ctypes.ResultBlock{}
+	if err := oracletypes.DecodeResponse(value, &res); err != nil {
+		return nil, err
+	}
+	if res.Block == nil || res.Block.Height != c.height-1 {
+		return nil, fmt.Errorf("%w: no block of height %d", oracletypes.ErrVerificationFailed, c.height-1)
+	}
+	if hash := res.Block.Header.Hash(); !bytes.Equal(hash, block.LastBlockID.Hash) {
+		return nil, fmt.Errorf("%w: header hash %X does not match last block hash %X", oracletypes.ErrVerificationFailed, hash, block.LastBlockID.Hash)
+	}
+	return &res, nil
+}
+
+// verifyValidators checks that vals, in the given order, are the validators
+// committed to by the header of the block below and signed the last commit
+// of block.
+func (c *VerifyingOracleClient) verifyValidators(block *types.Block, vals []*types.Validator) error {
+	if block.LastCommit == nil || block.LastCommit.Size() == 0 {
+		// the block of the initial height has no last commit
+		if len(vals) != 0 {
+			return fmt.Errorf("%w: validators for a block without last commit", oracletypes.ErrVerificationFailed)
+		}
+		return nil
+	}
+	for _, val := range vals {
+		if err := val.ValidateBasic(); err != nil {
+			return fmt.Errorf("%w: %v", oracletypes.ErrVerificationFailed, err)
+		}
+	}
+	last, err := c.lastBlock(block)
+	if err != nil {
+		return err
+	}
+	// NewValidatorSet would sort vals, the hash covers their order and
+	// voting powers
+	valSet := &types.ValidatorSet{Validators: vals}
+	if hash := valSet.Hash(); !bytes.Equal(hash, last.Block.ValidatorsHash) {
+		return fmt.Errorf("%w: validators hash %X does not match %X", oracletypes.ErrVerificationFailed, hash, last.Block.ValidatorsHash)
+	}
+	err = valSet.VerifyCommit(block.ChainID, block.LastBlockID, block.Height-1, block.LastCommit)
+	if err != nil {
+		return fmt.Errorf("%w: validators did not sign the last commit: %v", oracletypes.ErrVerificationFailed, err)
+	}
+	return nil
+}
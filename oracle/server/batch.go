@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+type ABCIQueryRequest struct {
+	Path string
+	Data tmbytes.HexBytes
+}
+
+// BatchRPCClient is implemented by RPC clients that can send many ABCI
+// queries in a single round trip.
+type BatchRPCClient interface {
+	ABCIQueryBatch(ctx context.Context, reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions) ([]*ctypes.ResultABCIQuery, error)
+}
+
+var _ BatchRPCClient = httpRPCClient{}
+
+// httpRPCClient adds JSON-RPC batch calls to the Tendermint HTTP client.
+type httpRPCClient struct {
+	*rpchttp.HTTP
+}
+
+func (c httpRPCClient) ABCIQueryBatch(ctx context.Context, reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions) ([]*ctypes.ResultABCIQuery, error) {
+	batch := c.NewBatch()
+	results := make([]*ctypes.ResultABCIQuery, len(reqs))
+	for i, req := range reqs {
+		res, err := batch.ABCIQueryWithOptions(ctx, req.Path, req.Data, opts)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = res
+	}
+	if _, err := batch.Send(ctx); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// abciQueryBatch sends reqs as one batch if c supports it and one by one otherwise.
+func abciQueryBatch(ctx context.Context, c RPCClient, reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions) ([]*ctypes.ResultABCIQuery, error) {
+	if bc, ok := c.(BatchRPCClient); ok {
+		return bc.ABCIQueryBatch(ctx, reqs, opts)
+	}
+	results := make([]*ctypes.ResultABCIQuery, len(reqs))
+	for i, req := range reqs {
+		res, err := c.ABCIQueryWithOptions(ctx, req.Path, req.Data, opts)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = res
+	}
+	return results, nil
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+func countRequest(server string, kind oracletypes.Kind) {
+	metrics.OracleRequests.WithLabelValues(server, kind.String()).Inc()
+}
+
+func observeVerification(target string, start time.Time) {
+	metrics.VerificationSeconds.WithLabelValues(target).Observe(time.Since(start).Seconds())
+}
+
+func countCache(cache string, hit bool) {
+	if hit {
+		metrics.CacheHits.WithLabelValues(cache).Inc()
+	} else {
+		metrics.CacheMisses.WithLabelValues(cache).Inc()
+	}
+}
+
+// countingTransport counts the bytes of the JSON-RPC responses received over
+// it by method.
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := rpcMethod(req)
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body = &countingBody{ReadCloser: res.Body, method: method}
+	return res, nil
+}
+
+// rpcMethod returns the method of a JSON-RPC request, or "batch" for a batch
+// of different methods.
+func rpcMethod(req *http.Request) string {
+	if req.GetBody == nil {
+		return "unknown"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "unknown"
+	}
+	defer body.Close()
+	bz, err := io.ReadAll(body)
+	if err != nil {
+		return "unknown"
+	}
+
+	type call struct {
+		Method string `json:"method"`
+	}
+	c := call{}
+	if err = json.Unmarshal(bz, &c); err == nil {
+		return c.Method
+	}
+	calls := []call{}
+	if err = json.Unmarshal(bz, &calls); err != nil || len(calls) == 0 {
+		return "unknown"
+	}
+	for _, c := range calls {
+		if c.Method != calls[0].Method {
+			return "batch"
+		}
+	}
+	return calls[0].Method
+}
+
+type countingBody struct {
+	io.ReadCloser
+	method string
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	metrics.BytesFetched.WithLabelValues(b.method).Add(float64(n))
+	return n, err
+}
@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/iavl"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	jsonrpcclient "github.com/tendermint/tendermint/rpc/jsonrpc/client"
+)
+
+var (
+	_ RPCClient      = &rpchttp.HTTP{}
+	_ RPCClient      = &RPCPool{}
+	_ BatchRPCClient = &RPCPool{}
+)
+
+// RPCClient is the subset of the Tendermint RPC API used by the oracle servers.
+type RPCClient interface {
+	Block(ctx context.Context, height *int64) (*ctypes.ResultBlock, error)
+	Commit(ctx context.Context, height *int64) (*ctypes.ResultCommit, error)
+	Validators(ctx context.Context, height *int64, page, perPage *int) (*ctypes.ResultValidators, error)
+	ConsensusParams(ctx context.Context, height *int64) (*ctypes.ResultConsensusParams, error)
+	BlockResults(ctx context.Context, height *int64) (*ctypes.ResultBlockResults, error)
+	ABCIQueryWithOptions(ctx context.Context, path string, data tmbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error)
+}
+
+// ErrPrunedHeight is returned when a node no longer has the state for the requested height.
+var ErrPrunedHeight = errors.New("requested height is pruned")
+
+type RPCPoolConfig struct {
+	// MaxRetries is the number of attempts made after the first one fails.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It doubles on every retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RequestTimeout bounds a single attempt against a single endpoint.
+	RequestTimeout time.Duration
+	// FailureThreshold is the number of consecutive failures after which an endpoint is skipped for Cooldown.
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+func DefaultRPCPoolConfig() RPCPoolConfig {
+	return RPCPoolConfig{
+		MaxRetries:       5,
+		InitialBackoff:   200 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		RequestTimeout:   30 * time.Second,
+		FailureThreshold: 3,
+		Cooldown:         time.Minute,
+	}
+}
+
+// RPCPool spreads requests over several RPC endpoints. Failed requests are
+// retried with exponential backoff on the next healthy endpoint.
+type RPCPool struct {
+	config RPCPoolConfig
+
+	mtx       sync.Mutex
+	endpoints []*rpcEndpoint
+	next      int
+}
+
+type rpcEndpoint struct {
+	addr      string
+	client    RPCClient
+	failures  int
+	downUntil time.Time
+}
+
+func NewRPCPool(rpcAddrs []string, config RPCPoolConfig) (*RPCPool, error) {
+	clients := make(map[string]RPCClient, len(rpcAddrs))
+	for _, addr := range rpcAddrs {
+		httpClient, err := jsonrpcclient.DefaultHTTPClient(addr)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = countingTransport{httpClient.Transport}
+		c, err := rpchttp.NewWithClient(addr, "/websocket", httpClient)
+		if err != nil {
+			return nil, err
+		}
+		clients[addr] = httpRPCClient{c}
+	}
+	return newRPCPool(rpcAddrs, clients, config)
+}
+
+func newRPCPool(addrs []string, clients map[string]RPCClient, config RPCPoolConfig) (*RPCPool, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("rpc pool requires at least one endpoint")
+	}
+	endpoints := make([]*rpcEndpoint, len(addrs))
+	for i, addr := range addrs {
+		endpoints[i] = &rpcEndpoint{
+			addr:   addr,
+			client: clients[addr],
+		}
+	}
+	return &RPCPool{
+		config:    config,
+		endpoints: endpoints,
+	}, nil
+}
+
+func (p *RPCPool) Block(ctx context.Context, height *int64) (*ctypes.ResultBlock, error) {
+	var result *ctypes.ResultBlock
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.Block(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) Commit(ctx context.Context, height *int64) (*ctypes.ResultCommit, error) {
+	var result *ctypes.ResultCommit
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.Commit(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) Validators(ctx context.Context, height *int64, page, perPage *int) (*ctypes.ResultValidators, error) {
+	var result *ctypes.ResultValidators
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.Validators(ctx, height, page, perPage)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) ConsensusParams(ctx context.Context, height *int64) (*ctypes.ResultConsensusParams, error) {
+	var result *ctypes.ResultConsensusParams
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.ConsensusParams(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) BlockResults(ctx context.Context, height *int64) (*ctypes.ResultBlockResults, error) {
+	var result *ctypes.ResultBlockResults
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.BlockResults(ctx, height)
+		return err
+	})
+	return result, err
+}
+
+func (p *RPCPool) ABCIQueryWithOptions(ctx context.Context, path string, data tmbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error) {
+	var result *ctypes.ResultABCIQuery
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.ABCIQueryWithOptions(ctx, path, data, opts)
+		if err != nil {
+			return err
+		}
+		return prunedError(result)
+	})
+	return result, err
+}
+
+func (p *RPCPool) ABCIQueryBatch(ctx context.Context, reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions) ([]*ctypes.ResultABCIQuery, error) {
+	var results []*ctypes.ResultABCIQuery
+	err := p.do(ctx, func(ctx context.Context, c RPCClient) (err error) {
+		results, err = abciQueryBatch(ctx, c, reqs, opts)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			if err = prunedError(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return results, err
+}
+
+func (p *RPCPool) do(ctx context.Context, fn func(context.Context, RPCClient) error) error {
+	var lastErr error
+	backoff := p.config.InitialBackoff
+	for attempt := 0; attempt <= p.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > p.config.MaxBackoff {
+				backoff = p.config.MaxBackoff
+			}
+		}
+
+		ep := p.pick()
+		reqCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.config.RequestTimeout > 0 {
+			reqCtx, cancel = context.WithTimeout(ctx, p.config.RequestTimeout)
+		}
+		err := fn(reqCtx, ep.client)
+		cancel()
+		if err == nil {
+			p.markSuccess(ep)
+			return nil
+		}
+		p.markFailure(ep)
+		lastErr = fmt.Errorf("%s: %w", ep.addr, err)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return fmt.Errorf("rpc pool: %d attempts failed: %w", p.config.MaxRetries+1, lastErr)
+}
+
+// pick returns the next healthy endpoint in round-robin order. If every
+// endpoint is cooling down, the one that recovers first is returned.
+func (p *RPCPool) pick() *rpcEndpoint {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := time.Now()
+	var fallback *rpcEndpoint
+	for i := range p.endpoints {
+		ep := p.endpoints[(p.next+i)%len(p.endpoints)]
+		if !ep.downUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.endpoints)
+			return ep
+		}
+		if fallback == nil || ep.downUntil.Before(fallback.downUntil) {
+			fallback = ep
+		}
+	}
+	return fallback
+}
+
+func (p *RPCPool) markSuccess(ep *rpcEndpoint) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	ep.failures = 0
+	ep.downUntil = time.Time{}
+}
+
+func (p *RPCPool) markFailure(ep *rpcEndpoint) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	ep.failures++
+	if ep.failures >= p.config.FailureThreshold {
+		ep.downUntil = time.Now().Add(p.config.Cooldown)
+	}
+}
+
+// Healthy returns the addresses of the endpoints that are not cooling down.
+func (p *RPCPool) Healthy() []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := time.Now()
+	addrs := []string{}
+	for _, ep := range p.endpoints {
+		if !ep.downUntil.After(now) {
+			addrs = append(addrs, ep.addr)
+		}
+	}
+	return addrs
+}
+
+// prunedQueryError is the error of the multistore for a proof of a pruned
+// height. It wraps sdkerrors.ErrInvalidRequest like the baseapp error for a
+// version the node no longer has.
+const prunedQueryError = "ensure height has not been pruned"
+
+// prunedError returns an ErrPrunedHeight error if res reports that the node
+// no longer has the state of the queried height and nil otherwise.
+func prunedError(res *ctypes.ResultABCIQuery) error {
+	r := res.Response
+	switch {
+	case r.IsOK():
+		// a store query of a missing version succeeds with the IAVL error as log
+		if r.Log != iavl.ErrVersionDoesNotExist.Error() {
+			return nil
+		}
+	case r.Codespace == sdkerrors.RootCodespace && r.Code == sdkerrors.ErrInvalidRequest.ABCICode():
+		if !strings.Contains(r.Log, iavl.ErrVersionDoesNotExist.Error()) && !strings.Contains(r.Log, prunedQueryError) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPrunedHeight, r.Log)
+}
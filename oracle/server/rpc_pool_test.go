@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+type fakeRPCClient struct {
+	RPCClient
+	calls  int
+	err    error
+	pruned bool
+	delay  time.Duration
+	vals   *ctypes.ResultValidators
+}
+
+func (c *fakeRPCClient) Block(ctx context.Context, height *int64) (*ctypes.ResultBlock, error) {
+	c.calls++
+	if c.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.delay):
+		}
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &ctypes.ResultBlock{}, nil
+}
+
+func (c *fakeRPCClient) Validators(ctx context.Context, height *int64, page, perPage *int) (*ctypes.ResultValidators, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.vals, nil
+}
+
+func (c *fakeRPCClient) ABCIQueryWithOptions(ctx context.Context, path string, data tmbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error) {
+	c.calls++
+	if c.pruned {
+		return &ctypes.ResultABCIQuery{Response: abci.ResponseQuery{
+			Codespace: sdkerrors.RootCodespace,
+			Code:      sdkerrors.ErrInvalidRequest.ABCICode(),
+			Log:       "proof is unexpectedly empty; ensure height has not been pruned",
+		}}, nil
+	}
+	return &ctypes.ResultABCIQuery{}, nil
+}
+
+func newTestPool(t *testing.T, clients ...*fakeRPCClient) *RPCPool {
+	addrs := []string{}
+	m := map[string]RPCClient{}
+	for i, c := range clients {
+		addr := string(rune('a' + i))
+		addrs = append(addrs, addr)
+		m[addr] = c
+	}
+	config := DefaultRPCPoolConfig()
+	config.InitialBackoff = time.Millisecond
+	config.MaxBackoff = 4 * time.Millisecond
+	config.RequestTimeout = 50 * time.Millisecond
+	config.FailureThreshold = 1
+	pool, err := newRPCPool(addrs, m, config)
+	require.NoError(t, err)
+	return pool
+}
+
+func TestRPCPoolFailover(t *testing.T) {
+	broken := &fakeRPCClient{err: errors.New("connection refused")}
+	healthy := &fakeRPCClient{}
+	pool := newTestPool(t, broken, healthy)
+
+	for range make([]int, 4) {
+		_, err := pool.Block(context.Background(), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, broken.calls)
+	require.Equal(t, 4, healthy.calls)
+	require.Equal(t, []string{"b"}, pool.Healthy())
+}
+
+func TestRPCPoolPrunedHeight(t *testing.T) {
+	pruned := &fakeRPCClient{pruned: true}
+	archive := &fakeRPCClient{}
+	pool := newTestPool(t, pruned, archive)
+
+	res, err := pool.ABCIQueryWithOptions(context.Background(), "store/bank/key", nil, rpcclient.ABCIQueryOptions{})
+	require.NoError(t, err)
+	require.True(t, res.Response.IsOK())
+	require.Equal(t, 1, pruned.calls)
+	require.Equal(t, 1, archive.calls)
+}
+
+func TestPrunedError(t *testing.T) {
+	invalid := sdkerrors.ErrInvalidRequest.ABCICode()
+	cases := []struct {
+		name   string
+		res    abci.ResponseQuery
+		pruned bool
+	}{
+		{"ok", abci.ResponseQuery{}, false},
+		{"store query of a missing version", abci.ResponseQuery{Log: "version does not exist"}, true},
+		{"missing version", abci.ResponseQuery{Codespace: sdkerrors.RootCodespace, Code: invalid, Log: "failed to load state at height 4; version does not exist (latest height: 9)"}, true},
+		{"pruned proof", abci.ResponseQuery{Codespace: sdkerrors.RootCodespace, Code: invalid, Log: "proof is unexpectedly empty; ensure height has not been pruned"}, true},
+		{"other invalid request", abci.ResponseQuery{Codespace: sdkerrors.RootCodespace, Code: invalid, Log: "cannot query with proof when height <= 1"}, false},
+		{"other codespace", abci.ResponseQuery{Codespace: "bank", Code: 1, Log: "version does not exist"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := prunedError(&ctypes.ResultABCIQuery{Response: c.res})
+			if c.pruned {
+				require.ErrorIs(t, err, ErrPrunedHeight)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRPCPoolRetriesExhausted(t *testing.T) {
+	slow := &fakeRPCClient{delay: time.Second}
+	broken := &fakeRPCClient{err: errors.New("bad gateway")}
+	pool := newTestPool(t, slow, broken)
+
+	_, err := pool.Block(context.Background(), nil)
+	require.Error(t, err)
+	require.Equal(t, pool.config.MaxRetries+1, slow.calls+broken.calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.Block(ctx, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	ocjson "github.com/tendermint/tendermint/libs/json"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+var (
+	_ RPCClient      = &QuorumRPC{}
+	_ BatchRPCClient = &QuorumRPC{}
+)
+
+type RPCProvider struct {
+	Name   string
+	Client RPCClient
+}
+
+// ErrConflictingQuorums is returned when more than one result reached the
+// quorum, which is possible if the quorum is at most half of the providers.
+var ErrConflictingQuorums = errors.New("conflicting results reached the quorum")
+
+// QuorumRPC sends every request to all providers and accepts a result only
+// if at least quorum providers returned the same canonical JSON and no other
+// result did.
+type QuorumRPC struct {
+	providers []RPCProvider
+	quorum    int
+	// onDivergence is called when a result is accepted although some providers disagreed or failed.
+	onDivergence func(*QuorumError)
+}
+
+func NewQuorumRPC(providers []RPCProvider, quorum int) (*QuorumRPC, error) {
+	if quorum < 1 || quorum > len(providers) {
+		return nil, fmt.Errorf("quorum must be between 1 and %d, got %d", len(providers), quorum)
+	}
+	return &QuorumRPC{
+		providers: providers,
+		quorum:    quorum,
+	}, nil
+}
+
+func (q *QuorumRPC) SetDivergenceHandler(fn func(*QuorumError)) {
+	q.onDivergence = fn
+}
+
+// QuorumError describes how the providers answered a request.
+type QuorumError struct {
+	Method string
+	Quorum int
+	// Agreed lists the providers of the most common result.
+	Agreed []string
+	// Diverged lists the providers whose result differs from the most common one.
+	Diverged []string
+	Failed   map[string]error
+	// Conflict is set if a diverged result reached the quorum as well.
+	Conflict bool
+}
+
+func (e *QuorumError) Is(target error) bool {
+	return e.Conflict && target == ErrConflictingQuorums
+}
+
+func (e *QuorumError) Error() string {
+	failed := []string{}
+	for name, err := range e.Failed {
+		failed = append(failed, fmt.Sprintf("%s: %v", name, err))
+	}
+	sort.Strings(failed)
+	if e.Conflict {
+		return fmt.Sprintf("%s: %v: agreed [%s], diverged [%s], failed [%s]",
+			e.Method, ErrConflictingQuorums, strings.Join(e.Agreed, ", "), strings.Join(e.Diverged, ", "), strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("%s: %d providers agreed, quorum is %d: agreed [%s], diverged [%s], failed [%s]",
+		e.Method, len(e.Agreed), e.Quorum, strings.Join(e.Agreed, ", "), strings.Join(e.Diverged, ", "), strings.Join(failed, "; "))
+}
+
+func (q *QuorumRPC) Block(ctx context.Context, height *int64) (*ctypes.ResultBlock, error) {
+	result := &ctypes.ResultBlock{}
+	err := q.do(ctx, "block", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		return c.Block(ctx, height)
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) Commit(ctx context.Context, height *int64) (*ctypes.ResultCommit, error) {
+	result := &ctypes.ResultCommit{}
+	err := q.do(ctx, "commit", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		return c.Commit(ctx, height)
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) Validators(ctx context.Context, height *int64, page, perPage *int) (*ctypes.ResultValidators, error) {
+	result := &ctypes.ResultValidators{}
+	err := q.do(ctx, "validators", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		return c.Validators(ctx, height, page, perPage)
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) ConsensusParams(ctx context.Context, height *int64) (*ctypes.ResultConsensusParams, error) {
+	result := &ctypes.ResultConsensusParams{}
+	err := q.do(ctx, "consensus_params", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		return c.ConsensusParams(ctx, height)
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) BlockResults(ctx context.Context, height *int64) (*ctypes.ResultBlockResults, error) {
+	result := &ctypes.ResultBlockResults{}
+	err := q.do(ctx, "block_results", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		return c.BlockResults(ctx, height)
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) ABCIQueryWithOptions(ctx context.Context, path string, data tmbytes.HexBytes, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error) {
+	result := &ctypes.ResultABCIQuery{}
+	err := q.do(ctx, "abci_query", result, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		res, err := c.ABCIQueryWithOptions(ctx, path, data, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err = prunedError(res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
+	return result, err
+}
+
+func (q *QuorumRPC) ABCIQueryBatch(ctx context.Context, reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions) ([]*ctypes.ResultABCIQuery, error) {
+	results := []*ctypes.ResultABCIQuery{}
+	err := q.do(ctx, "abci_query_batch", &results, func(ctx context.Context, c RPCClient) (interface{}, error) {
+		res, err := abciQueryBatch(ctx, c, reqs, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range res {
+			if err = prunedError(res[i]); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	})
+	return results, err
+}
+
+// do queries all providers concurrently and decodes the agreed result into out.
+func (q *QuorumRPC) do(ctx context.Context, method string, out interface{}, fn func(context.Context, RPCClient) (interface{}, error)) error {
+	type answer struct {
+		raw []byte
+		err error
+	}
+	answers := make([]answer, len(q.providers))
+	wg := sync.WaitGroup{}
+	for i := range q.providers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := fn(ctx, q.providers[i].Client)
+			if err != nil {
+				answers[i] = answer{err: err}
+				return
+			}
+			raw, err := canonicalJSON(res)
+			answers[i] = answer{raw: raw, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	// group providers by identical canonical result
+	groups := [][]int{}
+	qerr := &QuorumError{Method: method, Quorum: q.quorum, Failed: map[string]error{}}
+	for i, a := range answers {
+		if a.err != nil {
+			qerr.Failed[q.providers[i].Name] = a.err
+			continue
+		}
+		found := false
+		for j, g := range groups {
+			if bytes.Equal(answers[g[0]].raw, a.raw) {
+				groups[j] = append(g, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []int{i})
+		}
+	}
+
+	best := -1
+	quorums := 0
+	for j, g := range groups {
+		if best < 0 || len(g) > len(groups[best]) {
+			best = j
+		}
+		if len(g) >= q.quorum {
+			quorums++
+		}
+	}
+	for j, g := range groups {
+		for _, i := range g {
+			if j == best {
+				qerr.Agreed = append(qerr.Agreed, q.providers[i].Name)
+			} else {
+				qerr.Diverged = append(qerr.Diverged, q.providers[i].Name)
+			}
+		}
+	}
+
+	if len(qerr.Agreed) < q.quorum {
+		return qerr
+	}
+	if quorums > 1 {
+		qerr.Conflict = true
+		return qerr
+	}
+	if (len(qerr.Diverged) > 0 || len(qerr.Failed) > 0) && q.onDivergence != nil {
+		q.onDivergence(qerr)
+	}
+	return ocjson.Unmarshal(answers[groups[best][0]].raw, out)
+}
+
+// canonicalJSON encodes v with the Tendermint JSON encoder and then sorts
+// object keys so that results of different nodes compare byte-for-byte.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	js, err := ocjson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(js))
+	d.UseNumber()
+	var generic interface{}
+	if err := d.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+func newTestValidators(seed byte, power int64) *ctypes.ResultValidators {
+	pk := ed25519.GenPrivKeyFromSecret([]byte{seed}).PubKey()
+	vals := []*types.Validator{types.NewValidator(pk, power)}
+	return &ctypes.ResultValidators{BlockHeight: 10, Validators: vals, Count: 1, Total: 1}
+}
+
+func TestQuorumRPC(t *testing.T) {
+	honest := newTestValidators(1, 10)
+	forged := newTestValidators(2, 10)
+
+	a := &fakeRPCClient{vals: honest}
+	b := &fakeRPCClient{vals: honest}
+	c := &fakeRPCClient{vals: forged}
+	d := &fakeRPCClient{err: errors.New("timeout")}
+	providers := []RPCProvider{{"a", a}, {"b", b}, {"c", c}, {"d", d}}
+
+	// quorum reached, divergence is reported
+	q, err := NewQuorumRPC(providers, 2)
+	require.NoError(t, err)
+	var reported *QuorumError
+	q.SetDivergenceHandler(func(e *QuorumError) { reported = e })
+	res, err := q.Validators(context.Background(), nil, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, honest.Validators[0].Address, res.Validators[0].Address)
+	require.NotNil(t, reported)
+	require.Equal(t, []string{"a", "b"}, reported.Agreed)
+	require.Equal(t, []string{"c"}, reported.Diverged)
+	require.Contains(t, reported.Failed, "d")
+
+	// quorum not reached
+	q, err = NewQuorumRPC(providers, 3)
+	require.NoError(t, err)
+	_, err = q.Validators(context.Background(), nil, nil, nil)
+	qerr := &QuorumError{}
+	require.ErrorAs(t, err, &qerr)
+	require.Equal(t, []string{"c"}, qerr.Diverged)
+
+	_, err = NewQuorumRPC(providers, 5)
+	require.Error(t, err)
+}
+
+func TestQuorumRPCConflict(t *testing.T) {
+	honest := newTestValidators(1, 10)
+	forged := newTestValidators(2, 10)
+	providers := []RPCProvider{
+		{"a", &fakeRPCClient{vals: honest}},
+		{"b", &fakeRPCClient{vals: honest}},
+		{"c", &fakeRPCClient{vals: forged}},
+		{"d", &fakeRPCClient{vals: forged}},
+	}
+
+	// both groups reach a quorum of half of the providers
+	q, err := NewQuorumRPC(providers, 2)
+	require.NoError(t, err)
+	_, err = q.Validators(context.Background(), nil, nil, nil)
+	require.ErrorIs(t, err, ErrConflictingQuorums)
+	qerr := &QuorumError{}
+	require.ErrorAs(t, err, &qerr)
+	require.Len(t, qerr.Agreed, 2)
+	require.Len(t, qerr.Diverged, 2)
+}
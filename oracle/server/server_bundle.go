@@ -0,0 +1,132 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
+)
+
+var _ OracleServer = &BundleOracleServer{}
+
+// BundleOracleServer serves the block of a bundle from the bundle alone, and
+// the header of the block below as a block without data. Its responses are
+// the ones of the bundle creator and must be verified, e.g. by
+// VerifyingOracleClient.
+type BundleOracleServer struct {
+	bundle  *witness.Bundle
+	block   *ctypes.ResultBlock
+	vals    *ctypes.ResultValidators
+	queries map[string]abci.ResponseQuery
+
+	mtx sync.Mutex
+	// used holds the keys of the queries served so far.
+	used map[string]bool
+}
+
+func NewBundleOracleServer(bundle *witness.Bundle) (*BundleOracleServer, error) {
+	block, err := bundle.ResultBlock()
+	if err != nil {
+		return nil, err
+	}
+	vals, err := bundle.ResultValidators()
+	if err != nil {
+		return nil, err
+	}
+	// the proofs are rebuilt once, so that an invalid bundle fails here
+	queries := map[string]abci.ResponseQuery{}
+	for i, q := range bundle.Queries {
+		if queries[witness.QueryKey(q.Path, q.Data)], err = bundle.QueryResponse(i); err != nil {
+			return nil, err
+		}
+	}
+	return &BundleOracleServer{
+		bundle:  bundle,
+		block:   block,
+		vals:    vals,
+		queries: queries,
+		used:    map[string]bool{},
+	}, nil
+}
+
+// UsedQueries returns the QueryKeys of the queries served so far, e.g. to
+// prune the bundle to the queries of an execution with witness.PruneQueries.
+func (s *BundleOracleServer) UsedQueries() map[string]bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	used := make(map[string]bool, len(s.used))
+	for key := range s.used {
+		used[key] = true
+	}
+	return used
+}
+
+func (s *BundleOracleServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("bundle", req.Kind)
+	res, err := s.handle(req)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (s *BundleOracleServer) handle(req oracletypes.Request) ([]byte, error) {
+	if req.Kind == oracletypes.KindBlock && req.Height == s.bundle.Height-1 {
+		// the bundle only holds the header of the block below
+		last, err := s.bundle.ResultLastBlock()
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(last), nil
+	}
+	if req.Height != 0 && req.Height != s.bundle.Height {
+		return nil, fmt.Errorf("bundle has no block at height %d", req.Height)
+	}
+
+	switch req.Kind {
+	case oracletypes.KindBlock:
+		return toRawJson(s.block), nil
+	case oracletypes.KindConsensusParams:
+		return toRawJson(s.bundle.ResultConsensusParams()), nil
+	case oracletypes.KindValidators:
+		return toRawJson(s.vals), nil
+	case oracletypes.KindABCIQuery:
+		res, err := s.query(req.Queries[0])
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(res), nil
+	case oracletypes.KindABCIQueryBatch:
+		results := make([]interface{}, len(req.Queries))
+		for i, q := range req.Queries {
+			res, err := s.query(q)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = res
+		}
+		return oracletypes.EncodeBatchResponse(results)
+	default:
+		return nil, fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind)
+	}
+}
+
+func (s *BundleOracleServer) query(q oracletypes.Query) (ctypes.ResultABCIQuery, error) {
+	key := witness.QueryKey(q.Path, q.Data)
+	res, ok := s.queries[key]
+	if !ok {
+		return ctypes.ResultABCIQuery{}, fmt.Errorf("bundle has no response to %s %X", q.Path, q.Data)
+	}
+	s.mtx.Lock()
+	s.used[key] = true
+	s.mtx.Unlock()
+	return ctypes.ResultABCIQuery{Response: res}, nil
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	cmtdb "github.com/cometbft/cometbft-db"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	sm "github.com/tendermint/tendermint/state"
+	tmstore "github.com/tendermint/tendermint/store"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var _ OracleServer = &DBOracleServer{}
+
+// BlockSource is implemented by the Tendermint block store.
+type BlockSource interface {
+	LoadBlock(height int64) *types.Block
+	LoadBlockMeta(height int64) *types.BlockMeta
+}
+
+// DBOracleServer serves blocks, validators and proofs of any retained
+// version from the databases of a stopped node, without RPC. Requests must
+// carry the height of the block to execute.
+type DBOracleServer struct {
+	cms    *rootmulti.Store
+	blocks BlockSource
+	state  sm.Store
+
+	// mtx serializes the store queries
+	mtx     sync.Mutex
+	closers []io.Closer
+}
+
+// NewDBOracleServer opens application.db, blockstore.db and state.db of the
+// node data directory dataDir read-only. The databases must be goleveldb.
+func NewDBOracleServer(dataDir string) (*DBOracleServer, error) {
+	closers := []io.Closer{}
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	readOnly := &opt.Options{ReadOnly: true}
+
+	appDB, err := dbm.NewGoLevelDBWithOpts("application", dataDir, readOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filepath.Join(dataDir, "application.db"), err)
+	}
+	closers = append(closers, appDB)
+	blockDB, err := cmtdb.NewGoLevelDBWithOpts("blockstore", dataDir, readOnly)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to open %s: %w", filepath.Join(dataDir, "blockstore.db"), err)
+	}
+	closers = append(closers, blockDB)
+	stateDB, err := cmtdb.NewGoLevelDBWithOpts("state", dataDir, readOnly)
+	if err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to open %s: %w", filepath.Join(dataDir, "state.db"), err)
+	}
+	closers = append(closers, stateDB)
+
+	s, err := NewDBOracleServerWithDBs(appDB, tmstore.NewBlockStore(blockDB), sm.NewStore(stateDB, sm.StoreOptions{}))
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	s.closers = closers
+	return s, nil
+}
+
+// NewDBOracleServerWithDBs mounts every store committed in the latest
+// version of appDB. appDB is never written.
+func NewDBOracleServerWithDBs(appDB dbm.DB, blocks BlockSource, state sm.Store) (*DBOracleServer, error) {
+	version := rootmulti.GetLatestVersion(appDB)
+	if version == 0 {
+		return nil, fmt.Errorf("application db has no committed version")
+	}
+	bz, err := appDB.Get([]byte(fmt.Sprintf("s/%d", version)))
+	if err != nil {
+		return nil, err
+	}
+	if bz == nil {
+		return nil, fmt.Errorf("no commit info for version %d", version)
+	}
+	cInfo := storetypes.CommitInfo{}
+	if err = cInfo.Unmarshal(bz); err != nil {
+		return nil, err
+	}
+
+	cms := rootmulti.NewStore(appDB, log.NewNopLogger())
+	// fast node upgrade writes to the db
+	cms.SetIAVLDisableFastNode(true)
+	cms.SetLazyLoading(true)
+	for _, info := range cInfo.StoreInfos {
+		cms.MountStoreWithDB(storetypes.NewKVStoreKey(info.Name), storetypes.StoreTypeIAVL, nil)
+	}
+	if err = cms.LoadVersion(version); err != nil {
+		return nil, err
+	}
+
+	return &DBOracleServer{
+		cms:    cms,
+		blocks: blocks,
+		state:  state,
+	}, nil
+}
+
+// LatestVersion returns the latest version of the application db. Blocks up
+// to one height above it can be served.
+func (s *DBOracleServer) LatestVersion() int64 {
+	return s.cms.LastCommitID().Version
+}
+
+func (s *DBOracleServer) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, db := range s.closers {
+		if err := db.Close(); err != nil {
+			return err
+		}
+	}
+	s.closers = nil
+	return nil
+}
+
+func (s *DBOracleServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("db", req.Kind)
+	res, err := s.handle(req)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (s *DBOracleServer) handle(req oracletypes.Request) ([]byte, error) {
+	height := req.Height
+	// the first block is only served as the block below the second one
+	if height < 1 || height == 1 && req.Kind != oracletypes.KindBlock {
+		return nil, fmt.Errorf("%s request requires a height above 1", req.Kind)
+	}
+
+	switch req.Kind {
+	case oracletypes.KindBlock:
+		block, meta := s.blocks.LoadBlock(height), s.blocks.LoadBlockMeta(height)
+		if block == nil || meta == nil {
+			return nil, fmt.Errorf("no block at height %d", height)
+		}
+		return toRawJson(ctypes.ResultBlock{
+			BlockID: meta.BlockID,
+			Block:   block,
+		}), nil
+	case oracletypes.KindConsensusParams:
+		cp, err := s.state.LoadConsensusParams(height)
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(ctypes.ResultConsensusParams{
+			BlockHeight:     height,
+			ConsensusParams: cp,
+		}), nil
+	case oracletypes.KindValidators:
+		// same height as the validators verified by RPCOracleServer
+		vals, err := s.state.LoadValidators(height - 1)
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(ctypes.ResultValidators{
+			BlockHeight: height - 1,
+			Validators:  vals.Validators,
+			Count:       len(vals.Validators),
+			Total:       len(vals.Validators),
+		}), nil
+	case oracletypes.KindABCIQuery:
+		res, err := s.query(height, req.Queries[0])
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(res), nil
+	case oracletypes.KindABCIQueryBatch:
+		results := make([]interface{}, len(req.Queries))
+		for i, q := range req.Queries {
+			res, err := s.query(height, q)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = res
+		}
+		return oracletypes.EncodeBatchResponse(results)
+	default:
+		return nil, fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind)
+	}
+}
+
+func (s *DBOracleServer) query(height int64, q oracletypes.Query) (ctypes.ResultABCIQuery, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return queryMultiStore(s.cms, height-1, q)
+}
+
+// queryMultiStore answers q at version like the store queries of baseapp do.
+func queryMultiStore(cms *rootmulti.Store, version int64, q oracletypes.Query) (result ctypes.ResultABCIQuery, err error) {
+	if !strings.HasPrefix(q.Path, "store/") {
+		return ctypes.ResultABCIQuery{}, fmt.Errorf("%w: only store queries are supported, got %s", oracletypes.ErrMalformedRequest, q.Path)
+	}
+	// baseapp turns panics, e.g. for missing nodes, into error responses
+	defer func() {
+		if r := recover(); r != nil {
+			result = ctypes.ResultABCIQuery{
+				Response: sdkerrors.QueryResultWithDebug(sdkerrors.Wrapf(sdkerrors.ErrPanic, "%v", r), false),
+			}
+		}
+	}()
+	res := cms.Query(abci.RequestQuery{
+		Data:   q.Data,
+		Path:   strings.TrimPrefix(q.Path, "store"),
+		Height: version,
+		Prove:  true,
+	})
+	res.Height = version
+
+	return ctypes.ResultABCIQuery{
+		Response: res,
+	}, nil
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"math/rand"
+	"testing"
+
+	cmtdb "github.com/cometbft/cometbft-db"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	sm "github.com/tendermint/tendermint/state"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+func TestDBOracleServer(t *testing.T) {
+	db := dbm.NewMemDB()
+	app, err := testapp.NewTestAppWithDB(db)
+	require.NoError(t, err)
+	app.InitChain(abci.RequestInitChain{})
+	blocks := mapBlockSource{}
+	r := rand.New(rand.NewSource(0))
+	vals, _ := types.RandValidatorSet(4, 10)
+	cp := *types.DefaultConsensusParams()
+	state := sm.State{
+		ChainID:                          "test",
+		InitialHeight:                    1,
+		Validators:                       vals,
+		NextValidators:                   vals,
+		LastValidators:                   vals,
+		LastHeightValidatorsChanged:      1,
+		ConsensusParams:                  cp,
+		LastHeightConsensusParamsChanged: 1,
+	}
+	stateStore := sm.NewStore(cmtdb.NewMemDB(), sm.StoreOptions{})
+	require.NoError(t, stateStore.Bootstrap(state))
+	for i := range make([]int, 16) {
+		block, err := testapp.ExecuteBlockWithTxs(app, 8, int64(i)+1, r)
+		require.NoError(t, err)
+		app.Commit()
+		blocks[block.Height] = block
+		state.LastBlockHeight = block.Height
+		require.NoError(t, stateStore.Save(state))
+	}
+	// the state store sets the proposer priorities of the height
+	local := NewLocalOracleServer(app, blocks[1], vals.Validators, &cp)
+	for h := int64(2); h <= 16; h++ {
+		lastVals, err := stateStore.LoadValidators(h - 1)
+		require.NoError(t, err)
+		local.AddBlock(blocks[h], lastVals.Validators, &cp)
+	}
+
+	server, err := NewDBOracleServerWithDBs(db, blocks, stateStore)
+	require.NoError(t, err)
+	require.Equal(t, int64(16), server.LatestVersion())
+
+	for _, height := range []int64{3, 9, 16} {
+		res, err := server.handle(oracletypes.NewBlockRequest(height))
+		require.NoError(t, err)
+		meta := blocks.LoadBlockMeta(height)
+		require.NotZero(t, meta.BlockID.PartSetHeader.Total)
+		require.Equal(t, string(toRawJson(ctypes.ResultBlock{BlockID: meta.BlockID, Block: blocks[height]})), string(res))
+
+		// both servers answer like RPCOracleServer
+		reqs := []oracletypes.Request{
+			oracletypes.NewConsensusParamsRequest(height),
+			oracletypes.NewValidatorsRequest(height),
+		}
+		for _, path := range []string{"store/key1/key", "store/key2/key"} {
+			reqs = append(reqs, oracletypes.NewABCIQueryRequest(height, path, []byte{byte(height)}))
+		}
+		for _, req := range reqs {
+			res, err := server.handle(req)
+			require.NoError(t, err)
+			expected, err := local.handle(req)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(res), "%s request", req.Kind)
+		}
+		res, err = server.handle(oracletypes.NewConsensusParamsRequest(height))
+		require.NoError(t, err)
+		cpRes := ctypes.ResultConsensusParams{}
+		require.NoError(t, oracletypes.DecodeResponse(res, &cpRes))
+		require.Equal(t, height, cpRes.BlockHeight)
+	}
+
+	_, err = server.handle(oracletypes.NewABCIQueryRequest(2, "custom/bank/balance", nil))
+	require.ErrorIs(t, err, oracletypes.ErrMalformedRequest)
+}
+
+type mapBlockSource map[int64]*types.Block
+
+func (m mapBlockSource) LoadBlock(height int64) *types.Block {
+	return m[height]
+}
+
+func (m mapBlockSource) LoadBlockMeta(height int64) *types.BlockMeta {
+	block := m[height]
+	if block == nil {
+		return nil
+	}
+	// fill the header before splitting the block into parts
+	block.Hash()
+	return types.NewBlockMeta(block, block.MakePartSet(types.BlockPartSizeBytes))
+}
@@ -1,9 +1,9 @@
 package server
 
 import (
-	"encoding/hex"
 	"encoding/json"
-	"net/url"
+	"fmt"
+	"sync"
 
 	"github.com/cosmos/cosmos-sdk/baseapp"
 	abci "github.com/tendermint/tendermint/abci/types"
@@ -11,11 +11,23 @@ import (
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	"github.com/tendermint/tendermint/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
 )
 
-var _ OracleServer = LocalOracleServer{}
+var _ OracleServer = &LocalOracleServer{}
 
+// LocalOracleServer serves the blocks added to it from a local app. Requests
+// without a height are served the block given to the constructor.
 type LocalOracleServer struct {
+	app           *baseapp.BaseApp
+	defaultHeight int64
+
+	mtx    sync.RWMutex
+	blocks map[int64]localBlock
+}
+
+type localBlock struct {
 	app   *baseapp.BaseApp
 	block *types.Block
 	vals  []*types.Validator
@@ -23,66 +35,109 @@ type LocalOracleServer struct {
 }
 
 func NewLocalOracleServer(app *baseapp.BaseApp, block *types.Block, vals []*types.Validator, cp *tmproto.ConsensusParams) *LocalOracleServer {
+	s := &LocalOracleServer{
+		app:           app,
+		defaultHeight: block.Height,
+		blocks:        map[int64]localBlock{},
+	}
+	s.AddBlock(block, vals, cp)
+	return s
+}
+
+// AddBlock makes the server serve block. The app must still hold the state
+// of the previous height when the block is requested.
+func (s *LocalOracleServer) AddBlock(block *types.Block, vals []*types.Validator, cp *tmproto.ConsensusParams) {
 	if cp == nil {
 		cp = &tmproto.ConsensusParams{}
 	}
-	return &LocalOracleServer{
-		app:   app,
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.blocks[block.Height] = localBlock{
+		app:   s.app,
 		block: block,
 		vals:  vals,
 		cp:    cp,
 	}
 }
 
-func (s LocalOracleServer) Get(key []byte) []byte {
-	u, err := url.Parse(string(key))
+// SetApp makes the server serve the blocks added after it from app, e.g. an
+// app restarted with store upgrades. Blocks that were already added are still
+// served from their app.
+func (s *LocalOracleServer) SetApp(app *baseapp.BaseApp) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.app = app
+}
+
+func (s *LocalOracleServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("local", req.Kind)
+	res, err := s.handle(req)
 	if err != nil {
 		panic(err)
 	}
-	switch u.Path {
-	case "block":
+	return res
+}
+
+func (s *LocalOracleServer) handle(req oracletypes.Request) ([]byte, error) {
+	height := req.Height
+	if height == 0 {
+		height = s.defaultHeight
+	}
+	s.mtx.RLock()
+	b, ok := s.blocks[height]
+	s.mtx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("server has no block at height %d", height)
+	}
+
+	switch req.Kind {
+	case oracletypes.KindBlock:
 		result := ctypes.ResultBlock{
-			Block: s.block,
+			Block: b.block,
 		}
-		return toRawJson(result)
-	case "consensus_params":
+		return toRawJson(result), nil
+	case oracletypes.KindConsensusParams:
+		// the heights of the results are the ones of RPCOracleServer
 		result := ctypes.ResultConsensusParams{
-			BlockHeight:     s.block.Height - 1,
-			ConsensusParams: *s.cp,
+			BlockHeight:     height,
+			ConsensusParams: *b.cp,
 		}
-		return toRawJson(result)
-	case "validators":
+		return toRawJson(result), nil
+	case oracletypes.KindValidators:
 		result := ctypes.ResultValidators{
-			BlockHeight: s.block.Height,
-			Validators:  s.vals,
-			Count:       len(s.vals),
-			Total:       len(s.vals),
-		}
-		return toRawJson(result)
-	case "abci_query":
-		m, err := url.ParseQuery(u.RawQuery)
-		if err != nil {
-			panic(err)
+			BlockHeight: height - 1,
+			Validators:  b.vals,
+			Count:       len(b.vals),
+			Total:       len(b.vals),
 		}
-		data, err := hex.DecodeString(m["data"][0])
-		if err != nil {
-			panic(err)
+		return toRawJson(result), nil
+	case oracletypes.KindABCIQuery:
+		return toRawJson(queryApp(b.app, height, req.Queries[0])), nil
+	case oracletypes.KindABCIQueryBatch:
+		results := make([]interface{}, len(req.Queries))
+		for i, q := range req.Queries {
+			results[i] = queryApp(b.app, height, q)
 		}
+		return oracletypes.EncodeBatchResponse(results)
+	default:
+		return nil, fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind)
+	}
+}
 
-		res := s.app.Query(abci.RequestQuery{
-			Data:   data,
-			Path:   m["path"][0],
-			Height: s.block.Header.Height - 1,
-			Prove:  true,
-		})
-
-		result := ctypes.ResultABCIQuery{
-			Response: res,
-		}
+func queryApp(app *baseapp.BaseApp, height int64, q oracletypes.Query) ctypes.ResultABCIQuery {
+	res := app.Query(abci.RequestQuery{
+		Data:   q.Data,
+		Path:   q.Path,
+		Height: height - 1,
+		Prove:  true,
+	})
 
-		return toRawJson(result)
-	default:
-		panic("not supported")
+	return ctypes.ResultABCIQuery{
+		Response: res,
 	}
 }
 
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var _ OracleServer = &PrefetchOracleServer{}
+
+// PrefetchOracleServer caches the responses of another server. The cache
+// can be filled in parallel before execution with Prefetch.
+type PrefetchOracleServer struct {
+	server OracleServer
+	// batchSize is the number of abci_query keys a worker fetches at once.
+	batchSize int
+
+	mtx   sync.RWMutex
+	cache map[string][]byte
+
+	hits   uint64
+	misses uint64
+}
+
+func NewPrefetchOracleServer(server OracleServer) *PrefetchOracleServer {
+	return &PrefetchOracleServer{
+		server:    server,
+		batchSize: 1,
+		cache:     map[string][]byte{},
+	}
+}
+
+// SetBatchSize makes Prefetch request abci_query keys in batches of size n.
+// The underlying server must support abci_query_batch keys.
+func (s *PrefetchOracleServer) SetBatchSize(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.batchSize = n
+}
+
+func (s *PrefetchOracleServer) Get(key []byte) []byte {
+	s.mtx.RLock()
+	value, ok := s.cache[string(key)]
+	s.mtx.RUnlock()
+	countCache("prefetch", ok)
+	if ok {
+		atomic.AddUint64(&s.hits, 1)
+		return value
+	}
+
+	atomic.AddUint64(&s.misses, 1)
+	value = s.server.Get(key)
+	s.set(key, value)
+	return value
+}
+
+// Prefetch fetches the keys that are not cached yet with the given number of
+// workers and returns how many keys were fetched. Keys the underlying server
+// fails to serve are skipped; they are requested again when they are needed.
+func (s *PrefetchOracleServer) Prefetch(keys [][]byte, workers int) int {
+	if workers < 1 {
+		workers = 1
+	}
+
+	missing := make(chan [][]byte)
+	fetched := uint64(0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range missing {
+				atomic.AddUint64(&fetched, uint64(s.fetch(chunk)))
+			}
+		}()
+	}
+
+	seen := map[string]struct{}{}
+	chunk := [][]byte{}
+	for _, key := range keys {
+		if _, ok := seen[string(key)]; ok || s.has(key) {
+			continue
+		}
+		seen[string(key)] = struct{}{}
+		if req, err := oracletypes.DecodeRequest(key); err != nil || req.Kind != oracletypes.KindABCIQuery {
+			missing <- [][]byte{key}
+			continue
+		}
+		chunk = append(chunk, key)
+		if len(chunk) == s.batchSize {
+			missing <- chunk
+			chunk = [][]byte{}
+		}
+	}
+	if len(chunk) > 0 {
+		missing <- chunk
+	}
+	close(missing)
+	wg.Wait()
+
+	return int(fetched)
+}
+
+// Stats returns the number of Get calls served from and missing in the cache.
+func (s *PrefetchOracleServer) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&s.hits), atomic.LoadUint64(&s.misses)
+}
+
+// fetch requests keys, as a batch if there is more than one, and returns the
+// number of keys that were cached.
+func (s *PrefetchOracleServer) fetch(keys [][]byte) (n int) {
+	defer func() {
+		if r := recover(); r != nil {
+			n = 0
+		}
+	}()
+	if len(keys) == 1 {
+		s.set(keys[0], s.server.Get(keys[0]))
+		return 1
+	}
+
+	queries := []oracletypes.Query{}
+	reqs := make([]oracletypes.Request, len(keys))
+	for i, key := range keys {
+		req, err := oracletypes.DecodeRequest(key)
+		if err != nil || (i > 0 && req.Height != reqs[0].Height) {
+			return 0
+		}
+		reqs[i] = req
+		queries = append(queries, req.Queries...)
+	}
+	key, err := oracletypes.EncodeRequest(oracletypes.NewABCIQueryBatchRequest(reqs[0].Height, queries))
+	if err != nil {
+		return 0
+	}
+	values, err := oracletypes.DecodeBatchResponse(s.server.Get(key))
+	if err != nil || len(values) != len(keys) {
+		return 0
+	}
+	for i := range keys {
+		s.set(keys[i], values[i])
+	}
+	return len(keys)
+}
+
+func (s *PrefetchOracleServer) has(key []byte) bool {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	_, ok := s.cache[string(key)]
+	return ok
+}
+
+func (s *PrefetchOracleServer) set(key, value []byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.cache[string(key)] = value
+}
@@ -4,22 +4,46 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	abci "github.com/tendermint/tendermint/abci/types"
 	ocjson "github.com/tendermint/tendermint/libs/json"
 	rpcclient "github.com/tendermint/tendermint/rpc/client"
-	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 	octypes "github.com/tendermint/tendermint/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/witness"
 )
 
 var _ OracleServer = &RPCOracleServer{}
 
+// RPCOracleServer serves the data of any block whose hash is trusted or can
+// be derived from a trusted hash of a later block through LastBlockID. The
+// data of each height is fetched and verified lazily and kept for later
+// requests, so a single server can be shared by many executions.
 type RPCOracleServer struct {
+	rpc     RPCClient
+	basedir string
+	// defaultHeight is used for requests without a height.
+	defaultHeight int64
+	// nodes, if set, answers node queries without fetching them.
+	nodes *witness.NodeStore
+
+	mtx     sync.Mutex
+	trusted map[int64][]byte
+	heights map[int64]*rpcHeight
+}
+
+// rpcHeight holds the data needed to execute the block of one height.
+type rpcHeight struct {
+	mtx sync.Mutex
 	// rpc
 	rpc *CacheHttp
 	// trusted data
@@ -29,75 +53,313 @@ type RPCOracleServer struct {
 	verifiedCommit     *ctypes.ResultCommit
 	verifiedValidators *ctypes.ResultValidators
 	verifiedBlock      *ctypes.ResultBlock
+	// verifiedConsensusParams are only fetched when requested
+	verifiedConsensusParams *ctypes.ResultConsensusParams
+	nodes                   *witness.NodeIndex
 }
 
-func NewRPCOracleServer(trustHeight int, trustBlockHash string, rpcAddr string, basedir string) (*RPCOracleServer, error) {
-	trustHashBytes, err := hex.DecodeString(trustBlockHash)
+func NewRPCOracleServer(trustHeight int, trustBlockHash string, rpcAddrs []string, basedir string) (*RPCOracleServer, error) {
+	pool, err := NewRPCPool(rpcAddrs, DefaultRPCPoolConfig())
 	if err != nil {
 		return nil, err
 	}
+	return NewRPCOracleServerWithClient(trustHeight, trustBlockHash, pool, basedir)
+}
 
-	c, err := rpchttp.New(rpcAddr, "/websocket")
+// NewRPCOracleServerWithClient returns a server that trusts trustBlockHash,
+// serves trustHeight to requests without a height and has already verified
+// the data of trustHeight.
+func NewRPCOracleServerWithClient(trustHeight int, trustBlockHash string, c RPCClient, basedir string) (*RPCOracleServer, error) {
+	trustHashBytes, err := hex.DecodeString(trustBlockHash)
 	if err != nil {
 		return nil, err
 	}
 
-	basedir = fmt.Sprintf("%s/output/%d", basedir, trustHeight)
+	server := NewHeightAgnosticRPCOracleServer(c, basedir)
+	server.defaultHeight = int64(trustHeight)
+	if err = server.Trust(int64(trustHeight), trustHashBytes); err != nil {
+		return nil, err
+	}
+	if err = server.Verify(int64(trustHeight)); err != nil {
+		return nil, err
+	}
+
+	return server, nil
+}
 
-	server := RPCOracleServer{
-		rpc:            NewCacheHttp(c, basedir),
-		trustHeight:    int64(trustHeight),
-		trustBlockHash: trustHashBytes,
+// NewHeightAgnosticRPCOracleServer returns a server without trusted hashes
+// and without a default height. Trust must be called before requests can be
+// served.
+func NewHeightAgnosticRPCOracleServer(c RPCClient, basedir string) *RPCOracleServer {
+	return &RPCOracleServer{
+		rpc:     c,
+		basedir: basedir,
+		trusted: map[int64][]byte{},
+		heights: map[int64]*rpcHeight{},
 	}
+}
 
-	err = server.setVerifiedBlock()
-	if err != nil {
-		return nil, err
+// Trust adds a trusted block hash. The hashes of all lower heights are
+// derived from it when they are requested.
+func (s *RPCOracleServer) Trust(height int64, blockHash []byte) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if trusted, ok := s.trusted[height]; ok && !bytes.Equal(trusted, blockHash) {
+		return fmt.Errorf("conflicting block hashes at height %d: %X and %X", height, trusted, blockHash)
 	}
-	err = server.setVerifiedCommit()
+	s.trusted[height] = blockHash
+	return nil
+}
+
+// TrustDownTo derives the trusted hashes of all heights from the nearest
+// trusted one above down to height in one walk, e.g. before executing many
+// heights below a trusted one concurrently. The blocks walked through are not
+// kept.
+func (s *RPCOracleServer) TrustDownTo(height int64) error {
+	_, err := s.trustedHash(height)
+	return err
+}
+
+// Verify fetches and verifies the block of height and the commit and
+// validators of the previous height.
+func (s *RPCOracleServer) Verify(height int64) error {
+	_, err := s.verified(height)
+	return err
+}
+
+// VerifiedBlock returns the verified block of height.
+func (s *RPCOracleServer) VerifiedBlock(height int64) (*ctypes.ResultBlock, error) {
+	h, err := s.verifiedBlock(height)
 	if err != nil {
 		return nil, err
 	}
-	err = server.setVerifiedValidators()
-	if err != nil {
-		return nil, err
+	return h.verifiedBlock, nil
+}
+
+// SetNodeStore makes the server store the proof nodes of all heights in
+// nodes and answer the node queries it has the nodes for from there. Node
+// queries answered this way are neither fetched nor cached in basedir. It
+// must be called before the server serves requests.
+func (s *RPCOracleServer) SetNodeStore(nodes *witness.NodeStore) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.nodes = nodes
+	for _, h := range s.heights {
+		h.nodes = nodes.Index()
 	}
+}
 
-	return &server, nil
+// Forget drops the verified data of height. The trusted hash is kept.
+func (s *RPCOracleServer) Forget(height int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.heights, height)
 }
 
 func (s *RPCOracleServer) Get(key []byte) []byte {
-	u, err := url.Parse(string(key))
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("rpc", req.Kind)
+	res, err := s.handle(req)
 	if err != nil {
 		panic(err)
 	}
-	switch u.Path {
-	case "block":
-		return toRawJson(s.verifiedBlock)
-	case "validators":
-		return toRawJson(s.verifiedValidators)
-	case "abci_query":
-		res, err := s.getVerifiedABCIQuery(u)
+	return res
+}
+
+func (s *RPCOracleServer) handle(req oracletypes.Request) ([]byte, error) {
+	height := req.Height
+	if height == 0 {
+		height = s.defaultHeight
+	}
+	if height <= 0 {
+		return nil, fmt.Errorf("%s request has no height and the server has no default height", req.Kind)
+	}
+	if req.Kind == oracletypes.KindBlock {
+		// a block is verified by its hash alone, e.g. the block below an
+		// executed one
+		h, err := s.verifiedBlock(height)
 		if err != nil {
-			panic(err)
+			return nil, err
+		}
+		return toRawJson(h.verifiedBlock), nil
+	}
+	h, err := s.verified(height)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Kind {
+	case oracletypes.KindValidators:
+		return toRawJson(h.verifiedValidators), nil
+	case oracletypes.KindConsensusParams:
+		res, err := h.getVerifiedConsensusParams()
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(res), nil
+	case oracletypes.KindABCIQuery:
+		res, err := h.getVerifiedABCIQuery(req.Queries[0])
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(res), nil
+	case oracletypes.KindABCIQueryBatch:
+		res, err := h.getVerifiedABCIQueryBatch(req.Queries)
+		if err != nil {
+			return nil, err
 		}
-		return toRawJson(res)
+		vs := make([]interface{}, len(res))
+		for i := range res {
+			vs[i] = res[i]
+		}
+		return oracletypes.EncodeBatchResponse(vs)
 	default:
-		panic("not supported")
+		return nil, fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind)
 	}
 }
 
-func (s *RPCOracleServer) setVerifiedBlock() error {
-	resultBlock, err := s.rpc.Block(&s.trustHeight)
+func (s *RPCOracleServer) height(height int64) *rpcHeight {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	h, ok := s.heights[height]
+	if !ok {
+		h = &rpcHeight{
+			rpc:         NewCacheHttp(s.rpc, fmt.Sprintf("%s/output/%d", s.basedir, height)),
+			trustHeight: height,
+		}
+		if s.nodes != nil {
+			h.nodes = s.nodes.Index()
+		}
+		s.heights[height] = h
+	}
+	return h
+}
+
+// verifiedBlock verifies the block of height and trusts the hash of the
+// previous block it contains.
+func (s *RPCOracleServer) verifiedBlock(height int64) (*rpcHeight, error) {
+	h := s.height(height)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.verifiedBlock != nil {
+		return h, nil
+	}
+
+	hash, err := s.trustedHash(height)
 	if err != nil {
+		return nil, err
+	}
+	h.trustBlockHash = hash
+	if err = h.setVerifiedBlock(); err != nil {
+		return nil, err
+	}
+	if height > 1 {
+		if err = s.Trust(height-1, h.verifiedBlock.Block.LastBlockID.Hash); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (s *RPCOracleServer) verified(height int64) (*rpcHeight, error) {
+	h, err := s.verifiedBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if h.verifiedCommit == nil {
+		if err = h.setVerifiedCommit(); err != nil {
+			return nil, err
+		}
+	}
+	if h.verifiedValidators == nil {
+		if err = h.setVerifiedValidators(); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// trustedHash returns the trusted hash of height. If there is none, it is
+// derived by walking down the LastBlockIDs from the nearest trusted height
+// above.
+func (s *RPCOracleServer) trustedHash(height int64) ([]byte, error) {
+	s.mtx.Lock()
+	hash, ok := s.trusted[height]
+	above := int64(0)
+	for h := range s.trusted {
+		if h > height && (above == 0 || h < above) {
+			above = h
+		}
+	}
+	s.mtx.Unlock()
+	if ok {
+		return hash, nil
+	}
+	if above == 0 {
+		return nil, fmt.Errorf("no trusted block hash at or above height %d", height)
+	}
+
+	for h := above; h > height; h-- {
+		if err := s.trustParent(h); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.trusted[height], nil
+}
+
+// trustParent trusts the hash of the block before the trusted block of
+// height. The block of height is verified for it, but only kept if the data
+// of height is already held.
+func (s *RPCOracleServer) trustParent(height int64) error {
+	s.mtx.Lock()
+	_, held := s.heights[height]
+	_, trusted := s.trusted[height-1]
+	hash := s.trusted[height]
+	s.mtx.Unlock()
+	if trusted || height <= 1 {
+		return nil
+	}
+	if held {
+		_, err := s.verifiedBlock(height)
 		return err
 	}
 
-	if err = resultBlock.BlockID.ValidateBasic(); err != nil {
+	h := &rpcHeight{
+		rpc:            NewCacheHttp(s.rpc, fmt.Sprintf("%s/output/%d", s.basedir, height)),
+		trustHeight:    height,
+		trustBlockHash: hash,
+	}
+	if err := h.setVerifiedBlock(); err != nil {
 		return err
 	}
+	return s.Trust(height-1, h.verifiedBlock.Block.LastBlockID.Hash)
+}
 
-	if err = resultBlock.Block.ValidateBasic(); err != nil {
+func (s *rpcHeight) setVerifiedBlock() error {
+	resultBlock, err := s.rpc.Block(&s.trustHeight, s.verifyBlock)
+	if err != nil {
+		return err
+	}
+	s.verifiedBlock = resultBlock
+	return nil
+}
+
+func (s *rpcHeight) verifyBlock(resultBlock *ctypes.ResultBlock) error {
+	defer observeVerification("block", time.Now())
+
+	if err := resultBlock.BlockID.ValidateBasic(); err != nil {
+		return err
+	}
+
+	if err := resultBlock.Block.ValidateBasic(); err != nil {
 		return err
 	}
 
@@ -111,23 +373,27 @@ func (s *RPCOracleServer) setVerifiedBlock() error {
 		return errors.New("block hash does not match")
 	}
 
-	s.verifiedBlock = resultBlock
-
 	return nil
 }
 
-func (s *RPCOracleServer) setVerifiedCommit() error {
+func (s *rpcHeight) setVerifiedCommit() error {
 	if s.verifiedBlock == nil {
 		return errors.New("verified block is nil")
 	}
 
 	preHeight := s.trustHeight - 1
-	res, err := s.rpc.Commit(&preHeight)
+	res, err := s.rpc.Commit(&preHeight, s.verifyCommit)
 	if err != nil {
 		return err
 	}
+	s.verifiedCommit = res
+	return nil
+}
+
+func (s *rpcHeight) verifyCommit(res *ctypes.ResultCommit) error {
+	defer observeVerification("commit", time.Now())
 
-	if err = res.ValidateBasic(s.verifiedBlock.Block.ChainID); err != nil {
+	if err := res.ValidateBasic(s.verifiedBlock.Block.ChainID); err != nil {
 		return err
 	}
 
@@ -136,83 +402,171 @@ func (s *RPCOracleServer) setVerifiedCommit() error {
 		return errors.New("last commit hash does not match")
 	}
 
-	s.verifiedCommit = res
-
 	return nil
 }
 
-func (s *RPCOracleServer) setVerifiedValidators() error {
+func (s *rpcHeight) setVerifiedValidators() error {
 	if s.verifiedCommit == nil {
 		return errors.New("verified commit is nil")
 	}
 
 	preHeight := s.trustHeight - 1
-	page := 1
-	perPage := 100
-	vals := ctypes.ResultValidators{
-		BlockHeight: preHeight,
-	}
-	for {
-		res, err := s.rpc.Validators(&preHeight, &page, &perPage)
-		if err != nil {
-			return err
-		}
-		vals.Validators = append(vals.Validators, res.Validators...)
-		vals.Count = vals.Count + res.Count
-		vals.Total = res.Total
-		if vals.Count == vals.Total {
-			break
-		}
-		page++
+	vals, err := s.rpc.Validators(&preHeight, s.verifyValidators)
+	if err != nil {
+		return err
 	}
+	s.verifiedValidators = vals
+	return nil
+}
 
+func (s *rpcHeight) verifyValidators(vals *ctypes.ResultValidators) error {
 	// verify ResultValidators
+	defer observeVerification("validators", time.Now())
 	if !bytes.Equal(s.verifiedCommit.ValidatorsHash, octypes.NewValidatorSet(vals.Validators).Hash()) {
 		return errors.New("validators is not verified")
 	}
-
-	s.verifiedValidators = &vals
-
 	return nil
 }
 
-func (s *RPCOracleServer) getVerifiedABCIQuery(u *url.URL) (*ctypes.ResultABCIQuery, error) {
+func (s *rpcHeight) getVerifiedConsensusParams() (*ctypes.ResultConsensusParams, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.verifiedConsensusParams != nil {
+		return s.verifiedConsensusParams, nil
+	}
 	if s.verifiedBlock == nil {
 		return nil, errors.New("verified block is nil")
 	}
 
-	m, err := url.ParseQuery(u.RawQuery)
+	res, err := s.rpc.ConsensusParams(&s.trustHeight, func(res *ctypes.ResultConsensusParams) error {
+		// verify ResultConsensusParams
+		defer observeVerification("consensus_params", time.Now())
+		if !bytes.Equal(s.verifiedBlock.Block.ConsensusHash, octypes.HashConsensusParams(res.ConsensusParams)) {
+			return errors.New("consensus params do not match the consensus hash")
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := hex.DecodeString(m["data"][0])
+	s.verifiedConsensusParams = res
+
+	return res, nil
+}
+
+func (s *rpcHeight) getVerifiedABCIQuery(q oracletypes.Query) (*ctypes.ResultABCIQuery, error) {
+	if s.verifiedBlock == nil {
+		return nil, errors.New("verified block is nil")
+	}
+	if res, ok, err := s.nodeQuery(q); err != nil || ok {
+		return res, err
+	}
+
+	opts := rpcclient.ABCIQueryOptions{
+		Height: s.trustHeight - 1,
+		Prove:  true,
+	}
+	res, err := s.rpc.ABCIQueryWithOptions(q.Path, q.Data, opts, func(res *ctypes.ResultABCIQuery) error {
+		// verify ResultABCIQuery
+		defer observeVerification("abci_query", time.Now())
+		return oracletypes.VerifyABCIQuery(s.verifiedBlock.Block.AppHash, opts.Height, q, res.Response)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if err = s.addNodes(q, res.Response); err != nil {
+		return nil, err
+	}
 
-	path := m["path"][0]
+	return res, nil
+}
+
+func (s *rpcHeight) getVerifiedABCIQueryBatch(qs []oracletypes.Query) ([]*ctypes.ResultABCIQuery, error) {
+	if s.verifiedBlock == nil {
+		return nil, errors.New("verified block is nil")
+	}
+
+	res := make([]*ctypes.ResultABCIQuery, len(qs))
+	missing := []int{}
+	reqs := []ABCIQueryRequest{}
+	for i, q := range qs {
+		nodeRes, ok, err := s.nodeQuery(q)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			res[i] = nodeRes
+			continue
+		}
+		missing = append(missing, i)
+		reqs = append(reqs, ABCIQueryRequest{Path: q.Path, Data: q.Data})
+	}
+	if len(reqs) == 0 {
+		return res, nil
+	}
 
 	opts := rpcclient.ABCIQueryOptions{
 		Height: s.trustHeight - 1,
 		Prove:  true,
 	}
-	res, err := s.rpc.ABCIQueryWithOptions(path, []byte(data), opts)
+	fetched, err := s.rpc.ABCIQueryBatch(reqs, opts, func(j int, res *ctypes.ResultABCIQuery) error {
+		// verify ResultABCIQuery
+		defer observeVerification("abci_query", time.Now())
+		return oracletypes.VerifyABCIQuery(s.verifiedBlock.Block.AppHash, opts.Height, qs[missing[j]], res.Response)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO: verify ResultABCIQuery
+	for j, i := range missing {
+		if err = s.addNodes(qs[i], fetched[j].Response); err != nil {
+			return nil, err
+		}
+		res[i] = fetched[j]
+	}
 
 	return res, nil
 }
 
+// nodeQuery answers the node or key query q from the node store. The
+// response is verified like a fetched one.
+func (s *rpcHeight) nodeQuery(q oracletypes.Query) (*ctypes.ResultABCIQuery, bool, error) {
+	if s.nodes == nil || !strings.HasSuffix(q.Path, "/node") && !strings.HasSuffix(q.Path, "/key") {
+		return nil, false, nil
+	}
+	res, ok, err := s.nodes.Response(q)
+	countCache("nodes", ok)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if err = oracletypes.VerifyABCIQuery(s.verifiedBlock.Block.AppHash, s.trustHeight-1, q, res); err != nil {
+		return nil, false, err
+	}
+	// the children of the node become known
+	if err = s.nodes.Add(q, res); err != nil {
+		return nil, false, err
+	}
+	return &ctypes.ResultABCIQuery{Response: res}, true, nil
+}
+
+func (s *rpcHeight) addNodes(q oracletypes.Query, res abci.ResponseQuery) error {
+	if s.nodes == nil {
+		return nil
+	}
+	return s.nodes.Add(q, res)
+}
+
+// CacheHttp fetches responses from an RPC client and caches them in files in
+// basedir. Every response is checked by the verify function of the request
+// before it is written, and cached responses are checked again when they are
+// read.
 type CacheHttp struct {
-	rpc     *rpchttp.HTTP
+	rpc     RPCClient
 	basedir string
 }
 
-func NewCacheHttp(rpc *rpchttp.HTTP, basedir string) *CacheHttp {
+func NewCacheHttp(rpc RPCClient, basedir string) *CacheHttp {
 	if err := os.MkdirAll(basedir, os.ModePerm); err != nil {
 		panic(err)
 	}
@@ -222,134 +576,305 @@ func NewCacheHttp(rpc *rpchttp.HTTP, basedir string) *CacheHttp {
 	}
 }
 
-func (h CacheHttp) Block(height *int64) (*ctypes.ResultBlock, error) {
+func (h CacheHttp) Block(height *int64, verify func(*ctypes.ResultBlock) error) (*ctypes.ResultBlock, error) {
 	fileName := fmt.Sprintf("%s/block?height=%d.json", h.basedir, *height)
 
-	fileData, err := os.ReadFile(fileName)
-	if !errors.Is(err, os.ErrNotExist) {
-		raw := json.RawMessage(fileData)
-		result := ctypes.ResultBlock{}
-		if err := ocjson.Unmarshal(raw, &result); err != nil {
-			return nil, err
-		}
-		return &result, nil
+	result := &ctypes.ResultBlock{}
+	if ok, err := readCacheFile(fileName, result, func() error { return verify(result) }); ok || err != nil {
+		return result, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	result, err := h.rpc.Block(ctx, height)
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		if result, err = c.Block(ctx, height); err != nil {
+			return err
+		}
+		return verify(result)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(fileName)
-	if err != nil {
+	if err = writeCacheFile(fileName, result); err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	_, err = file.WriteString(string(toRawJson(result)))
+
+	return result, nil
+}
+
+func (h CacheHttp) Commit(height *int64, verify func(*ctypes.ResultCommit) error) (*ctypes.ResultCommit, error) {
+	fileName := fmt.Sprintf("%s/commit?height=%d.json", h.basedir, *height)
+
+	result := &ctypes.ResultCommit{}
+	if ok, err := readCacheFile(fileName, result, func() error { return verify(result) }); ok || err != nil {
+		return result, err
+	}
+
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		if result, err = c.Commit(ctx, height); err != nil {
+			return err
+		}
+		return verify(result)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = writeCacheFile(fileName, result); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
-func (h CacheHttp) Commit(height *int64) (*ctypes.ResultCommit, error) {
-	fileName := fmt.Sprintf("%s/commit?height=%d.json", h.basedir, *height)
+// Validators returns all validators of height. The pages are fetched from a
+// single endpoint and verify checks the whole set.
+func (h CacheHttp) Validators(height *int64, verify func(*ctypes.ResultValidators) error) (*ctypes.ResultValidators, error) {
+	perPage := 100
+	fileName := func(page int) string {
+		return fmt.Sprintf("%s/validator?height=%d&page=%d&per_page=%d.json", h.basedir, *height, page, perPage)
+	}
+	collect := func(get func(page int) (*ctypes.ResultValidators, error)) (*ctypes.ResultValidators, []*ctypes.ResultValidators, error) {
+		vals := &ctypes.ResultValidators{BlockHeight: *height}
+		pages := []*ctypes.ResultValidators{}
+		for page := 1; ; page++ {
+			res, err := get(page)
+			if err != nil {
+				return nil, nil, err
+			}
+			pages = append(pages, res)
+			vals.Validators = append(vals.Validators, res.Validators...)
+			vals.Count = vals.Count + res.Count
+			vals.Total = res.Total
+			// an empty page ends a set that falls short of its total
+			if vals.Count >= vals.Total || res.Count == 0 {
+				return vals, pages, nil
+			}
+		}
+	}
 
-	fileData, err := os.ReadFile(fileName)
-	if !errors.Is(err, os.ErrNotExist) {
-		raw := json.RawMessage(fileData)
-		result := ctypes.ResultCommit{}
-		if err := ocjson.Unmarshal(raw, &result); err != nil {
-			return nil, err
+	vals, pages, err := collect(func(page int) (*ctypes.ResultValidators, error) {
+		res := &ctypes.ResultValidators{}
+		ok, err := readCacheFile(fileName(page), res, nil)
+		if err == nil && !ok {
+			err = os.ErrNotExist
+		}
+		return res, err
+	})
+	if err == nil {
+		if err = verify(vals); err == nil {
+			return vals, nil
 		}
-		return &result, nil
+		for i := range pages {
+			if err = os.Remove(fileName(i + 1)); err != nil {
+				return nil, err
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	result, err := h.rpc.Commit(ctx, height)
+	err = h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		vals, pages, err = collect(func(page int) (*ctypes.ResultValidators, error) {
+			return c.Validators(ctx, height, &page, &perPage)
+		})
+		if err != nil {
+			return err
+		}
+		return verify(vals)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(fileName)
+	for i, res := range pages {
+		if err = writeCacheFile(fileName(i+1), res); err != nil {
+			return nil, err
+		}
+	}
+
+	return vals, nil
+}
+
+func (h CacheHttp) ConsensusParams(height *int64, verify func(*ctypes.ResultConsensusParams) error) (*ctypes.ResultConsensusParams, error) {
+	fileName := fmt.Sprintf("%s/consensus_params?height=%d.json", h.basedir, *height)
+
+	result := &ctypes.ResultConsensusParams{}
+	if ok, err := readCacheFile(fileName, result, func() error { return verify(result) }); ok || err != nil {
+		return result, err
+	}
+
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		if result, err = c.ConsensusParams(ctx, height); err != nil {
+			return err
+		}
+		return verify(result)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	_, err = file.WriteString(string(toRawJson(result)))
-	if err != nil {
+
+	if err = writeCacheFile(fileName, result); err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
 
-func (h CacheHttp) Validators(height *int64, page, perPage *int) (*ctypes.ResultValidators, error) {
-	fileName := fmt.Sprintf("%s/validator?height=%d&page=%d&per_page=%d.json", h.basedir, *height, *page, *perPage)
+// BlockResults returns the results of the block of height. They are not
+// verified.
+func (h CacheHttp) BlockResults(height *int64) (*ctypes.ResultBlockResults, error) {
+	fileName := fmt.Sprintf("%s/block_results?height=%d.json", h.basedir, *height)
 
-	fileData, err := os.ReadFile(fileName)
-	if !errors.Is(err, os.ErrNotExist) {
-		raw := json.RawMessage(fileData)
-		result := ctypes.ResultValidators{}
-		if err := ocjson.Unmarshal(raw, &result); err != nil {
-			return nil, err
-		}
-		return &result, nil
+	result := &ctypes.ResultBlockResults{}
+	if ok, err := readCacheFile(fileName, result, nil); ok || err != nil {
+		return result, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	result, err := h.rpc.Validators(ctx, height, page, perPage)
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		result, err = c.BlockResults(ctx, height)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(fileName)
-	if err != nil {
+	if err = writeCacheFile(fileName, result); err != nil {
 		return nil, err
 	}
-	defer file.Close()
-	_, err = file.WriteString(string(toRawJson(result)))
+
+	return result, nil
+}
+
+func (h CacheHttp) ABCIQueryWithOptions(path string, data []byte, opts rpcclient.ABCIQueryOptions, verify func(*ctypes.ResultABCIQuery) error) (*ctypes.ResultABCIQuery, error) {
+	fileName := h.abciQueryFileName(path, data, opts)
+
+	result := &ctypes.ResultABCIQuery{}
+	if ok, err := readCacheFile(fileName, result, func() error { return verify(result) }); ok || err != nil {
+		return result, err
+	}
+
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		if result, err = c.ABCIQueryWithOptions(ctx, path, data, opts); err != nil {
+			return err
+		}
+		if err = prunedError(result); err != nil {
+			return err
+		}
+		return verify(result)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err = writeCacheFile(fileName, result); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
-func (h CacheHttp) ABCIQueryWithOptions(path string, data []byte, opts rpcclient.ABCIQueryOptions) (*ctypes.ResultABCIQuery, error) {
-	fileName := fmt.Sprintf("%s/abci_query?path=%s&data=%x&height=%d&prove=%v.json", h.basedir, url.QueryEscape(path), data, opts.Height, opts.Prove)
-
-	fileData, err := os.ReadFile(fileName)
-	if !errors.Is(err, os.ErrNotExist) {
-		raw := json.RawMessage(fileData)
-		result := ctypes.ResultABCIQuery{}
-		if err := ocjson.Unmarshal(raw, &result); err != nil {
+// ABCIQueryBatch serves the cached queries from files and fetches the rest in
+// a single batch. verify is called with the index of each query in reqs.
+func (h CacheHttp) ABCIQueryBatch(reqs []ABCIQueryRequest, opts rpcclient.ABCIQueryOptions, verify func(int, *ctypes.ResultABCIQuery) error) ([]*ctypes.ResultABCIQuery, error) {
+	results := make([]*ctypes.ResultABCIQuery, len(reqs))
+	missing := []int{}
+	for i, req := range reqs {
+		result := &ctypes.ResultABCIQuery{}
+		ok, err := readCacheFile(h.abciQueryFileName(req.Path, req.Data, opts), result, func() error { return verify(i, result) })
+		if err != nil {
 			return nil, err
 		}
-		return &result, nil
+		if !ok {
+			missing = append(missing, i)
+			continue
+		}
+		results[i] = result
+	}
+	if len(missing) == 0 {
+		return results, nil
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	result, err := h.rpc.ABCIQueryWithOptions(ctx, path, data, opts)
+	missingReqs := make([]ABCIQueryRequest, len(missing))
+	for j, i := range missing {
+		missingReqs[j] = reqs[i]
+	}
+	var fetched []*ctypes.ResultABCIQuery
+	err := h.fetch(func(ctx context.Context, c RPCClient) (err error) {
+		if fetched, err = abciQueryBatch(ctx, c, missingReqs, opts); err != nil {
+			return err
+		}
+		if len(fetched) != len(missingReqs) {
+			return fmt.Errorf("batch returned %d results for %d queries", len(fetched), len(missingReqs))
+		}
+		for j, i := range missing {
+			if err = prunedError(fetched[j]); err != nil {
+				return err
+			}
+			if err = verify(i, fetched[j]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	file, err := os.Create(fileName)
-	if err != nil {
-		return nil, err
+	for j, i := range missing {
+		results[i] = fetched[j]
+		err = writeCacheFile(h.abciQueryFileName(reqs[i].Path, reqs[i].Data, opts), fetched[j])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func (h CacheHttp) abciQueryFileName(path string, data []byte, opts rpcclient.ABCIQueryOptions) string {
+	return fmt.Sprintf("%s/abci_query?path=%s&data=%x&height=%d&prove=%v.json", h.basedir, url.QueryEscape(path), data, opts.Height, opts.Prove)
+}
+
+// fetch calls fn with the RPC client. With an RPCPool, a failed call,
+// including one whose response fails verification, is retried on the next
+// endpoint.
+func (h CacheHttp) fetch(fn func(context.Context, RPCClient) error) error {
+	if pool, ok := h.rpc.(*RPCPool); ok {
+		return pool.do(context.Background(), fn)
+	}
+	return fn(context.Background(), h.rpc)
+}
+
+// readCacheFile reads the response cached in fileName into v and checks it
+// with verify, if not nil. It returns false if there is no cached response. A
+// cached response that cannot be read or fails verification is removed, so
+// that it is fetched again.
+func readCacheFile(fileName string, v interface{}, verify func() error) (bool, error) {
+	fileData, err := os.ReadFile(fileName)
+	countCache("http", !errors.Is(err, os.ErrNotExist))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err = ocjson.Unmarshal(fileData, v); err == nil && verify != nil {
+		err = verify()
 	}
-	defer file.Close()
-	_, err = file.WriteString(string(toRawJson(result)))
 	if err != nil {
-		return nil, err
+		return false, os.Remove(fileName)
 	}
+	return true, nil
+}
 
-	return result, nil
+// writeCacheFile writes v, which has just been fetched and verified, to
+// fileName.
+func writeCacheFile(fileName string, v interface{}) error {
+	bz := toRawJson(v)
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(bz)
+	return err
 }
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp/mockrpc"
+)
+
+func newMockChain(t *testing.T, numValidators int, height int64) *testapp.Chain {
+	chain, err := testapp.NewChain(0, numValidators)
+	require.NoError(t, err)
+	for h := int64(1); h <= height; h++ {
+		_, err = chain.NextBlock(testapp.BlockOptions{Txs: 4})
+		require.NoError(t, err)
+	}
+	return chain
+}
+
+func newMockRPCOracleServer(t *testing.T, mock *mockrpc.Server, chain *testapp.Chain, height int64, basedir string) (*RPCOracleServer, error) {
+	config := DefaultRPCPoolConfig()
+	config.MaxRetries = 0
+	config.RequestTimeout = 100 * time.Millisecond
+	pool, err := NewRPCPool([]string{mock.URL}, config)
+	require.NoError(t, err)
+	return NewRPCOracleServerWithClient(int(height), hex.EncodeToString(chain.BlockID(height).Hash), pool, basedir)
+}
+
+func TestRPCOracleServerWithMockRPC(t *testing.T) {
+	// more validators than fit in one page
+	chain := newMockChain(t, 105, 8)
+	mock := mockrpc.NewServer(chain)
+	defer mock.Close()
+	basedir := t.TempDir()
+
+	fetched := testutil.ToFloat64(metrics.BytesFetched.WithLabelValues("validators"))
+	server, err := newMockRPCOracleServer(t, mock, chain, 8, basedir)
+	require.NoError(t, err)
+	require.NoError(t, server.Verify(5))
+	block, err := server.VerifiedBlock(5)
+	require.NoError(t, err)
+	require.Equal(t, chain.Block(5).Hash(), block.Block.Hash())
+	require.Equal(t, 4, mock.Calls("validators"))
+	cp := ctypes.ResultConsensusParams{}
+	require.NoError(t, oracletypes.DecodeResponse(server.Get(oracletypes.MustEncodeRequest(oracletypes.NewConsensusParamsRequest(5))), &cp))
+	require.Equal(t, chain.ConsensusParams(), cp.ConsensusParams)
+
+	require.Greater(t, testutil.ToFloat64(metrics.BytesFetched.WithLabelValues("validators")), fetched)
+
+	// verified data is served from the cache
+	calls := mock.Calls("block") + mock.Calls("commit") + mock.Calls("validators")
+	server, err = newMockRPCOracleServer(t, mock, chain, 8, basedir)
+	require.NoError(t, err)
+	require.NoError(t, server.Verify(5))
+	require.Equal(t, calls, mock.Calls("block")+mock.Calls("commit")+mock.Calls("validators"))
+}
+
+func TestRPCOracleServerTrustDownTo(t *testing.T) {
+	chain := newMockChain(t, 4, 8)
+	mock := mockrpc.NewServer(chain)
+	defer mock.Close()
+
+	server, err := newMockRPCOracleServer(t, mock, chain, 8, t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, server.TrustDownTo(3))
+	// the blocks above 3 are fetched once and not kept
+	require.Equal(t, 5, mock.Calls("block"))
+	require.Len(t, server.heights, 1)
+	for h := int64(3); h <= 8; h++ {
+		require.Equal(t, chain.BlockID(h).Hash.Bytes(), server.trusted[h], "height %d", h)
+	}
+
+	// a walked block is fetched again from the disk cache
+	block, err := server.VerifiedBlock(5)
+	require.NoError(t, err)
+	require.Equal(t, chain.Block(5).Hash(), block.Block.Hash())
+	require.Equal(t, 5, mock.Calls("block"))
+}
+
+func TestRPCOracleServerMockRPCFaults(t *testing.T) {
+	chain := newMockChain(t, 4, 8)
+	for _, tc := range []struct {
+		name   string
+		faults mockrpc.Faults
+		height int64
+		err    string
+	}{
+		{"wrong block hash", mockrpc.Faults{WrongBlockHash: true}, 8, "block hash does not match"},
+		{"truncated validators", mockrpc.Faults{TruncateValidators: true}, 8, "validators is not verified"},
+		{"pruned height", mockrpc.Faults{PrunedBelow: 5}, 4, "is not available"},
+		{"slow responses", mockrpc.Faults{Delay: time.Second}, 8, "deadline exceeded"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mock := mockrpc.NewServer(chain)
+			defer mock.Close()
+			mock.SetFaults(tc.faults)
+
+			server, err := newMockRPCOracleServer(t, mock, chain, 8, t.TempDir())
+			if err == nil {
+				err = server.Verify(tc.height)
+			}
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/snapshots"
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/iavl"
+	protoio "github.com/gogo/protobuf/io"
+	gogotypes "github.com/gogo/protobuf/types"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	"github.com/tendermint/tendermint/libs/log"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var _ OracleServer = &SnapshotOracleServer{}
+
+// SnapshotOracleServer serves the ABCI queries of the block following a
+// state-sync snapshot from the state restored from it. Other requests are
+// forwarded to a fallback server, usually one that verifies blocks over RPC.
+type SnapshotOracleServer struct {
+	cms      *rootmulti.Store
+	height   int64
+	fallback OracleServer
+
+	// mtx serializes the store queries
+	mtx sync.Mutex
+}
+
+// NewSnapshotOracleServer restores the snapshot of height and format from
+// store into db. The restored app hash must equal appHash, the app hash in
+// the trusted header of the block following the snapshot. If appHash is nil,
+// it is taken from the block served by fallback.
+func NewSnapshotOracleServer(store *snapshots.Store, height uint64, format uint32, db dbm.DB, appHash []byte, fallback OracleServer) (*SnapshotOracleServer, error) {
+	if appHash == nil && fallback == nil {
+		return nil, fmt.Errorf("snapshot at height %d requires a trusted app hash or a fallback server", height)
+	}
+	snapshot, chunks, err := store.Load(height, format)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("no snapshot at height %d in format %d", height, format)
+	}
+	reader, err := snapshots.NewStreamReader(chunks)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	names, err := restoreSnapshot(reader, int64(height), db)
+	if err != nil {
+		return nil, err
+	}
+	cms := rootmulti.NewStore(db, log.NewNopLogger())
+	for _, name := range names {
+		cms.MountStoreWithDB(storetypes.NewKVStoreKey(name), storetypes.StoreTypeIAVL, nil)
+	}
+	if err = cms.LoadLatestVersion(); err != nil {
+		return nil, err
+	}
+
+	s := &SnapshotOracleServer{
+		cms:      cms,
+		height:   int64(height),
+		fallback: fallback,
+	}
+	if appHash == nil {
+		block := ctypes.ResultBlock{}
+		if err = tmjson.Unmarshal(fallback.Get(oracletypes.MustEncodeRequest(oracletypes.NewBlockRequest(s.height+1))), &block); err != nil {
+			return nil, err
+		}
+		appHash = block.Block.AppHash
+	}
+	if err = s.VerifyAppHash(appHash); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// restoreSnapshot imports the stores of a snapshot into db like
+// rootmulti.Store.Restore, which requires the stores to be mounted up front,
+// and returns their names. The snapshot is read only once.
+func restoreSnapshot(reader protoio.Reader, height int64, db dbm.DB) ([]string, error) {
+	cInfo := &storetypes.CommitInfo{Version: height}
+	var tree *iavl.MutableTree
+	var importer *iavl.Importer
+	commit := func() error {
+		if importer == nil {
+			return nil
+		}
+		defer importer.Close()
+		if err := importer.Commit(); err != nil {
+			return fmt.Errorf("IAVL commit failed: %w", err)
+		}
+		hash, err := tree.Hash()
+		if err != nil {
+			return err
+		}
+		cInfo.StoreInfos[len(cInfo.StoreInfos)-1].CommitId.Hash = hash
+		return nil
+	}
+
+loop:
+	for {
+		item := snapshottypes.SnapshotItem{}
+		err := reader.ReadMsg(&item)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid protobuf message: %w", err)
+		}
+
+		switch i := item.Item.(type) {
+		case *snapshottypes.SnapshotItem_Store:
+			if err = commit(); err != nil {
+				return nil, err
+			}
+			// the prefix of the store in rootmulti.Store
+			tree, err = iavl.NewMutableTree(dbm.NewPrefixDB(db, []byte("s/k:"+i.Store.Name+"/")), 0, true)
+			if err != nil {
+				return nil, err
+			}
+			if importer, err = tree.Import(height); err != nil {
+				return nil, fmt.Errorf("import of store %s failed: %w", i.Store.Name, err)
+			}
+			cInfo.StoreInfos = append(cInfo.StoreInfos, storetypes.StoreInfo{
+				Name:     i.Store.Name,
+				CommitId: storetypes.CommitID{Version: height},
+			})
+		case *snapshottypes.SnapshotItem_IAVL:
+			if importer == nil {
+				return nil, fmt.Errorf("received IAVL node item before store item")
+			}
+			if i.IAVL.Height > math.MaxInt8 {
+				return nil, fmt.Errorf("node height %d cannot exceed %d", i.IAVL.Height, math.MaxInt8)
+			}
+			node := &iavl.ExportNode{
+				Key:     i.IAVL.Key,
+				Value:   i.IAVL.Value,
+				Height:  int8(i.IAVL.Height),
+				Version: i.IAVL.Version,
+			}
+			// protobuf decodes empty keys and values as nil
+			if node.Key == nil {
+				node.Key = []byte{}
+			}
+			if node.Height == 0 && node.Value == nil {
+				node.Value = []byte{}
+			}
+			if err = importer.Add(node); err != nil {
+				return nil, fmt.Errorf("IAVL node import failed: %w", err)
+			}
+		default:
+			// extension payloads follow the stores
+			break loop
+		}
+	}
+	if err := commit(); err != nil {
+		return nil, err
+	}
+
+	// the commit info and latest version of rootmulti.Store
+	bz, err := cInfo.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := gogotypes.StdInt64Marshal(height)
+	if err != nil {
+		return nil, err
+	}
+	batch := db.NewBatch()
+	defer batch.Close()
+	if err = batch.Set([]byte(fmt.Sprintf("s/%d", height)), bz); err != nil {
+		return nil, err
+	}
+	if err = batch.Set([]byte("s/latest"), latest); err != nil {
+		return nil, err
+	}
+	if err = batch.WriteSync(); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(cInfo.StoreInfos))
+	for i, info := range cInfo.StoreInfos {
+		names[i] = info.Name
+	}
+	return names, nil
+}
+
+// AppHash returns the app hash of the restored state.
+func (s *SnapshotOracleServer) AppHash() []byte {
+	return s.cms.LastCommitID().Hash
+}
+
+func (s *SnapshotOracleServer) VerifyAppHash(appHash []byte) error {
+	if !bytes.Equal(s.AppHash(), appHash) {
+		return fmt.Errorf("app hash of snapshot at height %d is %X, expected %X", s.height, s.AppHash(), appHash)
+	}
+	return nil
+}
+
+func (s *SnapshotOracleServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("snapshot", req.Kind)
+	res, err := s.handle(req)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func (s *SnapshotOracleServer) handle(req oracletypes.Request) ([]byte, error) {
+	isQuery := req.Kind == oracletypes.KindABCIQuery || req.Kind == oracletypes.KindABCIQueryBatch
+	if isQuery && req.Height != 0 && req.Height != s.height+1 {
+		return nil, fmt.Errorf("snapshot at height %d can only serve height %d, got request for height %d", s.height, s.height+1, req.Height)
+	}
+
+	switch req.Kind {
+	case oracletypes.KindABCIQuery:
+		res, err := s.query(req.Queries[0])
+		if err != nil {
+			return nil, err
+		}
+		return toRawJson(res), nil
+	case oracletypes.KindABCIQueryBatch:
+		results := make([]interface{}, len(req.Queries))
+		for i, q := range req.Queries {
+			res, err := s.query(q)
+			if err != nil {
+				return nil, err
+			}
+			results[i] = res
+		}
+		return oracletypes.EncodeBatchResponse(results)
+	default:
+		if s.fallback == nil {
+			return nil, fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind)
+		}
+		if req.Height == 0 {
+			req.Height = s.height + 1
+		}
+		return s.fallback.Get(oracletypes.MustEncodeRequest(req)), nil
+	}
+}
+
+func (s *SnapshotOracleServer) query(q oracletypes.Query) (ctypes.ResultABCIQuery, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return queryMultiStore(s.cms, s.height, q)
+}
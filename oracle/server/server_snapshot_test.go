@@ -0,0 +1,89 @@
+package server
+
+import (
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/snapshots"
+	snapshottypes "github.com/cosmos/cosmos-sdk/snapshots/types"
+	"github.com/cosmos/cosmos-sdk/store/rootmulti"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+func TestSnapshotOracleServer(t *testing.T) {
+	app, err := testapp.NewTestApp()
+	require.NoError(t, err)
+	app.InitChain(abci.RequestInitChain{})
+	r := rand.New(rand.NewSource(0))
+	snapshotHeight := int64(8)
+	for i := range make([]int, snapshotHeight) {
+		_, err := testapp.ExecuteBlockWithTxs(app, 8, int64(i)+1, r)
+		require.NoError(t, err)
+		app.Commit()
+	}
+	appHash := app.LastCommitID().Hash
+
+	// take a snapshot like the snapshot manager does
+	store, err := snapshots.NewStore(dbm.NewMemDB(), t.TempDir())
+	require.NoError(t, err)
+	ch := make(chan io.ReadCloser)
+	go func() {
+		writer := snapshots.NewStreamWriter(ch)
+		if err := app.CommitMultiStore().(*rootmulti.Store).Snapshot(uint64(snapshotHeight), writer); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		writer.Close()
+	}()
+	_, err = store.Save(uint64(snapshotHeight), snapshottypes.CurrentFormat, ch)
+	require.NoError(t, err)
+
+	block, err := testapp.ExecuteBlockWithTxs(app, 8, snapshotHeight+1, r)
+	require.NoError(t, err)
+	app.Commit()
+	block.Header.AppHash = appHash
+	local := NewLocalOracleServer(app, block, nil, nil)
+
+	server, err := NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), nil, local)
+	require.NoError(t, err)
+	require.Equal(t, appHash, server.AppHash())
+
+	for _, path := range []string{"store/key1/key", "store/key2/key"} {
+		for _, data := range [][]byte{{0}, {1}, {0xff}} {
+			req := oracletypes.NewABCIQueryRequest(0, path, data)
+			expected, err := local.handle(req)
+			require.NoError(t, err)
+			res, err := server.handle(req)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), string(res))
+		}
+	}
+	res, err := server.handle(oracletypes.NewBlockRequest(0))
+	require.NoError(t, err)
+	expected, err := local.handle(oracletypes.NewBlockRequest(0))
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+
+	// queries are only served for the height after the snapshot, blocks of
+	// other heights are left to the fallback
+	_, err = server.handle(oracletypes.NewABCIQueryRequest(snapshotHeight, "store/key1/key", []byte{0}))
+	require.Error(t, err)
+	require.Panics(t, func() { _, _ = server.handle(oracletypes.NewBlockRequest(snapshotHeight)) })
+
+	// the app hash is checked with and without a fallback
+	_, err = NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), appHash, nil)
+	require.NoError(t, err)
+	_, err = NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), []byte("wrong"), nil)
+	require.Error(t, err)
+	_, err = NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), nil, nil)
+	require.Error(t, err)
+	block.Header.AppHash = []byte("wrong")
+	_, err = NewSnapshotOracleServer(store, uint64(snapshotHeight), snapshottypes.CurrentFormat, dbm.NewMemDB(), nil, local)
+	require.Error(t, err)
+}
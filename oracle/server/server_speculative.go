@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var _ OracleServer = &SpeculativeOracleServer{}
+
+// SpeculativeOracleServer serves the abci_query responses that CacheHttp
+// recorded in a directory, usually the one of a previous height. Its
+// responses are not verified and must only be used to discover which keys an
+// execution is going to request.
+type SpeculativeOracleServer struct {
+	queries map[string][]byte
+}
+
+func NewSpeculativeOracleServer(dir string) (*SpeculativeOracleServer, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := map[string][]byte{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "abci_query?") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		m, err := url.ParseQuery(strings.TrimSuffix(strings.TrimPrefix(name, "abci_query?"), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		queries[speculativeKey(m.Get("path"), m.Get("data"))] = data
+	}
+
+	return &SpeculativeOracleServer{
+		queries: queries,
+	}, nil
+}
+
+func (s *SpeculativeOracleServer) Get(key []byte) []byte {
+	req, err := oracletypes.DecodeRequest(key)
+	if err != nil {
+		panic(err)
+	}
+	countRequest("speculative", req.Kind)
+	if req.Kind != oracletypes.KindABCIQuery {
+		panic(fmt.Errorf("%w: %s", oracletypes.ErrUnsupportedKind, req.Kind))
+	}
+	q := req.Queries[0]
+	value, ok := s.queries[speculativeKey(q.Path, hex.EncodeToString(q.Data))]
+	if !ok {
+		panic(fmt.Sprintf("no speculative response for %s", key))
+	}
+	return value
+}
+
+func (s *SpeculativeOracleServer) Len() int {
+	return len(s.queries)
+}
+
+func speculativeKey(path, data string) string {
+	return path + "?" + strings.ToLower(data)
+}
@@ -0,0 +1,192 @@
+package types
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Version is the version of the request encoding written by EncodeRequest.
+// Keys without a version, such as the ones built by the IAVL oracle client,
+// are decoded as version 1.
+const Version = 1
+
+var (
+	ErrUnknownKind        = errors.New("unknown request kind")
+	ErrUnsupportedKind    = errors.New("request kind not supported by this server")
+	ErrUnsupportedVersion = errors.New("unsupported request version")
+	ErrMalformedRequest   = errors.New("malformed request")
+	ErrMalformedResponse  = errors.New("malformed response")
+	ErrVerificationFailed = errors.New("oracle response verification failed")
+)
+
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindBlock
+	KindConsensusParams
+	KindValidators
+	KindABCIQuery
+	KindABCIQueryBatch
+)
+
+var kindNames = map[Kind]string{
+	KindBlock:           "block",
+	KindConsensusParams: "consensus_params",
+	KindValidators:      "validators",
+	KindABCIQuery:       "abci_query",
+	KindABCIQueryBatch:  "abci_query_batch",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(k))
+}
+
+func ParseKind(s string) (Kind, error) {
+	for k, name := range kindNames {
+		if name == s {
+			return k, nil
+		}
+	}
+	return KindUnknown, fmt.Errorf("%w: %q", ErrUnknownKind, s)
+}
+
+type Query struct {
+	Path string
+	Data []byte
+}
+
+// Request is a request to an oracle server.
+type Request struct {
+	Kind Kind
+	// Height is the height of the block the request is about. Zero means the
+	// default height of the server.
+	Height int64
+	// Queries holds exactly one query for KindABCIQuery and any number of
+	// queries for KindABCIQueryBatch.
+	Queries []Query
+}
+
+func NewBlockRequest(height int64) Request {
+	return Request{Kind: KindBlock, Height: height}
+}
+
+func NewConsensusParamsRequest(height int64) Request {
+	return Request{Kind: KindConsensusParams, Height: height}
+}
+
+func NewValidatorsRequest(height int64) Request {
+	return Request{Kind: KindValidators, Height: height}
+}
+
+func NewABCIQueryRequest(height int64, path string, data []byte) Request {
+	return Request{Kind: KindABCIQuery, Height: height, Queries: []Query{{Path: path, Data: data}}}
+}
+
+func NewABCIQueryBatchRequest(height int64, queries []Query) Request {
+	return Request{Kind: KindABCIQueryBatch, Height: height, Queries: queries}
+}
+
+func (r Request) Validate() error {
+	if _, ok := kindNames[r.Kind]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownKind, r.Kind)
+	}
+	if r.Height < 0 {
+		return fmt.Errorf("%w: negative height %d", ErrMalformedRequest, r.Height)
+	}
+	switch r.Kind {
+	case KindABCIQuery:
+		if len(r.Queries) != 1 {
+			return fmt.Errorf("%w: %s requires exactly one query, got %d", ErrMalformedRequest, r.Kind, len(r.Queries))
+		}
+	case KindABCIQueryBatch:
+	default:
+		if len(r.Queries) != 0 {
+			return fmt.Errorf("%w: %s does not take queries", ErrMalformedRequest, r.Kind)
+		}
+	}
+	for _, q := range r.Queries {
+		if q.Path == "" {
+			return fmt.Errorf("%w: %s requires a path", ErrMalformedRequest, r.Kind)
+		}
+	}
+	return nil
+}
+
+// EncodeRequest encodes r as an oracle key of the form
+// <kind>?v=<version>[&height=<height>][&path=<path>&data=<hex>...].
+func EncodeRequest(r Request) ([]byte, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	v := url.Values{}
+	v.Set("v", strconv.Itoa(Version))
+	if r.Height != 0 {
+		v.Set("height", strconv.FormatInt(r.Height, 10))
+	}
+	for _, q := range r.Queries {
+		v.Add("path", q.Path)
+		v.Add("data", hex.EncodeToString(q.Data))
+	}
+	return []byte(r.Kind.String() + "?" + v.Encode()), nil
+}
+
+func MustEncodeRequest(r Request) []byte {
+	key, err := EncodeRequest(r)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// DecodeRequest decodes an oracle key built by EncodeRequest or by the IAVL
+// oracle client.
+func DecodeRequest(key []byte) (Request, error) {
+	u, err := url.Parse(string(key))
+	if err != nil {
+		return Request{}, fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+	kind, err := ParseKind(u.Path)
+	if err != nil {
+		return Request{}, err
+	}
+	m, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return Request{}, fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+	}
+
+	if version := m.Get("v"); version != "" && version != strconv.Itoa(Version) {
+		return Request{}, fmt.Errorf("%w: %s", ErrUnsupportedVersion, version)
+	}
+
+	r := Request{Kind: kind}
+	if height := m.Get("height"); height != "" {
+		r.Height, err = strconv.ParseInt(height, 10, 64)
+		if err != nil {
+			return Request{}, fmt.Errorf("%w: invalid height %q", ErrMalformedRequest, height)
+		}
+	}
+
+	paths, datas := m["path"], m["data"]
+	if len(paths) != len(datas) {
+		return Request{}, fmt.Errorf("%w: %s has %d paths but %d data", ErrMalformedRequest, kind, len(paths), len(datas))
+	}
+	for i := range paths {
+		data, err := hex.DecodeString(datas[i])
+		if err != nil {
+			return Request{}, fmt.Errorf("%w: invalid data %q", ErrMalformedRequest, datas[i])
+		}
+		r.Queries = append(r.Queries, Query{Path: paths[i], Data: data})
+	}
+
+	if err := r.Validate(); err != nil {
+		return Request{}, err
+	}
+	return r, nil
+}
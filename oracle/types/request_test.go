@@ -0,0 +1,80 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRequest(t *testing.T) {
+	reqs := []Request{
+		NewBlockRequest(0),
+		NewBlockRequest(42),
+		NewConsensusParamsRequest(7),
+		NewValidatorsRequest(0),
+		NewABCIQueryRequest(0, "store/bank/node", []byte{0xde, 0xad}),
+		NewABCIQueryRequest(10, "store/acc/key", []byte{}),
+		NewABCIQueryBatchRequest(10, []Query{
+			{Path: "store/bank/node", Data: []byte{1}},
+			{Path: "store/staking/key", Data: []byte("roothash")},
+		}),
+	}
+	for _, req := range reqs {
+		key, err := EncodeRequest(req)
+		require.NoError(t, err)
+		decoded, err := DecodeRequest(key)
+		require.NoError(t, err, string(key))
+		require.Equal(t, req.Kind, decoded.Kind)
+		require.Equal(t, req.Height, decoded.Height)
+		require.Equal(t, len(req.Queries), len(decoded.Queries))
+		for i := range req.Queries {
+			require.Equal(t, req.Queries[i].Path, decoded.Queries[i].Path)
+			require.Equal(t, fmt.Sprintf("%x", req.Queries[i].Data), fmt.Sprintf("%x", decoded.Queries[i].Data))
+		}
+	}
+}
+
+func TestDecodeLegacyRequest(t *testing.T) {
+	// format of the keys built by the IAVL oracle client
+	key := fmt.Sprintf("abci_query?path=%s&data=%s", url.PathEscape("store/bank/node"), "0a0b")
+	req, err := DecodeRequest([]byte(key))
+	require.NoError(t, err)
+	require.Equal(t, KindABCIQuery, req.Kind)
+	require.Equal(t, "store/bank/node", req.Queries[0].Path)
+	require.Equal(t, []byte{0x0a, 0x0b}, req.Queries[0].Data)
+
+	req, err = DecodeRequest([]byte("validators"))
+	require.NoError(t, err)
+	require.Equal(t, KindValidators, req.Kind)
+}
+
+func TestDecodeMalformedRequest(t *testing.T) {
+	cases := map[string]error{
+		"abci_query?path=store/bank/key":                  ErrMalformedRequest,
+		"abci_query?data=00":                              ErrMalformedRequest,
+		"abci_query":                                      ErrMalformedRequest,
+		"abci_query?path=store/bank/key&data=zz":          ErrMalformedRequest,
+		"abci_query?path=a&data=00&path=b&data=01":        ErrMalformedRequest,
+		"abci_query_batch?path=a&data=00&path=b":          ErrMalformedRequest,
+		"block?height=abc":                                ErrMalformedRequest,
+		"block?height=-1":                                 ErrMalformedRequest,
+		"block?path=store/bank/key&data=00":               ErrMalformedRequest,
+		"block?v=2":                                       ErrUnsupportedVersion,
+		"tx_search?query=abc":                             ErrUnknownKind,
+		"%zz":                                             ErrMalformedRequest,
+		"abci_query?path=store/bank/key&data=00&height=1": nil,
+	}
+	for key, expected := range cases {
+		_, err := DecodeRequest([]byte(key))
+		if expected == nil {
+			require.NoError(t, err, key)
+			continue
+		}
+		require.ErrorIs(t, err, expected, key)
+	}
+
+	_, err := EncodeRequest(Request{Kind: Kind(100)})
+	require.ErrorIs(t, err, ErrUnknownKind)
+}
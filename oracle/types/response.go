@@ -0,0 +1,50 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+
+	tmjson "github.com/tendermint/tendermint/libs/json"
+)
+
+// Responses are the Tendermint JSON encoding of the RPC result type of the
+// request kind, e.g. ctypes.ResultBlock for KindBlock. A response to
+// KindABCIQueryBatch is a JSON array of ctypes.ResultABCIQuery in the order
+// of the queries.
+
+func EncodeResponse(v interface{}) ([]byte, error) {
+	return tmjson.Marshal(v)
+}
+
+func DecodeResponse(b []byte, v interface{}) error {
+	if err := tmjson.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+	}
+	return nil
+}
+
+func EncodeBatchResponse(vs []interface{}) ([]byte, error) {
+	raws := make([]json.RawMessage, len(vs))
+	for i := range vs {
+		js, err := EncodeResponse(vs[i])
+		if err != nil {
+			return nil, err
+		}
+		raws[i] = js
+	}
+	return json.Marshal(raws)
+}
+
+// DecodeBatchResponse splits a batch response into the responses to the
+// individual queries.
+func DecodeBatchResponse(b []byte) ([][]byte, error) {
+	raws := []json.RawMessage{}
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedResponse, err)
+	}
+	res := make([][]byte, len(raws))
+	for i := range raws {
+		res[i] = raws[i]
+	}
+	return res, nil
+}
@@ -0,0 +1,270 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+
+	ics23 "github.com/confio/ics23/go"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/merkle"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+)
+
+var proofRuntime = func() *merkle.ProofRuntime {
+	prt := merkle.NewProofRuntime()
+	prt.RegisterOpDecoder(storetypes.ProofOpIAVLCommitment, storetypes.CommitmentOpDecoder)
+	prt.RegisterOpDecoder(storetypes.ProofOpSimpleMerkleCommitment, storetypes.CommitmentOpDecoder)
+	return prt
+}()
+
+// VerifyABCIQuery checks that res answers the store query q at height and
+// that its proof leads from the value, or its absence, to appHash, the app
+// hash in the header of the block at height+1.
+//
+// Error responses carry no proof. They are passed on for queries other than
+// key and node queries, as they provide no data to the stateless tree, but
+// fail the verification of key and node queries, whose answer would be
+// withheld. Only the root of an empty tree has no node to answer with.
+func VerifyABCIQuery(appHash []byte, height int64, q Query, res abci.ResponseQuery) error {
+	parts := strings.Split(strings.TrimPrefix(q.Path, "store/"), "/")
+	isStoreQuery := strings.HasPrefix(q.Path, "store/") && len(parts) == 2
+	if res.ProofOps == nil || len(res.ProofOps.Ops) == 0 {
+		if res.IsOK() {
+			return fmt.Errorf("%w: %s response has no proof", ErrVerificationFailed, q.Path)
+		}
+		withheld := isStoreQuery && (parts[1] == "key" || parts[1] == "node" && !bytes.Equal(q.Data, EmptyTreeHash))
+		if withheld {
+			return fmt.Errorf("%w: %s error response: %s", ErrVerificationFailed, q.Path, res.Log)
+		}
+		return nil
+	}
+	if !res.IsOK() {
+		return fmt.Errorf("%w: %s error response has a proof", ErrVerificationFailed, q.Path)
+	}
+	if res.Height != height {
+		return fmt.Errorf("%w: %s response is for height %d, not %d", ErrVerificationFailed, q.Path, res.Height, height)
+	}
+	if !isStoreQuery {
+		return fmt.Errorf("%w: cannot verify %s response", ErrVerificationFailed, q.Path)
+	}
+	storeName, subpath := parts[0], parts[1]
+	// node queries are answered with the proof of a key of the node
+	if subpath == "key" && !bytes.Equal(res.Key, q.Data) {
+		return fmt.Errorf("%w: %s response proves key %X instead of %X", ErrVerificationFailed, q.Path, res.Key, q.Data)
+	}
+	if subpath == "node" && !provesNode(res.ProofOps.Ops[0], q.Data) {
+		return fmt.Errorf("%w: %s response does not prove node %X", ErrVerificationFailed, q.Path, q.Data)
+	}
+	keyPath := merkle.KeyPath{}.
+		AppendKey([]byte(storeName), merkle.KeyEncodingURL).
+		AppendKey(res.Key, merkle.KeyEncodingURL)
+
+	if isEmptyTreeProof(res.ProofOps.Ops[0]) {
+		// nothing can be proven absent in an empty tree, prove that the
+		// store is empty instead
+		if res.Value != nil {
+			return fmt.Errorf("%w: %s response has a value but proves an empty store", ErrVerificationFailed, q.Path)
+		}
+		storePath := merkle.KeyPath{}.AppendKey([]byte(storeName), merkle.KeyEncodingURL)
+		ops := &tmcrypto.ProofOps{Ops: res.ProofOps.Ops[1:]}
+		if err := proofRuntime.VerifyValue(ops, appHash, storePath.String(), EmptyTreeHash); err != nil {
+			return fmt.Errorf("%w: %s response: %v", ErrVerificationFailed, q.Path, err)
+		}
+		return nil
+	}
+
+	var err error
+	if res.Value != nil {
+		err = proofRuntime.VerifyValue(res.ProofOps, appHash, keyPath.String(), res.Value)
+	} else {
+		err = proofRuntime.VerifyAbsence(res.ProofOps, appHash, keyPath.String())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s response: %v", ErrVerificationFailed, q.Path, err)
+	}
+	return nil
+}
+
+// EmptyTreeHash is the root hash of an IAVL tree without nodes.
+var EmptyTreeHash = sha256.New().Sum(nil)
+
+func isEmptyTreeProof(op tmcrypto.ProofOp) bool {
+	if op.Type != storetypes.ProofOpIAVLCommitment {
+		return false
+	}
+	decoded, err := storetypes.CommitmentOpDecoder(op)
+	if err != nil {
+		return false
+	}
+	nonexist := decoded.(storetypes.CommitmentOp).Proof.GetNonexist()
+	return nonexist != nil && nonexist.Left == nil && nonexist.Right == nil
+}
+
+// provesNode reports whether node is the hash of the leaf or of an inner node
+// on the path of the IAVL existence proof of op.
+func provesNode(op tmcrypto.ProofOp, node []byte) bool {
+	_, ok := nodeChildren(commitmentProof(op, storetypes.ProofOpIAVLCommitment).GetExist(), node)
+	return ok
+}
+
+// commitmentProof decodes the ics23 proof of op if op is of type typ.
+func commitmentProof(op tmcrypto.ProofOp, typ string) *ics23.CommitmentProof {
+	if op.Type != typ {
+		return nil
+	}
+	decoded, err := storetypes.CommitmentOpDecoder(op)
+	if err != nil {
+		return nil
+	}
+	return decoded.(storetypes.CommitmentOp).Proof
+}
+
+// nodeChildren walks the IAVL existence proof p from the leaf up to node and
+// returns the hashes of the children of node, none for the leaf. It returns
+// false if node is not on the path of p.
+func nodeChildren(p *ics23.ExistenceProof, node []byte) ([][]byte, bool) {
+	if p == nil || p.Leaf == nil {
+		return nil, false
+	}
+	hash, err := p.Leaf.Apply(p.Key, p.Value)
+	if err != nil {
+		return nil, false
+	}
+	if bytes.Equal(hash, node) {
+		return nil, true
+	}
+	for _, inner := range p.Path {
+		child := hash
+		if hash, err = inner.Apply(child); err != nil {
+			return nil, false
+		}
+		if bytes.Equal(hash, node) {
+			sibling, ok := innerSibling(inner)
+			if !ok {
+				return nil, false
+			}
+			return [][]byte{child, sibling}, true
+		}
+	}
+	return nil, false
+}
+
+// innerSibling returns the hash of the child of an IAVL inner op that is not
+// on the path. The op holds it with its length, a single byte for SHA256
+// hashes:
+//
+//	child on the left:  suffix = 32 | right
+//	child on the right: prefix = header | 32 | left | 32
+func innerSibling(op *ics23.InnerOp) ([]byte, bool) {
+	const hashLen = 32
+	prefix, suffix := op.Prefix, op.Suffix
+	switch {
+	case len(suffix) == hashLen+1 && suffix[0] == hashLen:
+		return suffix[1:], true
+	case len(suffix) == 0 && len(prefix) >= hashLen+2 && prefix[len(prefix)-1] == hashLen && prefix[len(prefix)-hashLen-2] == hashLen:
+		return prefix[len(prefix)-hashLen-1 : len(prefix)-1], true
+	default:
+		return nil, false
+	}
+}
+
+// RootHashKey is the key whose absence proof the IAVL oracle client queries
+// for the root of a store, which it takes from the proof of the store.
+var RootHashKey = []byte("roothash")
+
+// ProvenNodes verifies the ABCI queries of one height like VerifyABCIQuery
+// and keeps the roots of the stores and the children of the requested nodes
+// the responses prove. Against them, it also accepts the partial proofs of a
+// pruned bundle, which only hold what the stateless tree reads:
+//   - the proof of a root hash query may be the proof of the store root
+//     alone,
+//   - the proof of a node query may end at the requested node if the node is
+//     the root of its store or a child of a node requested before, as are
+//     all nodes the stateless tree requests while it walks down from the
+//     root.
+type ProvenNodes struct {
+	mtx sync.Mutex
+	// nodes holds the proven node hashes by store name.
+	nodes map[string]map[string]bool
+}
+
+func NewProvenNodes() *ProvenNodes {
+	return &ProvenNodes{nodes: map[string]map[string]bool{}}
+}
+
+// Verify checks that res answers the store query q at height with a proof
+// to appHash, or with a partial proof to the nodes proven before.
+func (p *ProvenNodes) Verify(appHash []byte, height int64, q Query, res abci.ResponseQuery) error {
+	parts := strings.Split(strings.TrimPrefix(q.Path, "store/"), "/")
+	if !strings.HasPrefix(q.Path, "store/") || len(parts) != 2 || !res.IsOK() || res.ProofOps == nil {
+		return VerifyABCIQuery(appHash, height, q, res)
+	}
+	storeName, subpath := parts[0], parts[1]
+	ops := res.ProofOps.Ops
+	partialRoot := subpath == "key" && bytes.Equal(q.Data, RootHashKey) &&
+		len(ops) == 1 && ops[0].Type == storetypes.ProofOpSimpleMerkleCommitment
+	partialNode := subpath == "node" && len(ops) == 1 && ops[0].Type == storetypes.ProofOpIAVLCommitment
+	if (partialRoot || partialNode) && res.Height != height {
+		return fmt.Errorf("%w: %s response is for height %d, not %d", ErrVerificationFailed, q.Path, res.Height, height)
+	}
+
+	switch {
+	case partialRoot:
+		root := commitmentProof(ops[0], storetypes.ProofOpSimpleMerkleCommitment).GetExist().GetValue()
+		if res.Value != nil || root == nil {
+			return fmt.Errorf("%w: %s response proves no store root", ErrVerificationFailed, q.Path)
+		}
+		storePath := merkle.KeyPath{}.AppendKey([]byte(storeName), merkle.KeyEncodingURL)
+		if err := proofRuntime.VerifyValue(res.ProofOps, appHash, storePath.String(), root); err != nil {
+			return fmt.Errorf("%w: %s response: %v", ErrVerificationFailed, q.Path, err)
+		}
+		p.add(storeName, root)
+		return nil
+	case partialNode:
+		if !p.has(storeName, q.Data) {
+			return fmt.Errorf("%w: %s response ends at node %X, which is not proven", ErrVerificationFailed, q.Path, q.Data)
+		}
+		proof := commitmentProof(ops[0], storetypes.ProofOpIAVLCommitment)
+		if proof == nil || !ics23.VerifyMembership(ics23.IavlSpec, q.Data, proof, res.Key, res.Value) {
+			return fmt.Errorf("%w: %s response does not prove node %X", ErrVerificationFailed, q.Path, q.Data)
+		}
+		children, _ := nodeChildren(proof.GetExist(), q.Data)
+		p.add(storeName, children...)
+		return nil
+	}
+
+	if err := VerifyABCIQuery(appHash, height, q, res); err != nil {
+		return err
+	}
+	if root := commitmentProof(ops[len(ops)-1], storetypes.ProofOpSimpleMerkleCommitment).GetExist().GetValue(); root != nil {
+		p.add(storeName, root)
+	}
+	if subpath == "node" {
+		children, _ := nodeChildren(commitmentProof(ops[0], storetypes.ProofOpIAVLCommitment).GetExist(), q.Data)
+		p.add(storeName, children...)
+	}
+	return nil
+}
+
+func (p *ProvenNodes) add(storeName string, hashes ...[]byte) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	nodes, ok := p.nodes[storeName]
+	if !ok {
+		nodes = map[string]bool{}
+		p.nodes[storeName] = nodes
+	}
+	for _, hash := range hashes {
+		nodes[string(hash)] = true
+	}
+}
+
+func (p *ProvenNodes) has(storeName string, hash []byte) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.nodes[storeName][string(hash)]
+}
@@ -0,0 +1,392 @@
+package testapp
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/simapp"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/types"
+)
+
+var genesisTime = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// BlockOptions configures a block generated by Chain.
+type BlockOptions struct {
+	// Include are txs of the block before the Txs generated ones.
+	Include types.Txs
+	Txs     int
+	// Evidence adds duplicate-vote evidence of a random validator at the
+	// previous height.
+	Evidence bool
+	// Absent is the number of validators, starting from the last one, that
+	// do not sign the commit of the block.
+	Absent int
+}
+
+// ChainConfig configures a chain of any application.
+type ChainConfig struct {
+	App     *baseapp.BaseApp
+	ChainID string
+	// PrivValidators sign for the validators of every height. They may
+	// include validators that only join the set later.
+	PrivValidators []types.PrivValidator
+	// Validators are the genesis validators, unless InitChain returns
+	// validators.
+	Validators *types.ValidatorSet
+	AppState   []byte
+	// ConsensusParams are passed to InitChain if set, which requires the app
+	// to have a param store.
+	ConsensusParams *tmproto.ConsensusParams
+	// Txs returns the txs of the block of height for BlockOptions.Txs n.
+	Txs func(height int64, n int) (types.Txs, error)
+}
+
+// Chain deterministically generates a chain of blocks with ed25519
+// validators, signed commits and complete headers, executing them on App.
+// Validator updates returned by EndBlock take effect two heights later as
+// in Tendermint.
+type Chain struct {
+	App     *baseapp.BaseApp
+	ChainID string
+
+	r        *rand.Rand
+	txs      func(height int64, n int) (types.Txs, error)
+	privVals map[string]types.PrivValidator
+	// vals are the validators of every height from 1 up to the one after
+	// the next block
+	vals []*types.ValidatorSet
+	cp   tmproto.ConsensusParams
+
+	blocks      []*types.Block
+	commits     []*types.Commit
+	appHash     []byte
+	resultsHash []byte
+}
+
+// NewChain returns a chain of a testapp with numValidators validators of
+// equal power whose first block is at height 1. The same seed always
+// generates the same blocks.
+func NewChain(seed int64, numValidators int) (*Chain, error) {
+	if numValidators < 1 {
+		return nil, fmt.Errorf("chain needs at least one validator")
+	}
+	app, err := NewTestApp()
+	if err != nil {
+		return nil, err
+	}
+
+	privVals := make([]types.PrivValidator, numValidators)
+	validators := make([]*types.Validator, numValidators)
+	for i := range privVals {
+		privKey := ed25519.GenPrivKeyFromSecret([]byte(fmt.Sprintf("testapp/%d/%d", seed, i)))
+		privVals[i] = types.NewMockPVWithParams(privKey, false, false)
+		validators[i] = types.NewValidator(privKey.PubKey(), 10)
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	txConfig := simapp.MakeTestEncodingConfig().TxConfig
+	return NewChainWithConfig(r, ChainConfig{
+		App:            app,
+		ChainID:        fmt.Sprintf("testapp-%d", seed),
+		PrivValidators: privVals,
+		Validators:     types.NewValidatorSet(validators),
+		Txs: func(height int64, n int) (types.Txs, error) {
+			txs := types.Txs{}
+			for i := 0; i < n; i++ {
+				tx, err := RandomTx(txConfig, r)
+				if err != nil {
+					return nil, err
+				}
+				txs = append(txs, tx)
+			}
+			return txs, nil
+		},
+	})
+}
+
+// NewChainWithConfig initializes the chain of cfg. r generates evidence.
+func NewChainWithConfig(r *rand.Rand, cfg ChainConfig) (*Chain, error) {
+	c := &Chain{
+		App:      cfg.App,
+		ChainID:  cfg.ChainID,
+		r:        r,
+		txs:      cfg.Txs,
+		privVals: map[string]types.PrivValidator{},
+		cp:       *types.DefaultConsensusParams(),
+	}
+	for _, pv := range cfg.PrivValidators {
+		pubKey, err := pv.GetPubKey()
+		if err != nil {
+			return nil, err
+		}
+		c.privVals[string(pubKey.Address())] = pv
+	}
+
+	req := abci.RequestInitChain{
+		Time:          genesisTime,
+		ChainId:       c.ChainID,
+		AppStateBytes: cfg.AppState,
+		InitialHeight: 1,
+	}
+	if cfg.Validators != nil {
+		req.Validators = types.TM2PB.ValidatorUpdates(cfg.Validators)
+	}
+	if cfg.ConsensusParams != nil {
+		c.cp = *cfg.ConsensusParams
+		req.ConsensusParams = types.TM2PB.ConsensusParams(&c.cp)
+	}
+	res := c.App.InitChain(req)
+
+	vals := cfg.Validators
+	if len(res.Validators) > 0 {
+		validators, err := types.PB2TM.ValidatorUpdates(res.Validators)
+		if err != nil {
+			return nil, err
+		}
+		vals = types.NewValidatorSet(validators)
+	}
+	if vals == nil || vals.IsNilOrEmpty() {
+		return nil, fmt.Errorf("chain needs at least one validator")
+	}
+	for _, val := range vals.Validators {
+		if _, ok := c.privVals[string(val.Address)]; !ok {
+			return nil, fmt.Errorf("no private validator for validator %s", val.Address)
+		}
+	}
+	c.vals = []*types.ValidatorSet{vals, vals.Copy()}
+	c.appHash = res.AppHash
+	return c, nil
+}
+
+// Height returns the height of the last generated block.
+func (c *Chain) Height() int64 {
+	return int64(len(c.blocks))
+}
+
+func (c *Chain) Block(height int64) *types.Block {
+	return c.blocks[height-1]
+}
+
+// Commit returns the commit of the block of height.
+func (c *Chain) Commit(height int64) *types.Commit {
+	return c.commits[height-1]
+}
+
+func (c *Chain) BlockID(height int64) types.BlockID {
+	return c.Commit(height).BlockID
+}
+
+// Validators returns the validators of height, which are known up to the
+// height after the next block. Heights below 1 have the genesis validators.
+func (c *Chain) Validators(height int64) *types.ValidatorSet {
+	if height < 1 {
+		height = 1
+	}
+	return c.vals[height-1]
+}
+
+func (c *Chain) ConsensusParams() tmproto.ConsensusParams {
+	return c.cp
+}
+
+// AppHash returns the app hash after the last generated block.
+func (c *Chain) AppHash() []byte {
+	return c.appHash
+}
+
+// NextBlock generates, executes, commits and signs the block of the next
+// height.
+func (c *Chain) NextBlock(opts BlockOptions) (*types.Block, error) {
+	height := c.Height() + 1
+
+	txs, err := c.txs(height, opts.Txs)
+	if err != nil {
+		return nil, err
+	}
+	txs = append(append(types.Txs{}, opts.Include...), txs...)
+
+	lastCommit := &types.Commit{}
+	lastBlockID := types.BlockID{}
+	if height > 1 {
+		lastCommit = c.Commit(height - 1)
+		lastBlockID = lastCommit.BlockID
+	}
+	evidence := []types.Evidence{}
+	if opts.Evidence && height > 1 {
+		ev, err := c.duplicateVoteEvidence(height - 1)
+		if err != nil {
+			return nil, err
+		}
+		evidence = append(evidence, ev)
+	}
+
+	block := types.MakeBlock(height, txs, lastCommit, evidence)
+	block.ChainID = c.ChainID
+	block.Time = blockTime(height)
+	block.LastBlockID = lastBlockID
+	vals := c.Validators(height)
+	block.ValidatorsHash = vals.Hash()
+	block.NextValidatorsHash = c.Validators(height + 1).Hash()
+	block.ConsensusHash = types.HashConsensusParams(c.cp)
+	block.AppHash = c.appHash
+	block.LastResultsHash = c.resultsHash
+	block.ProposerAddress = vals.Validators[int(height)%vals.Size()].Address
+	if err := block.ValidateBasic(); err != nil {
+		return nil, err
+	}
+
+	// execute
+	byzVals := []abci.Evidence{}
+	for _, ev := range evidence {
+		byzVals = append(byzVals, ev.ABCI()...)
+	}
+	c.App.BeginBlock(abci.RequestBeginBlock{
+		Hash:                block.Hash(),
+		Header:              *block.Header.ToProto(),
+		LastCommitInfo:      c.lastCommitInfo(block),
+		ByzantineValidators: byzVals,
+	})
+	responses := make([]*abci.ResponseDeliverTx, len(txs))
+	for i, tx := range txs {
+		res := c.App.DeliverTx(abci.RequestDeliverTx{Tx: tx})
+		if !res.IsOK() {
+			return nil, fmt.Errorf(res.String())
+		}
+		responses[i] = &res
+	}
+	endBlock := c.App.EndBlock(abci.RequestEndBlock{Height: height})
+	if err = c.updateValidators(endBlock.ValidatorUpdates); err != nil {
+		return nil, err
+	}
+	c.appHash = c.App.Commit().Data
+	c.resultsHash = types.NewResults(responses).Hash()
+
+	// sign
+	blockID := types.BlockID{
+		Hash:          block.Hash(),
+		PartSetHeader: block.MakePartSet(types.BlockPartSizeBytes).Header(),
+	}
+	commit, err := c.sign(blockID, height, opts.Absent)
+	if err != nil {
+		return nil, err
+	}
+
+	c.blocks = append(c.blocks, block)
+	c.commits = append(c.commits, commit)
+	return block, nil
+}
+
+// updateValidators applies the validator updates of the next block to the
+// validators of the height after it.
+func (c *Chain) updateValidators(updates []abci.ValidatorUpdate) error {
+	vals := c.vals[len(c.vals)-1].Copy()
+	if len(updates) > 0 {
+		changes, err := types.PB2TM.ValidatorUpdates(updates)
+		if err != nil {
+			return err
+		}
+		if err = vals.UpdateWithChangeSet(changes); err != nil {
+			return err
+		}
+		for _, val := range changes {
+			if _, ok := c.privVals[string(val.Address)]; val.VotingPower > 0 && !ok {
+				return fmt.Errorf("no private validator for validator %s", val.Address)
+			}
+		}
+	}
+	c.vals = append(c.vals, vals)
+	return nil
+}
+
+func (c *Chain) lastCommitInfo(block *types.Block) abci.LastCommitInfo {
+	vals := c.Validators(block.Height - 1)
+	votes := make([]abci.VoteInfo, block.LastCommit.Size())
+	for i, sig := range block.LastCommit.Signatures {
+		votes[i] = abci.VoteInfo{
+			Validator:       types.TM2PB.Validator(vals.Validators[i]),
+			SignedLastBlock: !sig.Absent(),
+		}
+	}
+	return abci.LastCommitInfo{
+		Round: block.LastCommit.Round,
+		Votes: votes,
+	}
+}
+
+// sign returns the commit of blockID signed by all but the last absent
+// validators.
+func (c *Chain) sign(blockID types.BlockID, height int64, absent int) (*types.Commit, error) {
+	vals := c.Validators(height)
+	if absent > vals.Size() {
+		absent = vals.Size()
+	}
+	voteSet := types.NewVoteSet(c.ChainID, height, 0, tmproto.PrecommitType, vals)
+	for _, val := range vals.Validators[:vals.Size()-absent] {
+		vote, err := c.vote(height, blockID, c.privVals[string(val.Address)], blockTime(height+1))
+		if err != nil {
+			return nil, err
+		}
+		if _, err = voteSet.AddVote(vote); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := voteSet.TwoThirdsMajority(); !ok {
+		return nil, fmt.Errorf("%d absent validators leave no +2/3 majority at height %d", absent, height)
+	}
+	return voteSet.MakeCommit(), nil
+}
+
+// duplicateVoteEvidence returns evidence of a random validator signing two
+// blocks at height.
+func (c *Chain) duplicateVoteEvidence(height int64) (types.Evidence, error) {
+	vals := c.Validators(height)
+	pv := c.privVals[string(vals.Validators[c.r.Intn(vals.Size())].Address)]
+	votes := make([]*types.Vote, 2)
+	for i := range votes {
+		hash := make([]byte, 32)
+		if _, err := c.r.Read(hash); err != nil {
+			return nil, err
+		}
+		blockID := types.BlockID{
+			Hash:          hash,
+			PartSetHeader: types.PartSetHeader{Total: 1, Hash: hash},
+		}
+		vote, err := c.vote(height, blockID, pv, blockTime(height))
+		if err != nil {
+			return nil, err
+		}
+		votes[i] = vote
+	}
+	return types.NewDuplicateVoteEvidence(votes[0], votes[1], blockTime(height), vals), nil
+}
+
+func (c *Chain) vote(height int64, blockID types.BlockID, pv types.PrivValidator, timestamp time.Time) (*types.Vote, error) {
+	pubKey, err := pv.GetPubKey()
+	if err != nil {
+		return nil, err
+	}
+	idx, _ := c.Validators(height).GetByAddress(pubKey.Address())
+	vote := &types.Vote{
+		Type:             tmproto.PrecommitType,
+		Height:           height,
+		BlockID:          blockID,
+		Timestamp:        timestamp,
+		ValidatorAddress: pubKey.Address(),
+		ValidatorIndex:   idx,
+	}
+	v := vote.ToProto()
+	if err = pv.SignVote(c.ChainID, v); err != nil {
+		return nil, err
+	}
+	vote.Signature = v.Signature
+	return vote, nil
+}
+
+func blockTime(height int64) time.Time {
+	return genesisTime.Add(time.Duration(height) * time.Second)
+}
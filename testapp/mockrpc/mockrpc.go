@@ -0,0 +1,308 @@
+// Package mockrpc serves a testapp Chain over a fake Tendermint JSON-RPC
+// endpoint, with optional faults, so that RPC based oracle servers can be
+// tested without a node.
+package mockrpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	ics23 "github.com/confio/ics23/go"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmbytes "github.com/tendermint/tendermint/libs/bytes"
+	tmjson "github.com/tendermint/tendermint/libs/json"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpctypes "github.com/tendermint/tendermint/rpc/jsonrpc/types"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+// Faults are misbehaviours of the server.
+type Faults struct {
+	// WrongBlockHash serves blocks with a modified app hash and a block ID
+	// matching the modified block.
+	WrongBlockHash bool
+	// TruncateValidators drops the last validator of every page while still
+	// reporting the full count.
+	TruncateValidators bool
+	// PrunedBelow makes all heights below it unavailable.
+	PrunedBelow int64
+	// Delay is added to every response.
+	Delay time.Duration
+	// ForgeProofs flips a byte of the value of every existence proof.
+	ForgeProofs bool
+}
+
+// Server is a fake Tendermint RPC endpoint serving block, commit, validators
+// and abci_query from a Chain.
+type Server struct {
+	*httptest.Server
+	chain *testapp.Chain
+
+	mtx    sync.Mutex
+	faults Faults
+	calls  map[string]int
+}
+
+// NewServer starts a server for chain. It must be closed after use.
+func NewServer(chain *testapp.Chain) *Server {
+	s := &Server{
+		chain: chain,
+		calls: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) SetFaults(faults Faults) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.faults = faults
+}
+
+// Calls returns the number of calls of method.
+func (s *Server) Calls(method string) int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.calls[method]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, rpctypes.RPCParseError(err))
+		return
+	}
+	s.mtx.Lock()
+	faults := s.faults
+	s.mtx.Unlock()
+	if faults.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(faults.Delay):
+		}
+	}
+
+	// batches are arrays of requests
+	reqs := []rpctypes.RPCRequest{}
+	if err = json.Unmarshal(body, &reqs); err != nil {
+		req := rpctypes.RPCRequest{}
+		if err = json.Unmarshal(body, &req); err != nil {
+			writeJSON(w, rpctypes.RPCParseError(err))
+			return
+		}
+		writeJSON(w, s.handle(req, faults))
+		return
+	}
+	responses := make([]rpctypes.RPCResponse, len(reqs))
+	for i, req := range reqs {
+		responses[i] = s.handle(req, faults)
+	}
+	writeJSON(w, responses)
+}
+
+func (s *Server) handle(req rpctypes.RPCRequest, faults Faults) rpctypes.RPCResponse {
+	s.mtx.Lock()
+	s.calls[req.Method]++
+	s.mtx.Unlock()
+
+	var result interface{}
+	var err error
+	switch req.Method {
+	case "block":
+		result, err = s.block(req.Params, faults)
+	case "commit":
+		result, err = s.commit(req.Params, faults)
+	case "validators":
+		result, err = s.validators(req.Params, faults)
+	case "consensus_params":
+		result, err = s.consensusParams(req.Params, faults)
+	case "abci_query":
+		result, err = s.abciQuery(req.Params, faults)
+	default:
+		return rpctypes.RPCMethodNotFoundError(req.ID)
+	}
+	if err != nil {
+		return rpctypes.RPCInternalError(req.ID, err)
+	}
+	return rpctypes.NewRPCSuccessResponse(req.ID, result)
+}
+
+type heightParams struct {
+	Height *int64 `json:"height"`
+}
+
+type validatorsParams struct {
+	Height  *int64 `json:"height"`
+	Page    *int   `json:"page"`
+	PerPage *int   `json:"per_page"`
+}
+
+type abciQueryParams struct {
+	Path   string           `json:"path"`
+	Data   tmbytes.HexBytes `json:"data"`
+	Height int64            `json:"height"`
+	Prove  bool             `json:"prove"`
+}
+
+// height returns the requested height, the latest one if it is nil.
+func (s *Server) height(height *int64, faults Faults) (int64, error) {
+	latest := s.chain.Height()
+	if height == nil {
+		return latest, nil
+	}
+	h := *height
+	if h < faults.PrunedBelow {
+		return 0, fmt.Errorf("height %d is not available, lowest height is %d", h, faults.PrunedBelow)
+	}
+	if h < 1 || h > latest {
+		return 0, fmt.Errorf("height %d must be less than or equal to the current blockchain height %d", h, latest)
+	}
+	return h, nil
+}
+
+func (s *Server) block(params json.RawMessage, faults Faults) (*ctypes.ResultBlock, error) {
+	p := heightParams{}
+	if err := tmjson.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	h, err := s.height(p.Height, faults)
+	if err != nil {
+		return nil, err
+	}
+	block := s.chain.Block(h)
+	blockID := s.chain.BlockID(h)
+	if faults.WrongBlockHash {
+		pb, err := block.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		if block, err = types.BlockFromProto(pb); err != nil {
+			return nil, err
+		}
+		block.AppHash = append([]byte{^block.AppHash[0]}, block.AppHash[1:]...)
+		blockID = types.BlockID{
+			Hash:          block.Hash(),
+			PartSetHeader: block.MakePartSet(types.BlockPartSizeBytes).Header(),
+		}
+	}
+	return &ctypes.ResultBlock{BlockID: blockID, Block: block}, nil
+}
+
+func (s *Server) commit(params json.RawMessage, faults Faults) (*ctypes.ResultCommit, error) {
+	p := heightParams{}
+	if err := tmjson.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	h, err := s.height(p.Height, faults)
+	if err != nil {
+		return nil, err
+	}
+	return ctypes.NewResultCommit(&s.chain.Block(h).Header, s.chain.Commit(h), true), nil
+}
+
+func (s *Server) validators(params json.RawMessage, faults Faults) (*ctypes.ResultValidators, error) {
+	p := validatorsParams{}
+	if err := tmjson.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	h, err := s.height(p.Height, faults)
+	if err != nil {
+		return nil, err
+	}
+	vals := s.chain.Validators(h).Validators
+	page, perPage := 1, 30
+	if p.Page != nil {
+		page = *p.Page
+	}
+	if p.PerPage != nil && *p.PerPage > 0 && *p.PerPage <= 100 {
+		perPage = *p.PerPage
+	}
+	start := (page - 1) * perPage
+	if page < 1 || start >= len(vals) {
+		return nil, fmt.Errorf("page should be within [1, %d] range, given %d", (len(vals)+perPage-1)/perPage, page)
+	}
+	end := start + perPage
+	if end > len(vals) {
+		end = len(vals)
+	}
+	count := end - start
+	if faults.TruncateValidators {
+		end--
+	}
+	return &ctypes.ResultValidators{
+		BlockHeight: h,
+		Validators:  vals[start:end],
+		Count:       count,
+		Total:       len(vals),
+	}, nil
+}
+
+func (s *Server) consensusParams(params json.RawMessage, faults Faults) (*ctypes.ResultConsensusParams, error) {
+	p := heightParams{}
+	if err := tmjson.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	h, err := s.height(p.Height, faults)
+	if err != nil {
+		return nil, err
+	}
+	return &ctypes.ResultConsensusParams{BlockHeight: h, ConsensusParams: s.chain.ConsensusParams()}, nil
+}
+
+func (s *Server) abciQuery(params json.RawMessage, faults Faults) (*ctypes.ResultABCIQuery, error) {
+	p := abciQueryParams{}
+	if err := tmjson.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Height != 0 && p.Height < faults.PrunedBelow {
+		return &ctypes.ResultABCIQuery{Response: abci.ResponseQuery{
+			Codespace: sdkerrors.RootCodespace,
+			Code:      sdkerrors.ErrInvalidRequest.ABCICode(),
+			Log:       fmt.Sprintf("failed to load state at height %d; version does not exist (latest height: %d)", p.Height, s.chain.Height()),
+			Height:    p.Height,
+		}}, nil
+	}
+	res := s.chain.App.Query(abci.RequestQuery{
+		Path:   p.Path,
+		Data:   p.Data,
+		Height: p.Height,
+		Prove:  p.Prove,
+	})
+	if faults.ForgeProofs && res.ProofOps != nil {
+		for i, op := range res.ProofOps.Ops {
+			proof := &ics23.CommitmentProof{}
+			if err := proof.Unmarshal(op.Data); err != nil {
+				return nil, err
+			}
+			if exist := proof.GetExist(); exist != nil && len(exist.Value) > 0 {
+				exist.Value[len(exist.Value)-1] ^= 0xff
+			}
+			bz, err := proof.Marshal()
+			if err != nil {
+				return nil, err
+			}
+			res.ProofOps.Ops[i].Data = bz
+		}
+	}
+	return &ctypes.ResultABCIQuery{Response: res}, nil
+}
+
+// writeJSON writes responses, whose results are already amino JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	bz, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bz)
+}
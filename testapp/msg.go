@@ -2,28 +2,125 @@ package testapp
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
 
+	"github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
-type MsgServerImpl struct{}
+// IterateResultKey is the key of key1 holding the hash of the last
+// iteration, so that iterating over wrong keys changes the app hash.
+var IterateResultKey = []byte("iterate")
+
+type MsgServerImpl struct {
+	// keys are the stores besides key1 and key2 by name
+	keys map[string]sdk.StoreKey
+}
 
 var _ MsgServer = MsgServerImpl{}
 
 func (m MsgServerImpl) Get(c context.Context, msg *MsgGet) (*MsgGetResponse, error) {
 	ctx := sdk.UnwrapSDKContext(c)
-	ctx.KVStore(capKey2).Get(msg.Key)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	store.Get(msg.Key)
 	return &MsgGetResponse{}, nil
 }
 
 func (m MsgServerImpl) Set(c context.Context, msg *MsgSet) (*MsgSetResponse, error) {
 	ctx := sdk.UnwrapSDKContext(c)
-	ctx.KVStore(capKey2).Set(msg.Key, msg.Value)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	store.Set(msg.Key, msg.Value)
 	return &MsgSetResponse{}, nil
 }
 
 func (m MsgServerImpl) Remove(c context.Context, msg *MsgRemove) (*MsgRemoveResponse, error) {
 	ctx := sdk.UnwrapSDKContext(c)
-	ctx.KVStore(capKey2).Delete(msg.Key)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	store.Delete(msg.Key)
 	return &MsgRemoveResponse{}, nil
 }
+
+func (m MsgServerImpl) Iterate(c context.Context, msg *MsgIterate) (*MsgIterateResponse, error) {
+	ctx := sdk.UnwrapSDKContext(c)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	return iterate(ctx, store.Iterator(msg.Start, msg.End), msg.Limit), nil
+}
+
+func (m MsgServerImpl) ReverseIterate(c context.Context, msg *MsgReverseIterate) (*MsgIterateResponse, error) {
+	ctx := sdk.UnwrapSDKContext(c)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	return iterate(ctx, store.ReverseIterator(msg.Start, msg.End), msg.Limit), nil
+}
+
+func (m MsgServerImpl) DeletePrefix(c context.Context, msg *MsgDeletePrefix) (*MsgDeletePrefixResponse, error) {
+	ctx := sdk.UnwrapSDKContext(c)
+	store, err := m.store(ctx, msg.Store)
+	if err != nil {
+		return nil, err
+	}
+	// keys cannot be deleted while iterating
+	keys := [][]byte{}
+	iter := sdk.KVStorePrefixIterator(store, msg.Prefix)
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+	return &MsgDeletePrefixResponse{Count: uint32(len(keys))}, nil
+}
+
+func (m MsgServerImpl) store(ctx sdk.Context, name string) (sdk.KVStore, error) {
+	switch name {
+	case "", capKey2.Name():
+		return ctx.KVStore(capKey2), nil
+	case capKey1.Name():
+		return ctx.KVStore(capKey1), nil
+	}
+	key, ok := m.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("store %s is not mounted", name)
+	}
+	return ctx.KVStore(key), nil
+}
+
+func iterate(ctx sdk.Context, iter types.Iterator, limit uint32) *MsgIterateResponse {
+	defer iter.Close()
+	res := &MsgIterateResponse{}
+	res.Hash, res.Count = hashIterator(iter, limit)
+	ctx.KVStore(capKey1).Set(IterateResultKey, res.Hash)
+	return res
+}
+
+// hashIterator returns the hash of the keys and values of up to limit items
+// of iter, or of all items if limit is 0, and their number.
+func hashIterator(iter types.Iterator, limit uint32) ([]byte, uint32) {
+	hash := sha256.New()
+	count := uint32(0)
+	for ; iter.Valid() && (limit == 0 || count < limit); iter.Next() {
+		for _, bz := range [][]byte{iter.Key(), iter.Value()} {
+			hash.Write(binary.AppendUvarint(nil, uint64(len(bz))))
+			hash.Write(bz)
+		}
+		count++
+	}
+	return hash.Sum(nil), count
+}
@@ -0,0 +1,384 @@
+package sdkapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	cryptocodec "github.com/cosmos/cosmos-sdk/crypto/codec"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx/signing"
+	authsigning "github.com/cosmos/cosmos-sdk/x/auth/signing"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
+	tmcrypto "github.com/tendermint/tendermint/crypto"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	"github.com/tendermint/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/ulbqb/cosmos-stateless-poc/testapp"
+)
+
+const (
+	// numCandidates validators join the set after genesis, each replacing
+	// the validator of the lowest power.
+	numCandidates = 3
+	// numHolders accounts only send, delegate and vote.
+	numHolders = 4
+
+	// blocks are a second apart, so proposals end after votingPeriod blocks
+	// and unbondings complete after unbondingTime blocks
+	votingPeriod  = 4 * time.Second
+	unbondingTime = 6 * time.Second
+
+	// every candidateInterval-th block creates the next candidate
+	candidateInterval = 5
+	// entries are limited per delegation by staking
+	maxUnbondings = 7
+)
+
+var (
+	accountCoins  = sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1_000_000_000_000))
+	proposalCoins = sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1_000))
+)
+
+// NewChain returns a chain of an App with numValidators genesis validators
+// of different power. Its blocks have signed txs paying fees, which send
+// coins, delegate, undelegate, withdraw rewards, create validators and
+// submit and vote on text proposals. The same seed always generates the
+// same blocks.
+func NewChain(seed int64, numValidators int) (*testapp.Chain, error) {
+	if numValidators < 1 {
+		return nil, fmt.Errorf("chain needs at least one validator")
+	}
+	app, err := NewApp(dbm.NewMemDB())
+	if err != nil {
+		return nil, err
+	}
+
+	chainID := fmt.Sprintf("sdkapp-%d", seed)
+	g := &generator{
+		r:           rand.New(rand.NewSource(seed)),
+		chainID:     chainID,
+		txConfig:    MakeEncodingConfig().TxConfig,
+		delegations: map[delegation]int64{},
+		unbondings:  map[delegation]int{},
+	}
+	// validators are operated by the first accounts
+	numAccounts := numValidators + numCandidates + numHolders
+	for i := 0; i < numAccounts; i++ {
+		key := secp256k1.GenPrivKeyFromSecret([]byte(fmt.Sprintf("sdkapp/%d/account/%d", seed, i)))
+		g.accounts = append(g.accounts, &account{key: key, number: uint64(i)})
+	}
+	privVals := []types.PrivValidator{}
+	for i := 0; i < numValidators+numCandidates; i++ {
+		privKey := ed25519.GenPrivKeyFromSecret([]byte(fmt.Sprintf("sdkapp/%d/validator/%d", seed, i)))
+		privVals = append(privVals, types.NewMockPVWithParams(privKey, false, false))
+		g.consKeys = append(g.consKeys, privKey.PubKey())
+	}
+
+	appState, err := g.genesis(app.appCodec, numValidators)
+	if err != nil {
+		return nil, err
+	}
+	cp := types.DefaultConsensusParams()
+	return testapp.NewChainWithConfig(g.r, testapp.ChainConfig{
+		App:             app.BaseApp,
+		ChainID:         chainID,
+		PrivValidators:  privVals,
+		AppState:        appState,
+		ConsensusParams: cp,
+		Txs:             g.txs,
+	})
+}
+
+type account struct {
+	key      cryptotypes.PrivKey
+	number   uint64
+	sequence uint64
+}
+
+func (a *account) address() sdk.AccAddress {
+	return sdk.AccAddress(a.key.PubKey().Address())
+}
+
+// delegation is a pair of account and validator indexes.
+type delegation struct {
+	delegator, validator int
+}
+
+type proposal struct {
+	id uint64
+	// votingEnd is the height whose EndBlock tallies the proposal
+	votingEnd int64
+}
+
+// generator generates valid txs by tracking the state they change.
+type generator struct {
+	r        *rand.Rand
+	chainID  string
+	txConfig client.TxConfig
+
+	accounts []*account
+	// consKeys are the consensus keys of the validators, which are
+	// operated by the accounts of the same index
+	consKeys []tmcrypto.PubKey
+	// numValidators is the number of created validators
+	numValidators int
+	// delegations are the delegated tokens
+	delegations map[delegation]int64
+	unbondings  map[delegation]int
+	proposals   []proposal
+}
+
+func (g *generator) genesis(cdc codec.JSONCodec, numValidators int) ([]byte, error) {
+	genAccounts := authtypes.GenesisAccounts{}
+	balances := []banktypes.Balance{}
+	for _, acc := range g.accounts {
+		genAccounts = append(genAccounts, authtypes.NewBaseAccount(acc.address(), nil, acc.number, 0))
+		balances = append(balances, banktypes.Balance{Address: acc.address().String(), Coins: accountCoins})
+	}
+
+	validators := []stakingtypes.Validator{}
+	delegations := []stakingtypes.Delegation{}
+	bonded := sdk.ZeroInt()
+	for i := 0; i < numValidators; i++ {
+		tokens := sdk.TokensFromConsensusPower(int64(10+i), sdk.DefaultPowerReduction)
+		pubKey, err := cryptocodec.FromTmPubKeyInterface(g.consKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		pubKeyAny, err := codectypes.NewAnyWithValue(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		operator := sdk.ValAddress(g.accounts[i].address())
+		validators = append(validators, stakingtypes.Validator{
+			OperatorAddress:   operator.String(),
+			ConsensusPubkey:   pubKeyAny,
+			Status:            stakingtypes.Bonded,
+			Tokens:            tokens,
+			DelegatorShares:   tokens.ToDec(),
+			UnbondingTime:     time.Unix(0, 0).UTC(),
+			Commission:        stakingtypes.NewCommission(sdk.NewDecWithPrec(1, 1), sdk.NewDecWithPrec(2, 1), sdk.NewDecWithPrec(1, 2)),
+			MinSelfDelegation: sdk.OneInt(),
+		})
+		delegations = append(delegations, stakingtypes.NewDelegation(g.accounts[i].address(), operator, tokens.ToDec()))
+		g.delegations[delegation{i, i}] = tokens.Int64()
+		bonded = bonded.Add(tokens)
+	}
+	g.numValidators = numValidators
+	balances = append(balances, banktypes.Balance{
+		Address: authtypes.NewModuleAddress(stakingtypes.BondedPoolName).String(),
+		Coins:   sdk.NewCoins(sdk.NewCoin(sdk.DefaultBondDenom, bonded)),
+	})
+
+	stakingParams := stakingtypes.DefaultParams()
+	stakingParams.UnbondingTime = unbondingTime
+	stakingParams.MaxValidators = uint32(numValidators)
+	stakingParams.MaxEntries = maxUnbondings
+	govGenesis := govtypes.DefaultGenesisState()
+	govGenesis.DepositParams.MinDeposit = proposalCoins
+	govGenesis.VotingParams.VotingPeriod = votingPeriod
+
+	genesisState := map[string]json.RawMessage{}
+	for name, state := range map[string]codec.ProtoMarshaler{
+		authtypes.ModuleName:    authtypes.NewGenesisState(authtypes.DefaultParams(), genAccounts),
+		banktypes.ModuleName:    banktypes.NewGenesisState(banktypes.DefaultParams(), balances, nil, nil),
+		stakingtypes.ModuleName: stakingtypes.NewGenesisState(stakingParams, validators, delegations),
+		distrtypes.ModuleName:   distrtypes.DefaultGenesisState(),
+		govtypes.ModuleName:     govGenesis,
+	} {
+		bz, err := cdc.MarshalJSON(state)
+		if err != nil {
+			return nil, err
+		}
+		genesisState[name] = bz
+	}
+	return json.Marshal(genesisState)
+}
+
+// txs returns n random txs of height, after the tx creating the next
+// candidate every candidateInterval blocks.
+func (g *generator) txs(height int64, n int) (types.Txs, error) {
+	txs := types.Txs{}
+	add := func(signer int, msg sdk.Msg) error {
+		tx, err := g.sign(signer, msg)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, tx)
+		return nil
+	}
+
+	if height%candidateInterval == 0 && g.numValidators < len(g.consKeys) {
+		signer, msg, err := g.createValidator()
+		if err != nil {
+			return nil, err
+		}
+		if err = add(signer, msg); err != nil {
+			return nil, err
+		}
+	}
+	for i := 0; i < n; i++ {
+		signer, msg, err := g.randomMsg(height)
+		if err != nil {
+			return nil, err
+		}
+		if err = add(signer, msg); err != nil {
+			return nil, err
+		}
+	}
+	return txs, nil
+}
+
+// createValidator returns a msg creating the next candidate with more power
+// than any genesis validator.
+func (g *generator) createValidator() (int, sdk.Msg, error) {
+	i := g.numValidators
+	g.numValidators++
+	pubKey, err := cryptocodec.FromTmPubKeyInterface(g.consKeys[i])
+	if err != nil {
+		return 0, nil, err
+	}
+	tokens := sdk.TokensFromConsensusPower(int64(20+g.r.Intn(10)), sdk.DefaultPowerReduction)
+	operator := sdk.ValAddress(g.accounts[i].address())
+	msg, err := stakingtypes.NewMsgCreateValidator(
+		operator, pubKey, sdk.NewCoin(sdk.DefaultBondDenom, tokens),
+		stakingtypes.NewDescription(fmt.Sprintf("candidate %d", i), "", "", "", ""),
+		stakingtypes.NewCommissionRates(sdk.NewDecWithPrec(5, 2), sdk.NewDecWithPrec(2, 1), sdk.NewDecWithPrec(1, 2)),
+		sdk.OneInt(),
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	g.delegations[delegation{i, i}] = tokens.Int64()
+	return i, msg, nil
+}
+
+// randomMsg returns a random msg that succeeds at height and its signer.
+func (g *generator) randomMsg(height int64) (int, sdk.Msg, error) {
+	switch g.r.Intn(6) {
+	case 1:
+		from, val := g.r.Intn(len(g.accounts)), g.r.Intn(g.numValidators)
+		tokens := sdk.TokensFromConsensusPower(int64(1+g.r.Intn(5)), sdk.DefaultPowerReduction)
+		g.delegations[delegation{from, val}] += tokens.Int64()
+		return from, stakingtypes.NewMsgDelegate(
+			g.accounts[from].address(), g.validator(val), sdk.NewCoin(sdk.DefaultBondDenom, tokens),
+		), nil
+	case 2:
+		if d, ok := g.randomDelegation(); ok && g.unbondings[d] < maxUnbondings {
+			// half of the tokens, so that the delegation remains
+			tokens := g.delegations[d] / 2
+			g.delegations[d] -= tokens
+			g.unbondings[d]++
+			return d.delegator, stakingtypes.NewMsgUndelegate(
+				g.accounts[d.delegator].address(), g.validator(d.validator), sdk.NewInt64Coin(sdk.DefaultBondDenom, tokens),
+			), nil
+		}
+	case 3:
+		if d, ok := g.randomDelegation(); ok {
+			return d.delegator, distrtypes.NewMsgWithdrawDelegatorReward(
+				g.accounts[d.delegator].address(), g.validator(d.validator),
+			), nil
+		}
+	case 4:
+		from := g.r.Intn(len(g.accounts))
+		g.proposals = append(g.proposals, proposal{
+			id:        uint64(len(g.proposals) + 1),
+			votingEnd: height + int64(votingPeriod/time.Second),
+		})
+		msg, err := govtypes.NewMsgSubmitProposal(
+			govtypes.NewTextProposal(fmt.Sprintf("proposal %d", len(g.proposals)), "text"),
+			proposalCoins, g.accounts[from].address(),
+		)
+		return from, msg, err
+	case 5:
+		active := []proposal{}
+		for _, p := range g.proposals {
+			if height < p.votingEnd {
+				active = append(active, p)
+			}
+		}
+		if len(active) > 0 {
+			from := g.r.Intn(len(g.accounts))
+			p := active[g.r.Intn(len(active))]
+			option := govtypes.VoteOption(1 + g.r.Intn(4))
+			return from, govtypes.NewMsgVote(g.accounts[from].address(), p.id, option), nil
+		}
+	}
+
+	from, to := g.r.Intn(len(g.accounts)), g.r.Intn(len(g.accounts))
+	amount := sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1+g.r.Int63n(1_000_000)))
+	return from, banktypes.NewMsgSend(g.accounts[from].address(), g.accounts[to].address(), amount), nil
+}
+
+func (g *generator) validator(i int) sdk.ValAddress {
+	return sdk.ValAddress(g.accounts[i].address())
+}
+
+// randomDelegation returns a random delegation of at least two tokens.
+func (g *generator) randomDelegation() (delegation, bool) {
+	// in a deterministic order
+	candidates := []delegation{}
+	for from := range g.accounts {
+		for val := 0; val < g.numValidators; val++ {
+			d := delegation{from, val}
+			if g.delegations[d] >= 2 {
+				candidates = append(candidates, d)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return delegation{}, false
+	}
+	return candidates[g.r.Intn(len(candidates))], true
+}
+
+// sign returns the tx of msg signed by the account of index signer, paying
+// a random fee.
+func (g *generator) sign(signer int, msg sdk.Msg) ([]byte, error) {
+	acc := g.accounts[signer]
+	txBuilder := g.txConfig.NewTxBuilder()
+	if err := txBuilder.SetMsgs(msg); err != nil {
+		return nil, err
+	}
+	txBuilder.SetFeeAmount(sdk.NewCoins(sdk.NewInt64Coin(sdk.DefaultBondDenom, 1_000+g.r.Int63n(1_000))))
+	txBuilder.SetGasLimit(1_000_000)
+
+	// signer infos are set before signing as they are signed
+	signMode := g.txConfig.SignModeHandler().DefaultMode()
+	sig := signing.SignatureV2{
+		PubKey:   acc.key.PubKey(),
+		Data:     &signing.SingleSignatureData{SignMode: signMode},
+		Sequence: acc.sequence,
+	}
+	if err := txBuilder.SetSignatures(sig); err != nil {
+		return nil, err
+	}
+	signBytes, err := g.txConfig.SignModeHandler().GetSignBytes(signMode, authsigning.SignerData{
+		ChainID:       g.chainID,
+		AccountNumber: acc.number,
+		Sequence:      acc.sequence,
+	}, txBuilder.GetTx())
+	if err != nil {
+		return nil, err
+	}
+	if sig.Data.(*signing.SingleSignatureData).Signature, err = acc.key.Sign(signBytes); err != nil {
+		return nil, err
+	}
+	if err = txBuilder.SetSignatures(sig); err != nil {
+		return nil, err
+	}
+	acc.sequence++
+	return g.txConfig.TxEncoder()(txBuilder.GetTx())
+}
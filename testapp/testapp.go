@@ -7,10 +7,13 @@ import (
 	"math/rand"
 
 	"github.com/cosmos/cosmos-sdk/baseapp"
+	"github.com/cosmos/cosmos-sdk/client"
 	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/simapp"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/msgservice"
+	upgradetypes "github.com/cosmos/cosmos-sdk/x/upgrade/types"
 	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
@@ -24,17 +27,71 @@ var (
 )
 
 func NewTestApp() (*baseapp.BaseApp, error) {
+	return NewTestAppWithDB(dbm.NewMemDB())
+}
+
+func NewTestAppWithDB(db dbm.DB) (*baseapp.BaseApp, error) {
+	return NewTestAppWithConfig(db, Config{})
+}
+
+// Config configures the stores of a test app. key1 and key2 are always
+// mounted.
+type Config struct {
+	// Stores are the names of additional KV stores.
+	Stores []string
+	// TransientStores are the names of transient stores. Their content is
+	// hashed into key1 at the end of every block.
+	TransientStores []string
+	// Upgrades are applied when the app is loaded at UpgradeHeight-1.
+	UpgradeHeight int64
+	Upgrades      storetypes.StoreUpgrades
+}
+
+func NewTestAppWithConfig(db dbm.DB, cfg Config) (*baseapp.BaseApp, error) {
 	encCfg := simapp.MakeTestEncodingConfig()
 	RegisterInterfaces(encCfg.InterfaceRegistry)
-	app := baseapp.NewBaseApp("testapp", log.NewTMLogger(log.NewSyncWriter(io.Discard)), dbm.NewMemDB(), encCfg.TxConfig.TxDecoder())
+	app := baseapp.NewBaseApp("testapp", log.NewTMLogger(log.NewSyncWriter(io.Discard)), db, encCfg.TxConfig.TxDecoder())
 	app.SetInterfaceRegistry(encCfg.InterfaceRegistry)
+
+	keys := map[string]sdk.StoreKey{}
+	for _, name := range cfg.Stores {
+		keys[name] = sdk.NewKVStoreKey(name)
+	}
+	tkeys := []*sdk.TransientStoreKey{}
+	for _, name := range cfg.TransientStores {
+		tkey := sdk.NewTransientStoreKey(name)
+		keys[name] = tkey
+		tkeys = append(tkeys, tkey)
+	}
+	_, ok1 := keys[capKey1.Name()]
+	_, ok2 := keys[capKey2.Name()]
+	if ok1 || ok2 || len(keys) != len(cfg.Stores)+len(cfg.TransientStores) {
+		return nil, fmt.Errorf("store names must be unique")
+	}
 	RegisterMsgServer(
 		app.MsgServiceRouter(),
-		MsgServerImpl{},
+		MsgServerImpl{keys: keys},
 	)
 
 	app.MountStores(capKey1, capKey2)
+	for _, key := range keys {
+		app.MountStores(key)
+	}
 	app.SetParamStore(&paramStore{db: dbm.NewMemDB()})
+	if len(tkeys) > 0 {
+		app.SetEndBlocker(func(ctx sdk.Context, _ abci.RequestEndBlock) abci.ResponseEndBlock {
+			for _, tkey := range tkeys {
+				iter := ctx.TransientStore(tkey).Iterator(nil, nil)
+				hash, _ := hashIterator(iter, 0)
+				iter.Close()
+				ctx.KVStore(capKey1).Set([]byte("transient/"+tkey.Name()), hash)
+			}
+			return abci.ResponseEndBlock{}
+		})
+	}
+	if cfg.UpgradeHeight > 0 {
+		app.SetStoreLoader(upgradetypes.UpgradeStoreLoader(cfg.UpgradeHeight, &cfg.Upgrades))
+	}
 
 	// stores are mounted
 	err := app.LoadLatestVersion()
@@ -86,55 +143,27 @@ func (ps *paramStore) Get(_ sdk.Context, key []byte, ptr interface{}) {
 }
 
 func ExecuteBlockWithTxs(app *baseapp.BaseApp, numTransactions int, blockHeight int64, r *rand.Rand) (*types.Block, error) {
-	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: blockHeight}})
-
 	encCfg := simapp.MakeTestEncodingConfig()
 	txs := types.Txs{}
 	for txNum := 0; txNum < numTransactions; txNum++ {
-		txBuilder := encCfg.TxConfig.NewTxBuilder()
-
-		key := make([]byte, 1)
-		_, err := r.Read(key)
-		if err != nil {
-			return nil, err
-		}
-		value := make([]byte, 10)
-		_, err = r.Read(value)
+		txBytes, err := RandomTx(encCfg.TxConfig, r)
 		if err != nil {
 			return nil, err
 		}
-		sord := make([]byte, 1)
-		_, err = r.Read(sord)
-		if err != nil {
-			return nil, err
-		}
-		if sord[0]%8 == 0 {
-			err = txBuilder.SetMsgs(&MsgRemove{Key: key})
-			if err != nil {
-				return nil, err
-			}
-		} else if sord[0]%8 == 1 {
-			err = txBuilder.SetMsgs(&MsgGet{Key: key})
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			err = txBuilder.SetMsgs(&MsgSet{Key: key, Value: value})
-			if err != nil {
-				return nil, err
-			}
-		}
+		txs = append(txs, txBytes)
+	}
+	return ExecuteBlock(app, txs, blockHeight)
+}
 
-		txBytes, err := encCfg.TxConfig.TxEncoder()(txBuilder.GetTx())
-		if err != nil {
-			return nil, err
-		}
+// ExecuteBlock delivers txs in a block of blockHeight without committing it.
+func ExecuteBlock(app *baseapp.BaseApp, txs types.Txs, blockHeight int64) (*types.Block, error) {
+	app.BeginBlock(abci.RequestBeginBlock{Header: tmproto.Header{Height: blockHeight}})
 
-		resp := app.DeliverTx(abci.RequestDeliverTx{Tx: txBytes})
+	for _, tx := range txs {
+		resp := app.DeliverTx(abci.RequestDeliverTx{Tx: tx})
 		if !resp.IsOK() {
 			return nil, fmt.Errorf(resp.String())
 		}
-		txs = append(txs, txBytes)
 	}
 
 	app.EndBlock(abci.RequestEndBlock{Height: blockHeight})
@@ -153,3 +182,37 @@ func ExecuteBlockWithTxs(app *baseapp.BaseApp, numTransactions int, blockHeight
 	}
 	return block, nil
 }
+
+// EncodeTx returns an encoded tx of msgs.
+func EncodeTx(txConfig client.TxConfig, msgs ...sdk.Msg) ([]byte, error) {
+	txBuilder := txConfig.NewTxBuilder()
+	if err := txBuilder.SetMsgs(msgs...); err != nil {
+		return nil, err
+	}
+	return txConfig.TxEncoder()(txBuilder.GetTx())
+}
+
+// RandomTx returns an encoded tx setting, getting or removing a random key.
+func RandomTx(txConfig client.TxConfig, r *rand.Rand) ([]byte, error) {
+	key := make([]byte, 1)
+	_, err := r.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, 10)
+	_, err = r.Read(value)
+	if err != nil {
+		return nil, err
+	}
+	sord := make([]byte, 1)
+	_, err = r.Read(sord)
+	if err != nil {
+		return nil, err
+	}
+	if sord[0]%8 == 0 {
+		return EncodeTx(txConfig, &MsgRemove{Key: key})
+	} else if sord[0]%8 == 1 {
+		return EncodeTx(txConfig, &MsgGet{Key: key})
+	}
+	return EncodeTx(txConfig, &MsgSet{Key: key, Value: value})
+}
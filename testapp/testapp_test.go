@@ -21,3 +21,33 @@ func TestTestApp(t *testing.T) {
 		app.Commit()
 	}
 }
+
+func TestChain(t *testing.T) {
+	generate := func() *Chain {
+		chain, err := NewChain(7, 4)
+		require.NoError(t, err)
+		for h := int64(1); h <= 20; h++ {
+			_, err = chain.NextBlock(BlockOptions{Txs: 4, Evidence: h%5 == 0, Absent: int(h % 2)})
+			require.NoError(t, err)
+		}
+		return chain
+	}
+	chain := generate()
+
+	for h := int64(1); h <= chain.Height(); h++ {
+		block := chain.Block(h)
+		require.NoError(t, block.ValidateBasic())
+		require.Equal(t, chain.Validators(h).Hash(), []byte(block.ValidatorsHash))
+		require.NoError(t, chain.Validators(h).VerifyCommit(chain.ChainID, chain.BlockID(h), h, chain.Commit(h)))
+		if h > 1 {
+			require.Equal(t, chain.BlockID(h-1), block.LastBlockID)
+		}
+		if h%5 == 0 {
+			require.Len(t, block.Evidence.Evidence, 1)
+			require.NoError(t, block.Evidence.Evidence[0].ValidateBasic())
+		}
+	}
+
+	// deterministic
+	require.Equal(t, chain.BlockID(chain.Height()), generate().BlockID(chain.Height()))
+}
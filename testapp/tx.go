@@ -18,3 +18,18 @@ var _ sdk.Msg = &MsgRemove{}
 
 func (msg *MsgRemove) GetSigners() []sdk.AccAddress { return []sdk.AccAddress{} }
 func (msg *MsgRemove) ValidateBasic() error         { return nil }
+
+var _ sdk.Msg = &MsgIterate{}
+
+func (msg *MsgIterate) GetSigners() []sdk.AccAddress { return []sdk.AccAddress{} }
+func (msg *MsgIterate) ValidateBasic() error         { return nil }
+
+var _ sdk.Msg = &MsgReverseIterate{}
+
+func (msg *MsgReverseIterate) GetSigners() []sdk.AccAddress { return []sdk.AccAddress{} }
+func (msg *MsgReverseIterate) ValidateBasic() error         { return nil }
+
+var _ sdk.Msg = &MsgDeletePrefix{}
+
+func (msg *MsgDeletePrefix) GetSigners() []sdk.AccAddress { return []sdk.AccAddress{} }
+func (msg *MsgDeletePrefix) ValidateBasic() error         { return nil }
@@ -29,7 +29,8 @@ var _ = math.Inf
 const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 
 type MsgGet struct {
-	Key []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Key   []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Store string `protobuf:"bytes,2,opt,name=store,proto3" json:"store,omitempty"`
 }
 
 func (m *MsgGet) Reset()         { *m = MsgGet{} }
@@ -72,6 +73,13 @@ func (m *MsgGet) GetKey() []byte {
 	return nil
 }
 
+func (m *MsgGet) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
 type MsgGetResponse struct {
 }
 
@@ -111,6 +119,7 @@ var xxx_messageInfo_MsgGetResponse proto.InternalMessageInfo
 type MsgSet struct {
 	Key   []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Store string `protobuf:"bytes,3,opt,name=store,proto3" json:"store,omitempty"`
 }
 
 func (m *MsgSet) Reset()         { *m = MsgSet{} }
@@ -160,6 +169,13 @@ func (m *MsgSet) GetValue() []byte {
 	return nil
 }
 
+func (m *MsgSet) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
 type MsgSetResponse struct {
 }
 
@@ -197,7 +213,8 @@ func (m *MsgSetResponse) XXX_DiscardUnknown() {
 var xxx_messageInfo_MsgSetResponse proto.InternalMessageInfo
 
 type MsgRemove struct {
-	Key []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Key   []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Store string `protobuf:"bytes,2,opt,name=store,proto3" json:"store,omitempty"`
 }
 
 func (m *MsgRemove) Reset()         { *m = MsgRemove{} }
@@ -240,6 +257,13 @@ func (m *MsgRemove) GetKey() []byte {
 	return nil
 }
 
+func (m *MsgRemove) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
 type MsgRemoveResponse struct {
 }
 
@@ -276,6 +300,296 @@ func (m *MsgRemoveResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_MsgRemoveResponse proto.InternalMessageInfo
 
+// MsgIterate iterates over the keys in [start, end), a nil end being
+// unbounded, and stops after limit keys unless limit is 0.
+type MsgIterate struct {
+	Start []byte `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	Limit uint32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Store string `protobuf:"bytes,4,opt,name=store,proto3" json:"store,omitempty"`
+}
+
+func (m *MsgIterate) Reset()         { *m = MsgIterate{} }
+func (m *MsgIterate) String() string { return proto.CompactTextString(m) }
+func (*MsgIterate) ProtoMessage()    {}
+func (*MsgIterate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0fd2153dc07d3b5c, []int{6}
+}
+func (m *MsgIterate) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgIterate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgIterate.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgIterate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgIterate.Merge(m, src)
+}
+func (m *MsgIterate) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgIterate) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgIterate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgIterate proto.InternalMessageInfo
+
+func (m *MsgIterate) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *MsgIterate) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+func (m *MsgIterate) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *MsgIterate) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+// MsgReverseIterate is MsgIterate in descending order.
+type MsgReverseIterate struct {
+	Start []byte `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End   []byte `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	Limit uint32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	Store string `protobuf:"bytes,4,opt,name=store,proto3" json:"store,omitempty"`
+}
+
+func (m *MsgReverseIterate) Reset()         { *m = MsgReverseIterate{} }
+func (m *MsgReverseIterate) String() string { return proto.CompactTextString(m) }
+func (*MsgReverseIterate) ProtoMessage()    {}
+func (*MsgReverseIterate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0fd2153dc07d3b5c, []int{7}
+}
+func (m *MsgReverseIterate) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgReverseIterate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgReverseIterate.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgReverseIterate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgReverseIterate.Merge(m, src)
+}
+func (m *MsgReverseIterate) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgReverseIterate) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgReverseIterate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgReverseIterate proto.InternalMessageInfo
+
+func (m *MsgReverseIterate) GetStart() []byte {
+	if m != nil {
+		return m.Start
+	}
+	return nil
+}
+
+func (m *MsgReverseIterate) GetEnd() []byte {
+	if m != nil {
+		return m.End
+	}
+	return nil
+}
+
+func (m *MsgReverseIterate) GetLimit() uint32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+func (m *MsgReverseIterate) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+// MsgIterateResponse is the number of iterated keys and the sha256 hash of
+// the iterated keys and values, which is also stored under IterateResultKey.
+type MsgIterateResponse struct {
+	Count uint32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Hash  []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *MsgIterateResponse) Reset()         { *m = MsgIterateResponse{} }
+func (m *MsgIterateResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgIterateResponse) ProtoMessage()    {}
+func (*MsgIterateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0fd2153dc07d3b5c, []int{8}
+}
+func (m *MsgIterateResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgIterateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgIterateResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgIterateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgIterateResponse.Merge(m, src)
+}
+func (m *MsgIterateResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgIterateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgIterateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgIterateResponse proto.InternalMessageInfo
+
+func (m *MsgIterateResponse) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *MsgIterateResponse) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+// MsgDeletePrefix removes every key starting with prefix.
+type MsgDeletePrefix struct {
+	Prefix []byte `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	Store  string `protobuf:"bytes,2,opt,name=store,proto3" json:"store,omitempty"`
+}
+
+func (m *MsgDeletePrefix) Reset()         { *m = MsgDeletePrefix{} }
+func (m *MsgDeletePrefix) String() string { return proto.CompactTextString(m) }
+func (*MsgDeletePrefix) ProtoMessage()    {}
+func (*MsgDeletePrefix) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0fd2153dc07d3b5c, []int{9}
+}
+func (m *MsgDeletePrefix) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgDeletePrefix) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgDeletePrefix.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgDeletePrefix) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgDeletePrefix.Merge(m, src)
+}
+func (m *MsgDeletePrefix) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgDeletePrefix) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgDeletePrefix.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgDeletePrefix proto.InternalMessageInfo
+
+func (m *MsgDeletePrefix) GetPrefix() []byte {
+	if m != nil {
+		return m.Prefix
+	}
+	return nil
+}
+
+func (m *MsgDeletePrefix) GetStore() string {
+	if m != nil {
+		return m.Store
+	}
+	return ""
+}
+
+type MsgDeletePrefixResponse struct {
+	Count uint32 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+}
+
+func (m *MsgDeletePrefixResponse) Reset()         { *m = MsgDeletePrefixResponse{} }
+func (m *MsgDeletePrefixResponse) String() string { return proto.CompactTextString(m) }
+func (*MsgDeletePrefixResponse) ProtoMessage()    {}
+func (*MsgDeletePrefixResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_0fd2153dc07d3b5c, []int{10}
+}
+func (m *MsgDeletePrefixResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MsgDeletePrefixResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_MsgDeletePrefixResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *MsgDeletePrefixResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MsgDeletePrefixResponse.Merge(m, src)
+}
+func (m *MsgDeletePrefixResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *MsgDeletePrefixResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MsgDeletePrefixResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MsgDeletePrefixResponse proto.InternalMessageInfo
+
+func (m *MsgDeletePrefixResponse) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*MsgGet)(nil), "testapp.MsgGet")
 	proto.RegisterType((*MsgGetResponse)(nil), "testapp.MsgGetResponse")
@@ -283,29 +597,46 @@ func init() {
 	proto.RegisterType((*MsgSetResponse)(nil), "testapp.MsgSetResponse")
 	proto.RegisterType((*MsgRemove)(nil), "testapp.MsgRemove")
 	proto.RegisterType((*MsgRemoveResponse)(nil), "testapp.MsgRemoveResponse")
+	proto.RegisterType((*MsgIterate)(nil), "testapp.MsgIterate")
+	proto.RegisterType((*MsgReverseIterate)(nil), "testapp.MsgReverseIterate")
+	proto.RegisterType((*MsgIterateResponse)(nil), "testapp.MsgIterateResponse")
+	proto.RegisterType((*MsgDeletePrefix)(nil), "testapp.MsgDeletePrefix")
+	proto.RegisterType((*MsgDeletePrefixResponse)(nil), "testapp.MsgDeletePrefixResponse")
 }
 
 func init() { proto.RegisterFile("tx.proto", fileDescriptor_0fd2153dc07d3b5c) }
 
 var fileDescriptor_0fd2153dc07d3b5c = []byte{
-	// 266 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x28, 0xa9, 0xd0, 0x2b,
-	0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2f, 0x49, 0x2d, 0x2e, 0x49, 0x2c, 0x28, 0x90, 0x12, 0x49,
-	0xcf, 0x4f, 0xcf, 0x07, 0x8b, 0xe9, 0x83, 0x58, 0x10, 0x69, 0x25, 0x29, 0x2e, 0x36, 0xdf, 0xe2,
-	0x74, 0xf7, 0xd4, 0x12, 0x21, 0x01, 0x2e, 0xe6, 0xec, 0xd4, 0x4a, 0x09, 0x46, 0x05, 0x46, 0x0d,
-	0x9e, 0x20, 0x10, 0x53, 0x49, 0x80, 0x8b, 0x0f, 0x22, 0x17, 0x94, 0x5a, 0x5c, 0x90, 0x9f, 0x57,
-	0x9c, 0xaa, 0x64, 0x00, 0x56, 0x1d, 0x8c, 0x4d, 0xb5, 0x90, 0x08, 0x17, 0x6b, 0x59, 0x62, 0x4e,
-	0x69, 0xaa, 0x04, 0x13, 0x58, 0x0c, 0xc2, 0x81, 0x9a, 0x11, 0x8c, 0x64, 0x86, 0x2c, 0x17, 0xa7,
-	0x6f, 0x71, 0x7a, 0x50, 0x6a, 0x6e, 0x7e, 0x59, 0x2a, 0x16, 0x4b, 0x85, 0xb9, 0x04, 0xe1, 0xd2,
-	0x30, 0x3d, 0x46, 0x0b, 0x19, 0xb9, 0x98, 0x7d, 0x8b, 0xd3, 0x85, 0xf4, 0xb9, 0x98, 0x41, 0x4e,
-	0xe5, 0xd7, 0x83, 0x7a, 0x4a, 0x0f, 0xe2, 0x3e, 0x29, 0x71, 0x34, 0x01, 0x98, 0x46, 0x90, 0x86,
-	0x60, 0x74, 0x0d, 0xc1, 0xe8, 0x1a, 0x90, 0x5c, 0x27, 0x64, 0xc1, 0xc5, 0x06, 0x75, 0x9a, 0x10,
-	0xb2, 0x12, 0x88, 0x98, 0x94, 0x14, 0xa6, 0x18, 0x4c, 0xa7, 0x93, 0xfd, 0x89, 0x47, 0x72, 0x8c,
-	0x17, 0x1e, 0xc9, 0x31, 0x3e, 0x78, 0x24, 0xc7, 0x38, 0xe1, 0xb1, 0x1c, 0xc3, 0x85, 0xc7, 0x72,
-	0x0c, 0x37, 0x1e, 0xcb, 0x31, 0x44, 0xa9, 0xa6, 0x67, 0x96, 0x64, 0x94, 0x26, 0xe9, 0x25, 0xe7,
-	0xe7, 0xea, 0x97, 0xe6, 0x24, 0x15, 0x26, 0xe9, 0x17, 0x97, 0x24, 0x96, 0xa4, 0xe6, 0xa4, 0x16,
-	0x17, 0x67, 0x67, 0x96, 0xe8, 0x43, 0x8d, 0x4c, 0x62, 0x03, 0xc7, 0x88, 0x31, 0x20, 0x00, 0x00,
-	0xff, 0xff, 0x08, 0x6a, 0x88, 0x9f, 0xbc, 0x01, 0x00, 0x00,
+	// 449 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x94, 0xcd, 0x6e, 0xd3, 0x40,
+	0x10, 0xc7, 0xe3, 0xba, 0xa4, 0x74, 0x94, 0x7e, 0xb0, 0xad, 0x68, 0x64, 0x24, 0x2b, 0xca, 0xa9,
+	0x97, 0xc4, 0x88, 0x5e, 0x90, 0x90, 0x40, 0x42, 0x88, 0xaa, 0x48, 0x96, 0x90, 0x7d, 0xe3, 0x84,
+	0x6d, 0x86, 0x8d, 0x85, 0x9d, 0x35, 0x9e, 0x75, 0x54, 0xde, 0x82, 0xc7, 0xe2, 0xd8, 0x23, 0x47,
+	0x94, 0x3c, 0x03, 0x77, 0x64, 0xef, 0xc6, 0x59, 0x5c, 0x52, 0x71, 0xe9, 0x6d, 0x3e, 0x7f, 0xff,
+	0x59, 0xcf, 0xc8, 0xf0, 0x50, 0x5e, 0x4f, 0x8b, 0x52, 0x48, 0xc1, 0xf6, 0x24, 0x92, 0x8c, 0x8a,
+	0xc2, 0x39, 0xe5, 0x82, 0x8b, 0x26, 0xe6, 0xd5, 0x96, 0x4a, 0x8f, 0x9f, 0x42, 0xdf, 0x27, 0x7e,
+	0x89, 0x92, 0x1d, 0x83, 0xfd, 0x05, 0xbf, 0x0d, 0xad, 0x91, 0x75, 0x3e, 0x08, 0x6a, 0x93, 0x9d,
+	0xc2, 0x03, 0x92, 0xa2, 0xc4, 0xe1, 0xce, 0xc8, 0x3a, 0xdf, 0x0f, 0x94, 0x33, 0x3e, 0x86, 0x43,
+	0xd5, 0x11, 0x20, 0x15, 0x62, 0x4e, 0x38, 0x7e, 0xdb, 0x30, 0xc2, 0x6d, 0x8c, 0x45, 0x94, 0x55,
+	0x8a, 0x31, 0x08, 0x94, 0xb3, 0x21, 0xdb, 0xb7, 0xc9, 0xa1, 0x41, 0xbe, 0x80, 0x7d, 0x9f, 0x78,
+	0x80, 0xb9, 0x58, 0xe0, 0x7f, 0x0f, 0x78, 0x02, 0x8f, 0xda, 0xa6, 0x96, 0xf4, 0x11, 0xc0, 0x27,
+	0x7e, 0x25, 0xb1, 0x8c, 0xa4, 0xd6, 0x8f, 0x4a, 0xa9, 0x61, 0xca, 0xa9, 0x05, 0x70, 0xfe, 0x49,
+	0x4f, 0x5a, 0x9b, 0x75, 0x5d, 0x96, 0xe6, 0xa9, 0x6c, 0xe6, 0x3c, 0x08, 0x94, 0xb3, 0x91, 0xdd,
+	0x35, 0x65, 0xb9, 0x96, 0x5d, 0x60, 0x49, 0x78, 0x9f, 0x42, 0x2f, 0x81, 0x6d, 0x9e, 0xb2, 0x7e,
+	0x60, 0x5d, 0x9b, 0x88, 0x6a, 0xae, 0x94, 0x0e, 0x02, 0xe5, 0x30, 0x06, 0xbb, 0xb3, 0x88, 0x66,
+	0x5a, 0xaa, 0xb1, 0xc7, 0xaf, 0xe0, 0xc8, 0x27, 0xfe, 0x06, 0x33, 0x94, 0xf8, 0xbe, 0xc4, 0xcf,
+	0xe9, 0x35, 0x7b, 0x0c, 0xfd, 0xa2, 0xb1, 0xf4, 0x9c, 0xda, 0xdb, 0xf2, 0x81, 0x3d, 0x38, 0xeb,
+	0x00, 0xee, 0x9e, 0xe2, 0xd9, 0xef, 0x1d, 0xb0, 0x7d, 0xe2, 0xcc, 0x03, 0xbb, 0xbe, 0xb4, 0xa3,
+	0xa9, 0xbe, 0xc9, 0xa9, 0x3a, 0x24, 0xe7, 0xac, 0x13, 0x68, 0x71, 0x1e, 0xd8, 0x61, 0xb7, 0x21,
+	0xec, 0x36, 0x18, 0x07, 0xc3, 0x9e, 0x43, 0x5f, 0x5f, 0x0b, 0x33, 0x4b, 0x54, 0xcc, 0x71, 0x6e,
+	0xc7, 0xda, 0xce, 0x17, 0xb0, 0xb7, 0x5e, 0xda, 0x89, 0x59, 0xa6, 0x83, 0xce, 0x93, 0x7f, 0x04,
+	0xdb, 0xe6, 0x2b, 0x38, 0xec, 0x2c, 0xbe, 0x23, 0x65, 0xe6, 0xee, 0x46, 0xbd, 0x83, 0xc1, 0x5f,
+	0xab, 0x19, 0x9a, 0xc5, 0x66, 0xc6, 0x19, 0x6d, 0xcb, 0xac, 0x59, 0xaf, 0x2f, 0x7f, 0x2c, 0x5d,
+	0xeb, 0x66, 0xe9, 0x5a, 0xbf, 0x96, 0xae, 0xf5, 0x7d, 0xe5, 0xf6, 0x6e, 0x56, 0x6e, 0xef, 0xe7,
+	0xca, 0xed, 0x7d, 0x98, 0xf0, 0x54, 0xce, 0xaa, 0x78, 0x9a, 0x88, 0xdc, 0xab, 0xb2, 0xf8, 0x6b,
+	0xec, 0x25, 0x82, 0x72, 0x41, 0x13, 0x92, 0x91, 0xc4, 0x0c, 0x89, 0x26, 0x85, 0x48, 0x3c, 0x2d,
+	0x10, 0xf7, 0x9b, 0x9f, 0xc5, 0xc5, 0x9f, 0x01, 0x00, 0x9c, 0xa3, 0x81, 0x22, 0x57, 0x04, 0x00,
+	0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -323,6 +654,9 @@ type MsgClient interface {
 	Get(ctx context.Context, in *MsgGet, opts ...grpc.CallOption) (*MsgGetResponse, error)
 	Set(ctx context.Context, in *MsgSet, opts ...grpc.CallOption) (*MsgSetResponse, error)
 	Remove(ctx context.Context, in *MsgRemove, opts ...grpc.CallOption) (*MsgRemoveResponse, error)
+	Iterate(ctx context.Context, in *MsgIterate, opts ...grpc.CallOption) (*MsgIterateResponse, error)
+	ReverseIterate(ctx context.Context, in *MsgReverseIterate, opts ...grpc.CallOption) (*MsgIterateResponse, error)
+	DeletePrefix(ctx context.Context, in *MsgDeletePrefix, opts ...grpc.CallOption) (*MsgDeletePrefixResponse, error)
 }
 
 type msgClient struct {
@@ -360,11 +694,41 @@ func (c *msgClient) Remove(ctx context.Context, in *MsgRemove, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *msgClient) Iterate(ctx context.Context, in *MsgIterate, opts ...grpc.CallOption) (*MsgIterateResponse, error) {
+	out := new(MsgIterateResponse)
+	err := c.cc.Invoke(ctx, "/testapp.Msg/Iterate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) ReverseIterate(ctx context.Context, in *MsgReverseIterate, opts ...grpc.CallOption) (*MsgIterateResponse, error) {
+	out := new(MsgIterateResponse)
+	err := c.cc.Invoke(ctx, "/testapp.Msg/ReverseIterate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msgClient) DeletePrefix(ctx context.Context, in *MsgDeletePrefix, opts ...grpc.CallOption) (*MsgDeletePrefixResponse, error) {
+	out := new(MsgDeletePrefixResponse)
+	err := c.cc.Invoke(ctx, "/testapp.Msg/DeletePrefix", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MsgServer is the server API for Msg service.
 type MsgServer interface {
 	Get(context.Context, *MsgGet) (*MsgGetResponse, error)
 	Set(context.Context, *MsgSet) (*MsgSetResponse, error)
 	Remove(context.Context, *MsgRemove) (*MsgRemoveResponse, error)
+	Iterate(context.Context, *MsgIterate) (*MsgIterateResponse, error)
+	ReverseIterate(context.Context, *MsgReverseIterate) (*MsgIterateResponse, error)
+	DeletePrefix(context.Context, *MsgDeletePrefix) (*MsgDeletePrefixResponse, error)
 }
 
 // UnimplementedMsgServer can be embedded to have forward compatible implementations.
@@ -380,6 +744,15 @@ func (*UnimplementedMsgServer) Set(ctx context.Context, req *MsgSet) (*MsgSetRes
 func (*UnimplementedMsgServer) Remove(ctx context.Context, req *MsgRemove) (*MsgRemoveResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
 }
+func (*UnimplementedMsgServer) Iterate(ctx context.Context, req *MsgIterate) (*MsgIterateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Iterate not implemented")
+}
+func (*UnimplementedMsgServer) ReverseIterate(ctx context.Context, req *MsgReverseIterate) (*MsgIterateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReverseIterate not implemented")
+}
+func (*UnimplementedMsgServer) DeletePrefix(ctx context.Context, req *MsgDeletePrefix) (*MsgDeletePrefixResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePrefix not implemented")
+}
 
 func RegisterMsgServer(s grpc1.Server, srv MsgServer) {
 	s.RegisterService(&_Msg_serviceDesc, srv)
@@ -439,6 +812,60 @@ func _Msg_Remove_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Msg_Iterate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgIterate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).Iterate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/testapp.Msg/Iterate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).Iterate(ctx, req.(*MsgIterate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_ReverseIterate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgReverseIterate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).ReverseIterate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/testapp.Msg/ReverseIterate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).ReverseIterate(ctx, req.(*MsgReverseIterate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Msg_DeletePrefix_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MsgDeletePrefix)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsgServer).DeletePrefix(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/testapp.Msg/DeletePrefix",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsgServer).DeletePrefix(ctx, req.(*MsgDeletePrefix))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Msg_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "testapp.Msg",
 	HandlerType: (*MsgServer)(nil),
@@ -455,6 +882,18 @@ var _Msg_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Remove",
 			Handler:    _Msg_Remove_Handler,
 		},
+		{
+			MethodName: "Iterate",
+			Handler:    _Msg_Iterate_Handler,
+		},
+		{
+			MethodName: "ReverseIterate",
+			Handler:    _Msg_ReverseIterate_Handler,
+		},
+		{
+			MethodName: "DeletePrefix",
+			Handler:    _Msg_DeletePrefix_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "tx.proto",
@@ -480,6 +919,13 @@ func (m *MsgGet) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Key) > 0 {
 		i -= len(m.Key)
 		copy(dAtA[i:], m.Key)
@@ -533,6 +979,13 @@ func (m *MsgSet) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x1a
+	}
 	if len(m.Value) > 0 {
 		i -= len(m.Value)
 		copy(dAtA[i:], m.Value)
@@ -593,6 +1046,13 @@ func (m *MsgRemove) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.Key) > 0 {
 		i -= len(m.Key)
 		copy(dAtA[i:], m.Key)
@@ -626,11 +1086,209 @@ func (m *MsgRemoveResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintTx(dAtA []byte, offset int, v uint64) int {
-	offset -= sovTx(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
+func (m *MsgIterate) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgIterate) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgIterate) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Limit != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.End) > 0 {
+		i -= len(m.End)
+		copy(dAtA[i:], m.End)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.End)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Start) > 0 {
+		i -= len(m.Start)
+		copy(dAtA[i:], m.Start)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Start)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgReverseIterate) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgReverseIterate) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgReverseIterate) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if m.Limit != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x18
+	}
+	if len(m.End) > 0 {
+		i -= len(m.End)
+		copy(dAtA[i:], m.End)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.End)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Start) > 0 {
+		i -= len(m.Start)
+		copy(dAtA[i:], m.Start)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Start)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgIterateResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgIterateResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgIterateResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Hash) > 0 {
+		i -= len(m.Hash)
+		copy(dAtA[i:], m.Hash)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Hash)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if m.Count != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Count))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgDeletePrefix) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgDeletePrefix) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgDeletePrefix) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Store) > 0 {
+		i -= len(m.Store)
+		copy(dAtA[i:], m.Store)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Store)))
+		i--
+		dAtA[i] = 0x12
+	}
+	if len(m.Prefix) > 0 {
+		i -= len(m.Prefix)
+		copy(dAtA[i:], m.Prefix)
+		i = encodeVarintTx(dAtA, i, uint64(len(m.Prefix)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *MsgDeletePrefixResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *MsgDeletePrefixResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *MsgDeletePrefixResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.Count != 0 {
+		i = encodeVarintTx(dAtA, i, uint64(m.Count))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintTx(dAtA []byte, offset int, v uint64) int {
+	offset -= sovTx(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
 		v >>= 7
 		offset++
 	}
@@ -647,6 +1305,10 @@ func (m *MsgGet) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovTx(uint64(l))
 	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
 	return n
 }
 
@@ -673,6 +1335,10 @@ func (m *MsgSet) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovTx(uint64(l))
 	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
 	return n
 }
 
@@ -695,6 +1361,10 @@ func (m *MsgRemove) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovTx(uint64(l))
 	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
 	return n
 }
 
@@ -707,6 +1377,99 @@ func (m *MsgRemoveResponse) Size() (n int) {
 	return n
 }
 
+func (m *MsgIterate) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Start)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.End)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovTx(uint64(m.Limit))
+	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	return n
+}
+
+func (m *MsgReverseIterate) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Start)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.End)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	if m.Limit != 0 {
+		n += 1 + sovTx(uint64(m.Limit))
+	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	return n
+}
+
+func (m *MsgIterateResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Count != 0 {
+		n += 1 + sovTx(uint64(m.Count))
+	}
+	l = len(m.Hash)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	return n
+}
+
+func (m *MsgDeletePrefix) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Prefix)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	l = len(m.Store)
+	if l > 0 {
+		n += 1 + l + sovTx(uint64(l))
+	}
+	return n
+}
+
+func (m *MsgDeletePrefixResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.Count != 0 {
+		n += 1 + sovTx(uint64(m.Count))
+	}
+	return n
+}
+
 func sovTx(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -776,6 +1539,454 @@ func (m *MsgGet) Unmarshal(dAtA []byte) error {
 				m.Key = []byte{}
 			}
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgGetResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgGetResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgGetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgSet) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgSet: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgSet: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgSetResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgSetResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgSetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRemove) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRemove: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
+			if m.Key == nil {
+				m.Key = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipTx(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthTx
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MsgRemoveResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowTx
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MsgRemoveResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MsgRemoveResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])
@@ -797,7 +2008,7 @@ func (m *MsgGet) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgGetResponse) Unmarshal(dAtA []byte) error {
+func (m *MsgIterate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -820,12 +2031,131 @@ func (m *MsgGetResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgGetResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgIterate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgGetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgIterate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Start = append(m.Start[:0], dAtA[iNdEx:postIndex]...)
+			if m.Start == nil {
+				m.Start = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.End = append(m.End[:0], dAtA[iNdEx:postIndex]...)
+			if m.End == nil {
+				m.End = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])
@@ -847,7 +2177,7 @@ func (m *MsgGetResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgSet) Unmarshal(dAtA []byte) error {
+func (m *MsgReverseIterate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -870,15 +2200,15 @@ func (m *MsgSet) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgSet: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgReverseIterate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgSet: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgReverseIterate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Start", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -905,14 +2235,14 @@ func (m *MsgSet) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
-			if m.Key == nil {
-				m.Key = []byte{}
+			m.Start = append(m.Start[:0], dAtA[iNdEx:postIndex]...)
+			if m.Start == nil {
+				m.Start = []byte{}
 			}
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field End", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -939,10 +2269,61 @@ func (m *MsgSet) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
-			if m.Value == nil {
-				m.Value = []byte{}
+			m.End = append(m.End[:0], dAtA[iNdEx:postIndex]...)
+			if m.End == nil {
+				m.End = []byte{}
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
 			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -965,7 +2346,7 @@ func (m *MsgSet) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgSetResponse) Unmarshal(dAtA []byte) error {
+func (m *MsgIterateResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -988,12 +2369,65 @@ func (m *MsgSetResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgSetResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgIterateResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgSetResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgIterateResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hash", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthTx
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hash = append(m.Hash[:0], dAtA[iNdEx:postIndex]...)
+			if m.Hash == nil {
+				m.Hash = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])
@@ -1015,7 +2449,7 @@ func (m *MsgSetResponse) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgRemove) Unmarshal(dAtA []byte) error {
+func (m *MsgDeletePrefix) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1038,15 +2472,15 @@ func (m *MsgRemove) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgRemove: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgDeletePrefix: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgDeletePrefix: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Prefix", wireType)
 			}
 			var byteLen int
 			for shift := uint(0); ; shift += 7 {
@@ -1073,10 +2507,42 @@ func (m *MsgRemove) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Key = append(m.Key[:0], dAtA[iNdEx:postIndex]...)
-			if m.Key == nil {
-				m.Key = []byte{}
+			m.Prefix = append(m.Prefix[:0], dAtA[iNdEx:postIndex]...)
+			if m.Prefix == nil {
+				m.Prefix = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Store", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthTx
 			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthTx
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Store = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -1099,7 +2565,7 @@ func (m *MsgRemove) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MsgRemoveResponse) Unmarshal(dAtA []byte) error {
+func (m *MsgDeletePrefixResponse) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -1122,12 +2588,31 @@ func (m *MsgRemoveResponse) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MsgRemoveResponse: wiretype end group for non-group")
+			return fmt.Errorf("proto: MsgDeletePrefixResponse: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MsgRemoveResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: MsgDeletePrefixResponse: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Count", wireType)
+			}
+			m.Count = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTx
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Count |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTx(dAtA[iNdEx:])
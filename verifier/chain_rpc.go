@@ -0,0 +1,79 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	"github.com/tendermint/tendermint/types"
+)
+
+var _ Chain = &RPCChain{}
+
+// RPCChain subscribes to NewBlock events over the Tendermint websocket.
+type RPCChain struct {
+	client *rpchttp.HTTP
+}
+
+func NewRPCChain(addr string) (*RPCChain, error) {
+	c, err := rpchttp.New(addr, "/websocket")
+	if err != nil {
+		return nil, err
+	}
+	return &RPCChain{client: c}, nil
+}
+
+func (c *RPCChain) Subscribe(ctx context.Context) (<-chan *types.Block, error) {
+	if !c.client.IsRunning() {
+		if err := c.client.Start(); err != nil {
+			return nil, err
+		}
+	}
+	events, err := c.client.Subscribe(ctx, "gaiasl-follow", types.EventQueryNewBlock.String(), 100)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(chan *types.Block)
+	go func() {
+		defer close(blocks)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, ok := event.Data.(types.EventDataNewBlock)
+				if !ok {
+					continue
+				}
+				select {
+				case blocks <- data.Block:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return blocks, nil
+}
+
+func (c *RPCChain) Block(ctx context.Context, height int64) (*types.Block, error) {
+	res, err := c.client.Block(ctx, &height)
+	if err != nil {
+		return nil, err
+	}
+	if res.Block == nil {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	return res.Block, nil
+}
+
+func (c *RPCChain) Stop() error {
+	if !c.client.IsRunning() {
+		return nil
+	}
+	return c.client.Stop()
+}
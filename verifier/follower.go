@@ -0,0 +1,288 @@
+package verifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+)
+
+// Chain is the chain a Follower verifies.
+type Chain interface {
+	// Subscribe returns the blocks as they are committed.
+	Subscribe(ctx context.Context) (<-chan *types.Block, error)
+	Block(ctx context.Context, height int64) (*types.Block, error)
+}
+
+// ExecuteFunc statelessly executes the block of height, whose hash is
+// trusted, and returns the resulting app hash.
+type ExecuteFunc func(height int64, blockHash []byte) ([]byte, error)
+
+// Result is the verification result of a block.
+type Result struct {
+	Height int64
+	// Expected is the app hash in the header of the next block.
+	Expected []byte
+	Executed []byte
+	Duration time.Duration
+	Err      error
+	// Attempt counts the executions of the height by a Follower, and Skipped
+	// is set on the result of the last failed one if the height is skipped.
+	Attempt int
+	Skipped bool
+}
+
+func (r Result) Mismatch() bool {
+	return r.Err == nil && !bytes.Equal(r.Expected, r.Executed)
+}
+
+// Follower statelessly executes every new block of a chain and compares the
+// resulting app hash with the one in the header of the next block. The block
+// hashes are taken from the chain and linked through LastBlockID, so the
+// chain is trusted the same way -hash is trusted for a single execution.
+type Follower struct {
+	chain        Chain
+	execute      ExecuteFunc
+	checkpoint   string
+	onResult     func(Result)
+	onCheckpoint func(height int64)
+	retry        RetryPolicy
+
+	// headers are the fetched headers from the one above the next height to
+	// verify, kept until the block below them is verified
+	headers []*types.Block
+	// attempts counts the failed executions of the next height
+	attempts int
+	// skipped are the heights that were given up
+	skipped []int64
+}
+
+// RetryPolicy decides what a Follower does with a height that fails to
+// execute.
+type RetryPolicy struct {
+	// MaxAttempts is the number of executions after which the height is
+	// given up. Zero retries forever.
+	MaxAttempts int
+	// Backoff is the delay before the second execution, doubled for every
+	// further one up to MaxBackoff. With zero the height is executed again
+	// when the next block arrives.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Skip makes a given up height skipped and recorded in the checkpoint.
+	// Otherwise Run returns an error.
+	Skip bool
+}
+
+// delay returns the backoff after the given number of failed attempts.
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts && delay > 0 && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// NewFollower returns a follower that records the last verified height in
+// the file checkpoint, if not empty, and resumes from it.
+func NewFollower(chain Chain, execute ExecuteFunc, checkpoint string) *Follower {
+	return &Follower{
+		chain:        chain,
+		execute:      execute,
+		checkpoint:   checkpoint,
+		onResult:     func(Result) {},
+		onCheckpoint: func(int64) {},
+	}
+}
+
+// SetResultHandler sets the function called with the result of every block.
+func (f *Follower) SetResultHandler(fn func(Result)) {
+	f.onResult = fn
+}
+
+// SetRetryPolicy sets the policy for heights that fail to execute. By
+// default a failed height is executed again with every new block.
+func (f *Follower) SetRetryPolicy(p RetryPolicy) {
+	f.retry = p
+}
+
+// SetCheckpointHandler sets the function called after the checkpoint moved
+// to height, e.g. to remove data of the heights below.
+func (f *Follower) SetCheckpointHandler(fn func(height int64)) {
+	f.onCheckpoint = fn
+}
+
+// Run verifies new blocks until ctx is done or the subscription is closed.
+func (f *Follower) Run(ctx context.Context) error {
+	next, err := f.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	blocks, err := f.chain.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+	// retry is set while a failed height waits for its backoff
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-retry:
+			retry = nil
+		case block, ok := <-blocks:
+			if !ok {
+				return fmt.Errorf("block subscription closed")
+			}
+			if next == 0 {
+				next = block.Height - 1
+			}
+			if err = f.addHeaders(ctx, next, block); err != nil {
+				return err
+			}
+			if retry != nil {
+				continue
+			}
+		}
+		var delay time.Duration
+		if next, delay, err = f.verifyHeaders(next); err != nil {
+			return err
+		}
+		if delay > 0 {
+			retry = time.After(delay)
+		}
+	}
+}
+
+// addHeaders adds the headers above the known ones up to latest, checking
+// that they are linked. Without known headers, they start at the one of
+// next+1, which verifies the block of next.
+func (f *Follower) addHeaders(ctx context.Context, next int64, latest *types.Block) error {
+	from := next + 1
+	if n := len(f.headers); n > 0 {
+		from = f.headers[n-1].Height + 1
+	}
+	if latest.Height < from {
+		return nil
+	}
+
+	headers := []*types.Block{latest}
+	for h := latest.Height - 1; h >= from; h-- {
+		block, err := f.chain.Block(ctx, h)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(block.Hash(), headers[len(headers)-1].LastBlockID.Hash) {
+			return fmt.Errorf("block %d with hash %X is not the parent of block %d", h, block.Hash(), h+1)
+		}
+		headers = append(headers, block)
+	}
+	if n := len(f.headers); n > 0 {
+		if top, lowest := f.headers[n-1], headers[len(headers)-1]; !bytes.Equal(top.Hash(), lowest.LastBlockID.Hash) {
+			return fmt.Errorf("block %d with hash %X is not the parent of block %d", top.Height, top.Hash(), lowest.Height)
+		}
+	}
+	for i := len(headers) - 1; i >= 0; i-- {
+		f.headers = append(f.headers, headers[i])
+	}
+	return nil
+}
+
+// verifyHeaders verifies the blocks below the known headers in order and
+// returns the next height to verify. It stops at a block that fails to
+// execute and returns the backoff before it is executed again.
+func (f *Follower) verifyHeaders(next int64) (int64, time.Duration, error) {
+	for len(f.headers) > 0 {
+		header := f.headers[0]
+		result := Result{
+			Height:   header.Height - 1,
+			Expected: header.AppHash,
+			Attempt:  f.attempts + 1,
+		}
+		start := time.Now()
+		result.Executed, result.Err = f.execute(result.Height, header.LastBlockID.Hash)
+		result.Duration = time.Since(start)
+		if result.Mismatch() {
+			metrics.AppHashMismatches.Inc()
+		}
+		if result.Err != nil {
+			f.attempts++
+			if f.retry.MaxAttempts <= 0 || f.attempts < f.retry.MaxAttempts {
+				f.onResult(result)
+				return next, f.retry.delay(f.attempts), nil
+			}
+			if !f.retry.Skip {
+				f.onResult(result)
+				return next, 0, fmt.Errorf("giving up height %d after %d attempts: %w", result.Height, f.attempts, result.Err)
+			}
+			result.Skipped = true
+			f.skipped = append(f.skipped, result.Height)
+		}
+		f.onResult(result)
+
+		f.attempts = 0
+		f.headers = f.headers[1:]
+		if err := f.saveCheckpoint(result.Height); err != nil {
+			return next, 0, err
+		}
+		f.onCheckpoint(result.Height)
+		next = header.Height
+	}
+	return next, 0, nil
+}
+
+type checkpoint struct {
+	Height int64 `json:"height"`
+	// Skipped are the heights below Height that were given up.
+	Skipped []int64 `json:"skipped,omitempty"`
+}
+
+// loadCheckpoint returns the height following the last verified one, or zero
+// if there is no checkpoint.
+func (f *Follower) loadCheckpoint() (int64, error) {
+	if f.checkpoint == "" {
+		return 0, nil
+	}
+	bz, err := os.ReadFile(f.checkpoint)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	cp := checkpoint{}
+	if err = json.Unmarshal(bz, &cp); err != nil {
+		return 0, fmt.Errorf("invalid checkpoint %s: %w", f.checkpoint, err)
+	}
+	f.skipped = cp.Skipped
+	return cp.Height + 1, nil
+}
+
+func (f *Follower) saveCheckpoint(height int64) error {
+	if f.checkpoint == "" {
+		return nil
+	}
+	bz, err := json.Marshal(checkpoint{Height: height, Skipped: f.skipped})
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(f.checkpoint), 0o755); err != nil {
+		return err
+	}
+	// write and rename so that a crash never leaves a partial checkpoint
+	tmp := f.checkpoint + ".tmp"
+	if err = os.WriteFile(tmp, bz, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.checkpoint)
+}
@@ -0,0 +1,223 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/types"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+)
+
+type fakeChain struct {
+	blocks map[int64]*types.Block
+	events chan *types.Block
+	// failing counts the failing executions left of heights
+	failing map[int64]int
+	// fetched counts the fetches of the blocks of heights
+	fetched map[int64]int
+}
+
+func newFakeChain(n int64) *fakeChain {
+	c := &fakeChain{blocks: map[int64]*types.Block{}, events: make(chan *types.Block, n), failing: map[int64]int{}, fetched: map[int64]int{}}
+	var prev *types.Block
+	for h := int64(1); h <= n; h++ {
+		block := &types.Block{Header: types.Header{
+			Height:         h,
+			ValidatorsHash: []byte("vals"),
+			AppHash:        appHash(h - 1),
+		}}
+		if prev != nil {
+			block.LastBlockID = types.BlockID{Hash: prev.Hash()}
+		}
+		c.blocks[h] = block
+		prev = block
+	}
+	return c
+}
+
+func appHash(height int64) []byte {
+	return []byte(fmt.Sprintf("app%d", height))
+}
+
+func (c *fakeChain) Subscribe(ctx context.Context) (<-chan *types.Block, error) {
+	return c.events, nil
+}
+
+func (c *fakeChain) Block(ctx context.Context, height int64) (*types.Block, error) {
+	c.fetched[height]++
+	return c.blocks[height], nil
+}
+
+func (c *fakeChain) execute(height int64, blockHash []byte) ([]byte, error) {
+	if string(blockHash) != string(c.blocks[height].Hash()) {
+		return nil, fmt.Errorf("untrusted hash")
+	}
+	if c.failing[height] > 0 {
+		c.failing[height]--
+		return nil, fmt.Errorf("execution of height %d failed", height)
+	}
+	if height == 7 {
+		return []byte("wrong"), nil
+	}
+	return appHash(height), nil
+}
+
+func TestFollower(t *testing.T) {
+	chain := newFakeChain(20)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	results := []Result{}
+	checkpoints := []int64{}
+	mismatches := testutil.ToFloat64(metrics.AppHashMismatches)
+
+	run := func(events ...int64) {
+		for _, h := range events {
+			chain.events <- chain.blocks[h]
+		}
+		follower := NewFollower(chain, chain.execute, checkpoint)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		follower.SetResultHandler(func(r Result) {
+			results = append(results, r)
+			if r.Height == events[len(events)-1]-1 {
+				cancel()
+			}
+		})
+		follower.SetCheckpointHandler(func(height int64) {
+			checkpoints = append(checkpoints, height)
+		})
+		require.ErrorIs(t, follower.Run(ctx), context.Canceled)
+	}
+
+	// starts at the block before the first event and fills the gap to 9
+	run(5, 6, 9)
+	// resumes from the checkpoint after a restart
+	run(12)
+
+	heights := []int64{}
+	for _, r := range results {
+		require.NoError(t, r.Err)
+		require.Equal(t, r.Height == 7, r.Mismatch(), r.Height)
+		heights = append(heights, r.Height)
+	}
+	require.Equal(t, []int64{4, 5, 6, 7, 8, 9, 10, 11}, heights)
+	require.Equal(t, heights, checkpoints)
+	require.Equal(t, mismatches+1, testutil.ToFloat64(metrics.AppHashMismatches))
+}
+
+func TestFollowerRetriesFailedHeight(t *testing.T) {
+	chain := newFakeChain(20)
+	chain.failing[6] = 1
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	results := []Result{}
+
+	run := func(stop func(Result) bool, events ...int64) {
+		for _, h := range events {
+			chain.events <- chain.blocks[h]
+		}
+		follower := NewFollower(chain, chain.execute, checkpoint)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		follower.SetResultHandler(func(r Result) {
+			results = append(results, r)
+			if stop(r) {
+				cancel()
+			}
+		})
+		require.ErrorIs(t, follower.Run(ctx), context.Canceled)
+	}
+
+	// the checkpoint stays before the failed height
+	run(func(r Result) bool { return r.Err != nil }, 5, 8)
+	next, err := NewFollower(chain, chain.execute, checkpoint).loadCheckpoint()
+	require.NoError(t, err)
+	require.Equal(t, int64(6), next)
+
+	// the failed height is verified again after a restart
+	run(func(r Result) bool { return r.Height == 8 }, 9)
+
+	heights := []int64{}
+	for _, r := range results {
+		require.Equal(t, len(heights) == 2, r.Err != nil, r.Height)
+		heights = append(heights, r.Height)
+	}
+	require.Equal(t, []int64{4, 5, 6, 6, 7, 8}, heights)
+}
+
+// runFollower runs follower on the blocks of events until the result handler
+// stops it and returns the error of Run.
+func runFollower(chain *fakeChain, follower *Follower, stop func(Result) bool, events ...int64) error {
+	for _, h := range events {
+		chain.events <- chain.blocks[h]
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	follower.SetResultHandler(func(r Result) {
+		if stop(r) {
+			cancel()
+		}
+	})
+	return follower.Run(ctx)
+}
+
+func TestFollowerRetryPolicy(t *testing.T) {
+	// a height is given up after the maximum number of attempts, each of
+	// which comes with a new block
+	chain := newFakeChain(20)
+	chain.failing[6] = 100
+	follower := NewFollower(chain, chain.execute, "")
+	follower.SetRetryPolicy(RetryPolicy{MaxAttempts: 3})
+	attempts := []int{}
+	err := runFollower(chain, follower, func(r Result) bool {
+		if r.Height == 6 {
+			attempts = append(attempts, r.Attempt)
+		}
+		return false
+	}, 5, 8, 9, 10)
+	require.ErrorContains(t, err, "giving up height 6 after 3 attempts")
+	require.Equal(t, []int{1, 2, 3}, attempts)
+	// the gap below block 8 is fetched once although height 6 was executed
+	// three times
+	require.Equal(t, map[int64]int{6: 1, 7: 1}, chain.fetched)
+
+	// a skipped height is recorded in the checkpoint and the next heights are
+	// verified; with a backoff, the height is executed again without a new
+	// block
+	chain = newFakeChain(20)
+	chain.failing[6] = 100
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint.json")
+	follower = NewFollower(chain, chain.execute, checkpoint)
+	follower.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond, Skip: true})
+	results := []Result{}
+	err = runFollower(chain, follower, func(r Result) bool {
+		results = append(results, r)
+		return r.Height == 7
+	}, 5, 8)
+	require.ErrorIs(t, err, context.Canceled)
+	heights := []int64{}
+	for _, r := range results {
+		require.Equal(t, r.Height == 6 && r.Attempt == 2, r.Skipped, r.Height)
+		heights = append(heights, r.Height)
+	}
+	require.Equal(t, []int64{4, 5, 6, 6, 7}, heights)
+	bz, err := os.ReadFile(checkpoint)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"height":7,"skipped":[6]}`, string(bz))
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	delays := []time.Duration{}
+	for attempts := 1; attempts <= 4; attempts++ {
+		delays = append(delays, p.delay(attempts))
+	}
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}, delays)
+	require.Zero(t, RetryPolicy{}.delay(3))
+	require.Positive(t, RetryPolicy{Backoff: time.Second}.delay(1000))
+}
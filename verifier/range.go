@@ -0,0 +1,121 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+)
+
+// HeightFunc statelessly executes the block of height and returns the
+// resulting app hash and the app hash in the header of the next block.
+type HeightFunc func(height int64) (executed, expected []byte, err error)
+
+// RangeStats summarizes a RunRange call.
+type RangeStats struct {
+	Blocks     int
+	Mismatches int
+	Failures   int
+	Elapsed    time.Duration
+}
+
+func (s RangeStats) BlocksPerSecond() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Blocks) / s.Elapsed.Seconds()
+}
+
+// RunRange verifies heights with the given number of workers. Every worker
+// gets its own HeightFunc from newWorker, so that executions never share an
+// app. onResult, if not nil, is called with the results in the order of
+// heights as soon as they are available. Heights not started before ctx is
+// done get ctx.Err() as their error.
+func RunRange(ctx context.Context, heights []int64, workers int, newWorker func() (HeightFunc, error), onResult func(Result)) ([]Result, RangeStats, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(heights) {
+		workers = len(heights)
+	}
+	fns := make([]HeightFunc, workers)
+	for i := range fns {
+		fn, err := newWorker()
+		if err != nil {
+			return nil, RangeStats{}, err
+		}
+		fns[i] = fn
+	}
+
+	start := time.Now()
+	results := make([]Result, len(heights))
+	done := make([]bool, len(heights))
+	next := 0
+	mtx := sync.Mutex{}
+	finish := func(i int, r Result) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		results[i] = r
+		done[i] = true
+		for ; next < len(heights) && done[next]; next++ {
+			if onResult != nil {
+				onResult(results[next])
+			}
+		}
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(fn HeightFunc) {
+			defer wg.Done()
+			for i := range jobs {
+				r := runHeight(ctx, fn, heights[i])
+				if r.Mismatch() {
+					metrics.AppHashMismatches.Inc()
+				}
+				finish(i, r)
+			}
+		}(fn)
+	}
+	for i := range heights {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	stats := RangeStats{
+		Blocks:  len(heights),
+		Elapsed: time.Since(start),
+	}
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			stats.Failures++
+		case r.Mismatch():
+			stats.Mismatches++
+		}
+	}
+	return results, stats, nil
+}
+
+// runHeight calls fn for height, turning panics of the execution into errors.
+func runHeight(ctx context.Context, fn HeightFunc, height int64) (r Result) {
+	r.Height = height
+	if err := ctx.Err(); err != nil {
+		r.Err = err
+		return r
+	}
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			r.Err = fmt.Errorf("panic: %v", p)
+		}
+		r.Duration = time.Since(start)
+	}()
+	r.Executed, r.Expected, r.Err = fn(height)
+	return r
+}
@@ -0,0 +1,53 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ulbqb/cosmos-stateless-poc/metrics"
+)
+
+func TestRunRange(t *testing.T) {
+	heights := []int64{}
+	for h := int64(100); h < 140; h++ {
+		heights = append(heights, h)
+	}
+
+	workers := int32(0)
+	newWorker := func() (HeightFunc, error) {
+		atomic.AddInt32(&workers, 1)
+		return func(height int64) ([]byte, []byte, error) {
+			time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+			switch height {
+			case 110:
+				return []byte("wrong"), appHash(height), nil
+			case 120:
+				return nil, nil, fmt.Errorf("failed")
+			case 130:
+				panic("oracle failure")
+			}
+			return appHash(height), appHash(height), nil
+		}, nil
+	}
+
+	mismatches := testutil.ToFloat64(metrics.AppHashMismatches)
+	ordered := []int64{}
+	results, stats, err := RunRange(context.Background(), heights, 8, newWorker, func(r Result) {
+		ordered = append(ordered, r.Height)
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(8), workers)
+	require.Equal(t, heights, ordered)
+	require.Len(t, results, len(heights))
+	require.Equal(t, RangeStats{Blocks: 40, Mismatches: 1, Failures: 2, Elapsed: stats.Elapsed}, stats)
+	require.True(t, results[10].Mismatch())
+	require.Error(t, results[30].Err)
+	require.Equal(t, mismatches+1, testutil.ToFloat64(metrics.AppHashMismatches))
+}
@@ -0,0 +1,592 @@
+package witness
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	ics23 "github.com/confio/ics23/go"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/klauspost/compress/zstd"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	"github.com/tendermint/tendermint/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// BundleVersion is the version of the bundle format written by EncodeBundle.
+const BundleVersion = 1
+
+// maxBundleSize bounds the size of a decompressed bundle.
+const maxBundleSize = 1 << 30
+
+// An encoded bundle is the magic, the version, the compression and the
+// Bundle protobuf.
+var bundleMagic = []byte("slbundle")
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionZstd
+)
+
+// BlockSource provides the block of a bundle, e.g. an oracle client.
+type BlockSource interface {
+	Block() *ctypes.ResultBlock
+	// LastBlock returns the block below, whose header is kept in the bundle.
+	LastBlock() *ctypes.ResultBlock
+	Validators() *ctypes.ResultValidators
+	ConsensusParams() *ctypes.ResultConsensusParams
+}
+
+// NewBundle returns a bundle of the block of src, the ABCI queries recorded
+// in r and the app hash the execution resulted in.
+func NewBundle(src BlockSource, r *Recording, appHash []byte) (*Bundle, error) {
+	block := src.Block()
+	if block.Block == nil {
+		return nil, fmt.Errorf("no block")
+	}
+	blockProto, err := block.Block.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		Height:          block.Block.Height,
+		BlockId:         block.BlockID.ToProto(),
+		Block:           blockProto,
+		ConsensusParams: src.ConsensusParams().ConsensusParams,
+		AppHash:         appHash,
+	}
+	for _, val := range src.Validators().Validators {
+		valProto, err := val.ToProto()
+		if err != nil {
+			return nil, err
+		}
+		b.Validators = append(b.Validators, valProto)
+	}
+	if block.Block.LastCommit != nil && block.Block.LastCommit.Size() > 0 {
+		last := src.LastBlock()
+		if last.Block == nil {
+			return nil, fmt.Errorf("no block at height %d", b.Height-1)
+		}
+		b.LastHeader = last.Block.Header.ToProto()
+	}
+
+	builder := newBundleBuilder(b)
+	added := map[string]bool{}
+	for _, a := range r.Accesses {
+		req, err := oracletypes.DecodeRequest([]byte(a.Request))
+		if err != nil {
+			return nil, err
+		}
+		responses := [][]byte{a.Response}
+		switch req.Kind {
+		case oracletypes.KindABCIQuery:
+		case oracletypes.KindABCIQueryBatch:
+			if responses, err = oracletypes.DecodeBatchResponse(a.Response); err != nil {
+				return nil, err
+			}
+			if len(responses) != len(req.Queries) {
+				return nil, fmt.Errorf("%d responses for %d queries", len(responses), len(req.Queries))
+			}
+		default:
+			// the block is taken from src
+			continue
+		}
+		for i, q := range req.Queries {
+			res := ctypes.ResultABCIQuery{}
+			if err = oracletypes.DecodeResponse(responses[i], &res); err != nil {
+				return nil, err
+			}
+			key := QueryKey(q.Path, q.Data)
+			if added[key] {
+				continue
+			}
+			added[key] = true
+			query, err := builder.newQuery(q, res.Response)
+			if err != nil {
+				return nil, fmt.Errorf("query %s %X: %w", q.Path, q.Data, err)
+			}
+			b.Queries = append(b.Queries, query)
+		}
+	}
+	return b, nil
+}
+
+// bundleBuilder adds the proofs of queries to the tables of a bundle,
+// storing each proof node and shared op once.
+type bundleBuilder struct {
+	tables *Bundle
+	leaves map[string]uint32
+	inners map[string]uint32
+	ops    map[string]uint32
+}
+
+func newBundleBuilder(tables *Bundle) *bundleBuilder {
+	return &bundleBuilder{
+		tables: tables,
+		leaves: map[string]uint32{},
+		inners: map[string]uint32{},
+		ops:    map[string]uint32{},
+	}
+}
+
+// newQuery returns the query of q and res with its proof referencing the
+// tables.
+func (bb *bundleBuilder) newQuery(q oracletypes.Query, res abci.ResponseQuery) (Query, error) {
+	query := Query{
+		Path:       q.Path,
+		Data:       q.Data,
+		Code:       res.Code,
+		Log:        res.Log,
+		Info:       res.Info,
+		Codespace:  res.Codespace,
+		Key:        res.Key,
+		Value:      res.Value,
+		EmptyValue: res.Value != nil && len(res.Value) == 0,
+		Height:     res.Height,
+	}
+	if res.ProofOps != nil {
+		for _, op := range res.ProofOps.Ops {
+			proofOp, err := bb.addOp(op)
+			if err != nil {
+				return Query{}, err
+			}
+			query.Proof = append(query.Proof, proofOp)
+		}
+	}
+	if leaf := bb.tables.proofLeaf(query); leaf != nil && res.Value != nil &&
+		bytes.Equal(leaf.Key, res.Key) && bytes.Equal(leaf.Value, res.Value) {
+		query.Key, query.Value, query.EmptyValue = nil, nil, false
+		query.LeafValue = true
+	}
+	return query, nil
+}
+
+func (bb *bundleBuilder) addOp(op tmcrypto.ProofOp) (ProofOp, error) {
+	if op.Type != storetypes.ProofOpIAVLCommitment {
+		bz, err := op.Marshal()
+		if err != nil {
+			return ProofOp{}, err
+		}
+		i, ok := bb.ops[string(bz)]
+		if !ok {
+			i = uint32(len(bb.tables.Ops))
+			bb.ops[string(bz)] = i
+			bb.tables.Ops = append(bb.tables.Ops, op)
+		}
+		return ProofOp{Op: &ProofOp_Shared{Shared: i}}, nil
+	}
+
+	proof := &ics23.CommitmentProof{}
+	if err := proof.Unmarshal(op.Data); err != nil {
+		return ProofOp{}, err
+	}
+	commitment := &IAVLCommitment{Key: op.Key}
+	switch {
+	case proof.GetExist() != nil:
+		exist, err := bb.addExistenceProof(proof.GetExist())
+		if err != nil {
+			return ProofOp{}, err
+		}
+		commitment.Proof = &IAVLCommitment_Exist{Exist: exist}
+	case proof.GetNonexist() != nil:
+		nonexist := &NonExistenceProof{Key: proof.GetNonexist().Key}
+		var err error
+		if nonexist.Left, err = bb.addExistenceProof(proof.GetNonexist().Left); err != nil {
+			return ProofOp{}, err
+		}
+		if nonexist.Right, err = bb.addExistenceProof(proof.GetNonexist().Right); err != nil {
+			return ProofOp{}, err
+		}
+		commitment.Proof = &IAVLCommitment_Nonexist{Nonexist: nonexist}
+	default:
+		return ProofOp{}, fmt.Errorf("unsupported IAVL commitment proof")
+	}
+	if bytes.Equal(op.Key, proofKey(proof)) {
+		commitment.Key = nil
+	}
+	return ProofOp{Op: &ProofOp_Iavl{Iavl: commitment}}, nil
+}
+
+// proofKey returns the key proven by p, which is also the key of its op.
+func proofKey(p *ics23.CommitmentProof) []byte {
+	if exist := p.GetExist(); exist != nil {
+		return exist.Key
+	}
+	return p.GetNonexist().GetKey()
+}
+
+// addExistenceProof adds the nodes of p by node hash.
+func (bb *bundleBuilder) addExistenceProof(p *ics23.ExistenceProof) (*ExistenceProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	spec := ics23.IavlSpec.LeafSpec
+	if p.Leaf == nil || p.Leaf.Hash != spec.Hash || p.Leaf.PrehashKey != spec.PrehashKey ||
+		p.Leaf.PrehashValue != spec.PrehashValue || p.Leaf.Length != spec.Length {
+		return nil, fmt.Errorf("unsupported leaf op %v", p.Leaf)
+	}
+	hash, err := p.Leaf.Apply(p.Key, p.Value)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := bb.leaves[string(hash)]
+	if !ok {
+		i = uint32(len(bb.tables.Leaves))
+		bb.leaves[string(hash)] = i
+		bb.tables.Leaves = append(bb.tables.Leaves, Leaf{Key: p.Key, Value: p.Value, Prefix: p.Leaf.Prefix})
+	}
+	res := &ExistenceProof{Leaf: i}
+
+	for _, op := range p.Path {
+		node, err := innerNode(op, hash)
+		if err != nil {
+			return nil, err
+		}
+		if hash, err = op.Apply(hash); err != nil {
+			return nil, err
+		}
+		i, ok := bb.inners[string(hash)]
+		if !ok {
+			i = uint32(len(bb.tables.InnerNodes))
+			bb.inners[string(hash)] = i
+			bb.tables.InnerNodes = append(bb.tables.InnerNodes, node)
+		}
+		res.Path = append(res.Path, i)
+	}
+	return res, nil
+}
+
+// innerNode returns the node of the inner op of an IAVL proof over child.
+// The hashes of the children are written with their length, which is a
+// single byte for SHA256 hashes:
+//
+//	child on the left:  prefix = header | 32,  suffix = 32 | right
+//	child on the right: prefix = header | 32 | left | 32,  suffix = ""
+func innerNode(op *ics23.InnerOp, child []byte) (InnerNode, error) {
+	const hashLen = 32
+	if op.Hash != ics23.IavlSpec.InnerSpec.Hash || len(child) != hashLen {
+		return InnerNode{}, fmt.Errorf("unsupported inner op %v", op)
+	}
+	prefix, suffix := op.Prefix, op.Suffix
+	switch {
+	case len(suffix) == hashLen+1 && suffix[0] == hashLen && len(prefix) > 1 && prefix[len(prefix)-1] == hashLen:
+		return InnerNode{Header: prefix[:len(prefix)-1], Left: child, Right: suffix[1:]}, nil
+	case len(suffix) == 0 && len(prefix) > hashLen+2 && prefix[len(prefix)-1] == hashLen && prefix[len(prefix)-hashLen-2] == hashLen:
+		return InnerNode{Header: prefix[:len(prefix)-hashLen-2], Left: prefix[len(prefix)-hashLen-1 : len(prefix)-1], Right: child}, nil
+	default:
+		return InnerNode{}, fmt.Errorf("unsupported inner op %v", op)
+	}
+}
+
+// innerOp returns the inner op of node over child.
+func innerOp(node InnerNode, child []byte) (*ics23.InnerOp, error) {
+	op := &ics23.InnerOp{Hash: ics23.IavlSpec.InnerSpec.Hash}
+	lenByte := byte(len(child))
+	switch {
+	case bytes.Equal(child, node.Left):
+		op.Prefix = append(append([]byte{}, node.Header...), lenByte)
+		op.Suffix = append([]byte{byte(len(node.Right))}, node.Right...)
+	case bytes.Equal(child, node.Right):
+		op.Prefix = append(append(append(append([]byte{}, node.Header...), byte(len(node.Left))), node.Left...), lenByte)
+	default:
+		return nil, fmt.Errorf("node is no parent of %X", child)
+	}
+	return op, nil
+}
+
+func iavlLeafOp(prefix []byte) *ics23.LeafOp {
+	spec := ics23.IavlSpec.LeafSpec
+	return &ics23.LeafOp{
+		Hash:         spec.Hash,
+		PrehashKey:   spec.PrehashKey,
+		PrehashValue: spec.PrehashValue,
+		Length:       spec.Length,
+		Prefix:       prefix,
+	}
+}
+
+// QueryKey identifies the query of path and data in a bundle.
+func QueryKey(path string, data []byte) string {
+	return path + "?" + hex.EncodeToString(data)
+}
+
+func (b *Bundle) ResultBlock() (*ctypes.ResultBlock, error) {
+	block, err := types.BlockFromProto(b.Block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	blockID, err := types.BlockIDFromProto(&b.BlockId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return &ctypes.ResultBlock{BlockID: *blockID, Block: block}, nil
+}
+
+// ResultLastBlock returns the block below the one of b with its header only,
+// or an error if b has none.
+func (b *Bundle) ResultLastBlock() (*ctypes.ResultBlock, error) {
+	if b.LastHeader == nil || b.Block == nil {
+		return nil, fmt.Errorf("bundle has no header of height %d", b.Height-1)
+	}
+	header, err := types.HeaderFromProto(b.LastHeader)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	blockID, err := types.BlockIDFromProto(&b.Block.Header.LastBlockId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	return &ctypes.ResultBlock{BlockID: *blockID, Block: &types.Block{Header: header}}, nil
+}
+
+func (b *Bundle) ResultValidators() (*ctypes.ResultValidators, error) {
+	vals := []*types.Validator{}
+	for _, valProto := range b.Validators {
+		val, err := types.ValidatorFromProto(valProto)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		vals = append(vals, val)
+	}
+	// the validators signed the last commit of the block
+	return &ctypes.ResultValidators{
+		BlockHeight: b.Height - 1,
+		Validators:  vals,
+		Count:       len(vals),
+		Total:       len(vals),
+	}, nil
+}
+
+func (b *Bundle) ResultConsensusParams() *ctypes.ResultConsensusParams {
+	return &ctypes.ResultConsensusParams{
+		BlockHeight:     b.Height,
+		ConsensusParams: b.ConsensusParams,
+	}
+}
+
+// QueryResponse returns the response to the query i with its proof rebuilt
+// from the nodes of the bundle.
+func (b *Bundle) QueryResponse(i int) (abci.ResponseQuery, error) {
+	q := b.Queries[i]
+	res := abci.ResponseQuery{
+		Code:      q.Code,
+		Log:       q.Log,
+		Info:      q.Info,
+		Codespace: q.Codespace,
+		Key:       q.Key,
+		Value:     q.Value,
+		Height:    q.Height,
+	}
+	if q.EmptyValue {
+		res.Value = []byte{}
+	}
+	if q.LeafValue {
+		leaf := b.proofLeaf(q)
+		if leaf == nil {
+			return abci.ResponseQuery{}, fmt.Errorf("%w: query %s %X has no leaf", ErrInvalidBundle, q.Path, q.Data)
+		}
+		res.Key, res.Value = leaf.Key, append([]byte{}, leaf.Value...)
+	}
+	if len(q.Proof) == 0 {
+		return res, nil
+	}
+	res.ProofOps = &tmcrypto.ProofOps{}
+	for _, proofOp := range q.Proof {
+		op, err := b.proofOp(proofOp)
+		if err != nil {
+			return abci.ResponseQuery{}, fmt.Errorf("%w: query %s %X: %v", ErrInvalidBundle, q.Path, q.Data, err)
+		}
+		res.ProofOps.Ops = append(res.ProofOps.Ops, op)
+	}
+	return res, nil
+}
+
+// proofLeaf returns the leaf of the existence proof of the first proof op of
+// q, if there is one.
+func (b *Bundle) proofLeaf(q Query) *Leaf {
+	if len(q.Proof) == 0 {
+		return nil
+	}
+	op, ok := q.Proof[0].Op.(*ProofOp_Iavl)
+	if !ok {
+		return nil
+	}
+	exist := op.Iavl.GetExist()
+	if exist == nil || int(exist.Leaf) >= len(b.Leaves) {
+		return nil
+	}
+	return &b.Leaves[exist.Leaf]
+}
+
+func (b *Bundle) proofOp(op ProofOp) (tmcrypto.ProofOp, error) {
+	switch op := op.Op.(type) {
+	case *ProofOp_Shared:
+		if int(op.Shared) >= len(b.Ops) {
+			return tmcrypto.ProofOp{}, fmt.Errorf("no op %d", op.Shared)
+		}
+		return b.Ops[op.Shared], nil
+	case *ProofOp_Iavl:
+		proof := &ics23.CommitmentProof{}
+		switch p := op.Iavl.Proof.(type) {
+		case *IAVLCommitment_Exist:
+			exist, err := b.existenceProof(p.Exist)
+			if err != nil {
+				return tmcrypto.ProofOp{}, err
+			}
+			proof.Proof = &ics23.CommitmentProof_Exist{Exist: exist}
+		case *IAVLCommitment_Nonexist:
+			nonexist := &ics23.NonExistenceProof{Key: p.Nonexist.Key}
+			var err error
+			if nonexist.Left, err = b.existenceProof(p.Nonexist.Left); err != nil {
+				return tmcrypto.ProofOp{}, err
+			}
+			if nonexist.Right, err = b.existenceProof(p.Nonexist.Right); err != nil {
+				return tmcrypto.ProofOp{}, err
+			}
+			proof.Proof = &ics23.CommitmentProof_Nonexist{Nonexist: nonexist}
+		default:
+			return tmcrypto.ProofOp{}, fmt.Errorf("empty IAVL commitment")
+		}
+		bz, err := proof.Marshal()
+		if err != nil {
+			return tmcrypto.ProofOp{}, err
+		}
+		key := op.Iavl.Key
+		if len(key) == 0 {
+			key = proofKey(proof)
+		}
+		return tmcrypto.ProofOp{Type: storetypes.ProofOpIAVLCommitment, Key: key, Data: bz}, nil
+	default:
+		return tmcrypto.ProofOp{}, fmt.Errorf("empty proof op")
+	}
+}
+
+func (b *Bundle) existenceProof(p *ExistenceProof) (*ics23.ExistenceProof, error) {
+	if p == nil {
+		return nil, nil
+	}
+	if int(p.Leaf) >= len(b.Leaves) {
+		return nil, fmt.Errorf("no leaf %d", p.Leaf)
+	}
+	leaf := b.Leaves[p.Leaf]
+	res := &ics23.ExistenceProof{Key: leaf.Key, Value: leaf.Value, Leaf: iavlLeafOp(leaf.Prefix)}
+	hash, err := res.Leaf.Apply(leaf.Key, leaf.Value)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range p.Path {
+		if int(i) >= len(b.InnerNodes) {
+			return nil, fmt.Errorf("no inner node %d", i)
+		}
+		op, err := innerOp(b.InnerNodes[i], hash)
+		if err != nil {
+			return nil, err
+		}
+		if hash, err = op.Apply(hash); err != nil {
+			return nil, err
+		}
+		res.Path = append(res.Path, op)
+	}
+	return res, nil
+}
+
+// EncodeBundle encodes b in the bundle format of BundleVersion.
+func EncodeBundle(b *Bundle, c Compression) ([]byte, error) {
+	bz, err := b.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return encode(BundleVersion, bz, c)
+}
+
+func encode(version byte, bz []byte, c Compression) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return nil, err
+		}
+		bz = enc.EncodeAll(bz, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression %d", c)
+	}
+	header := append(append([]byte{}, bundleMagic...), version, byte(c))
+	return append(header, bz...), nil
+}
+
+func DecodeBundle(bz []byte) (*Bundle, error) {
+	version, bz, err := decode(bz)
+	if err != nil {
+		return nil, err
+	}
+	if version != BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, version)
+	}
+	return unmarshalBundle(bz)
+}
+
+// decode returns the version and the decompressed protobuf of an encoded
+// bundle.
+func decode(bz []byte) (byte, []byte, error) {
+	if len(bz) < len(bundleMagic)+2 || !bytes.Equal(bz[:len(bundleMagic)], bundleMagic) {
+		return 0, nil, fmt.Errorf("%w: not a bundle", ErrInvalidBundle)
+	}
+	bz = bz[len(bundleMagic):]
+	version, c := bz[0], Compression(bz[1])
+	if version != BundleVersion && version != MultiBundleVersion {
+		return 0, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, version)
+	}
+	bz = bz[2:]
+	switch c {
+	case CompressionNone:
+	case CompressionZstd:
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxBundleSize))
+		if err != nil {
+			return 0, nil, err
+		}
+		defer dec.Close()
+		if bz, err = dec.DecodeAll(bz, nil); err != nil {
+			return 0, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+	default:
+		return 0, nil, fmt.Errorf("%w: unknown compression %d", ErrInvalidBundle, c)
+	}
+	return version, bz, nil
+}
+
+func unmarshalBundle(bz []byte) (*Bundle, error) {
+	b := &Bundle{}
+	if err := b.Unmarshal(bz); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if b.Block == nil {
+		return nil, fmt.Errorf("%w: no block", ErrInvalidBundle)
+	}
+	return b, nil
+}
+
+func WriteBundle(name string, b *Bundle, c Compression) error {
+	bz, err := EncodeBundle(b, c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, bz, 0o644)
+}
+
+func ReadBundle(name string) (*Bundle, error) {
+	bz, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeBundle(bz)
+}
@@ -0,0 +1,151 @@
+package witness
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// MultiBundleVersion is the version of the bundle format written by
+// EncodeMultiBundle.
+const MultiBundleVersion = 2
+
+// MergeBundles merges the bundles of consecutive blocks into a MultiBundle
+// that stores the proof nodes and ops shared by the blocks once.
+func MergeBundles(bundles ...*Bundle) (*MultiBundle, error) {
+	if len(bundles) == 0 {
+		return nil, fmt.Errorf("no bundles to merge")
+	}
+	tables := &Bundle{}
+	builder := newBundleBuilder(tables)
+	m := &MultiBundle{}
+	for i, b := range bundles {
+		if i > 0 && b.Height != bundles[i-1].Height+1 {
+			return nil, fmt.Errorf("bundle of height %d does not follow height %d", b.Height, bundles[i-1].Height)
+		}
+		merged := *b
+		merged.Queries, merged.Leaves, merged.InnerNodes, merged.Ops = nil, nil, nil, nil
+		if i > 0 {
+			// the header and the validators are known from the bundle below
+			prev := bundles[i-1]
+			if b.LastHeader != nil && prev.Block != nil && sameProto(b.LastHeader, &prev.Block.Header) {
+				merged.LastHeader = nil
+			}
+			if len(b.Validators) > 0 && equalValidators(b.Validators, prev.Validators) {
+				merged.Validators = nil
+			}
+		}
+		for j, q := range b.Queries {
+			res, err := b.QueryResponse(j)
+			if err != nil {
+				return nil, err
+			}
+			query, err := builder.newQuery(oracletypes.Query{Path: q.Path, Data: q.Data}, res)
+			if err != nil {
+				return nil, fmt.Errorf("query %s %X: %w", q.Path, q.Data, err)
+			}
+			merged.Queries = append(merged.Queries, query)
+		}
+		m.Bundles = append(m.Bundles, merged)
+	}
+	m.Leaves, m.InnerNodes, m.Ops = tables.Leaves, tables.InnerNodes, tables.Ops
+	return m, nil
+}
+
+// Bundle returns the bundle of the i-th block with the tables of m and the
+// header and validators left out by MergeBundles.
+func (m *MultiBundle) Bundle(i int) *Bundle {
+	b := m.Bundles[i]
+	b.Leaves, b.InnerNodes, b.Ops = m.Leaves, m.InnerNodes, m.Ops
+	if i > 0 && b.LastHeader == nil && m.Bundles[i-1].Block != nil {
+		b.LastHeader = &m.Bundles[i-1].Block.Header
+	}
+	for j := i; j > 0 && len(b.Validators) == 0; j-- {
+		b.Validators = m.Bundles[j-1].Validators
+	}
+	return &b
+}
+
+// sameProto reports whether a and b marshal to the same bytes.
+func sameProto(a, b interface{ Marshal() ([]byte, error) }) bool {
+	bzA, errA := a.Marshal()
+	bzB, errB := b.Marshal()
+	return errA == nil && errB == nil && bytes.Equal(bzA, bzB)
+}
+
+func equalValidators(a, b []*tmproto.Validator) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameProto(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeMultiBundle encodes m in the bundle format of MultiBundleVersion.
+func EncodeMultiBundle(m *MultiBundle, c Compression) ([]byte, error) {
+	bz, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return encode(MultiBundleVersion, bz, c)
+}
+
+// DecodeMultiBundle decodes a multi-block bundle or a bundle of a single
+// block.
+func DecodeMultiBundle(bz []byte) (*MultiBundle, error) {
+	version, bz, err := decode(bz)
+	if err != nil {
+		return nil, err
+	}
+	if version == BundleVersion {
+		b, err := unmarshalBundle(bz)
+		if err != nil {
+			return nil, err
+		}
+		m := &MultiBundle{Leaves: b.Leaves, InnerNodes: b.InnerNodes, Ops: b.Ops}
+		b.Leaves, b.InnerNodes, b.Ops = nil, nil, nil
+		m.Bundles = []Bundle{*b}
+		return m, nil
+	}
+
+	m := &MultiBundle{}
+	if err := m.Unmarshal(bz); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if len(m.Bundles) == 0 {
+		return nil, fmt.Errorf("%w: no blocks", ErrInvalidBundle)
+	}
+	for i, b := range m.Bundles {
+		if b.Block == nil {
+			return nil, fmt.Errorf("%w: no block at height %d", ErrInvalidBundle, b.Height)
+		}
+		if i > 0 && b.Height != m.Bundles[i-1].Height+1 {
+			return nil, fmt.Errorf("%w: height %d does not follow height %d", ErrInvalidBundle, b.Height, m.Bundles[i-1].Height)
+		}
+	}
+	return m, nil
+}
+
+func WriteMultiBundle(name string, m *MultiBundle, c Compression) error {
+	bz, err := EncodeMultiBundle(m, c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, bz, 0o644)
+}
+
+func ReadMultiBundle(name string) (*MultiBundle, error) {
+	bz, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMultiBundle(bz)
+}
@@ -0,0 +1,321 @@
+package witness
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	ics23 "github.com/confio/ics23/go"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+var (
+	leafPrefix   = []byte("l/")
+	innerPrefix  = []byte("i/")
+	pathPrefix   = []byte("p/")
+	parentPrefix = []byte("a/")
+	keyPrefix    = []byte("k/")
+)
+
+// NodeStore stores the nodes of verified IAVL proofs by node hash. The trees
+// of neighboring heights share most of their nodes, so the store grows with
+// the changes of the state rather than with the number of heights, and a node
+// or key query of a later height can be answered from nodes stored for an
+// earlier one.
+//
+// The parent of a node and the leaf of a key are the last ones stored. They
+// answer a query of a height only if they lead up to the root of the height,
+// which they do as long as the node or key did not change.
+type NodeStore struct {
+	db dbm.DB
+}
+
+func NewNodeStore(db dbm.DB) *NodeStore {
+	return &NodeStore{db: db}
+}
+
+func (s *NodeStore) Close() error {
+	return s.db.Close()
+}
+
+// Index returns an empty index of the trees of one height.
+func (s *NodeStore) Index() *NodeIndex {
+	return &NodeIndex{
+		store: s,
+		roots: map[string]storeRoot{},
+	}
+}
+
+func (s *NodeStore) leaf(hash []byte) (*Leaf, error) {
+	bz, err := s.db.Get(append(append([]byte{}, leafPrefix...), hash...))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	leaf := &Leaf{}
+	return leaf, leaf.Unmarshal(bz)
+}
+
+func (s *NodeStore) inner(hash []byte) (*InnerNode, error) {
+	bz, err := s.db.Get(append(append([]byte{}, innerPrefix...), hash...))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	node := &InnerNode{}
+	return node, node.Unmarshal(bz)
+}
+
+func (s *NodeStore) parent(hash []byte) ([]byte, error) {
+	return s.db.Get(append(append([]byte{}, parentPrefix...), hash...))
+}
+
+// keyLeaf returns the hash of the leaf of key in the tree of store.
+func (s *NodeStore) keyLeaf(store string, key []byte) ([]byte, error) {
+	return s.db.Get(storeKey(store, key))
+}
+
+func storeKey(store string, key []byte) []byte {
+	return append(append(append([]byte{}, keyPrefix...), store+"/"...), key...)
+}
+
+func (s *NodeStore) path(hash []byte) (*NodePath, error) {
+	bz, err := s.db.Get(append(append([]byte{}, pathPrefix...), hash...))
+	if err != nil || bz == nil {
+		return nil, err
+	}
+	path := &NodePath{}
+	return path, path.Unmarshal(bz)
+}
+
+// NodeIndex answers node and key queries of one height from a NodeStore. It
+// learns the roots of the stores at its height from the verified responses
+// added to it.
+type NodeIndex struct {
+	store *NodeStore
+
+	mtx   sync.Mutex
+	roots map[string]storeRoot
+}
+
+// storeRoot is the root of the tree of a store and the op proving it in the
+// app hash.
+type storeRoot struct {
+	hash   []byte
+	height int64
+	op     tmcrypto.ProofOp
+}
+
+// Add stores the nodes of res, the verified response to q, and indexes them.
+func (x *NodeIndex) Add(q oracletypes.Query, res abci.ResponseQuery) error {
+	storeName, subpath, ok := storeQuery(q.Path)
+	if !ok || res.ProofOps == nil || len(res.ProofOps.Ops) < 2 {
+		return nil
+	}
+	ops := res.ProofOps.Ops
+	rootOp := ops[len(ops)-1]
+	if rootOp.Type != storetypes.ProofOpSimpleMerkleCommitment {
+		return nil
+	}
+	rootProof := &ics23.CommitmentProof{}
+	if err := rootProof.Unmarshal(rootOp.Data); err != nil {
+		return err
+	}
+	if rootProof.GetExist() == nil {
+		return nil
+	}
+
+	x.mtx.Lock()
+	x.roots[storeName] = storeRoot{hash: rootProof.GetExist().Value, height: res.Height, op: rootOp}
+	x.mtx.Unlock()
+
+	batch := x.store.db.NewBatch()
+	defer batch.Close()
+	for i, op := range ops[:len(ops)-1] {
+		if op.Type != storetypes.ProofOpIAVLCommitment {
+			continue
+		}
+		proof := &ics23.CommitmentProof{}
+		if err := proof.Unmarshal(op.Data); err != nil {
+			return err
+		}
+		exists := []*ics23.ExistenceProof{proof.GetExist()}
+		if nonexist := proof.GetNonexist(); nonexist != nil {
+			exists = []*ics23.ExistenceProof{nonexist.Left, nonexist.Right}
+		}
+		for _, p := range exists {
+			if p == nil {
+				continue
+			}
+			hashes, err := x.addExistenceProof(batch, p)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				continue
+			}
+			// a node query is answered with the proof of the key of the node
+			if subpath == "node" {
+				if err = addNodePath(batch, q.Data, hashes); err != nil {
+					return err
+				}
+			}
+			if subpath == "key" && proof.GetExist() != nil {
+				if err = batch.Set(storeKey(storeName, p.Key), hashes[0]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return batch.Write()
+}
+
+// addExistenceProof stores the nodes of p and returns their hashes from the
+// leaf up to the root.
+func (x *NodeIndex) addExistenceProof(batch dbm.Batch, p *ics23.ExistenceProof) ([][]byte, error) {
+	spec := ics23.IavlSpec.LeafSpec
+	if p.Leaf == nil || p.Leaf.Hash != spec.Hash || p.Leaf.PrehashKey != spec.PrehashKey ||
+		p.Leaf.PrehashValue != spec.PrehashValue || p.Leaf.Length != spec.Length {
+		return nil, fmt.Errorf("unsupported leaf op %v", p.Leaf)
+	}
+	hash, err := p.Leaf.Apply(p.Key, p.Value)
+	if err != nil {
+		return nil, err
+	}
+	leaf := Leaf{Key: p.Key, Value: p.Value, Prefix: p.Leaf.Prefix}
+	bz, err := leaf.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err = batch.Set(append(append([]byte{}, leafPrefix...), hash...), bz); err != nil {
+		return nil, err
+	}
+	hashes := [][]byte{hash}
+
+	for _, op := range p.Path {
+		node, err := innerNode(op, hash)
+		if err != nil {
+			return nil, err
+		}
+		if hash, err = op.Apply(hash); err != nil {
+			return nil, err
+		}
+		if bz, err = node.Marshal(); err != nil {
+			return nil, err
+		}
+		if err = batch.Set(append(append([]byte{}, innerPrefix...), hash...), bz); err != nil {
+			return nil, err
+		}
+		for _, child := range [][]byte{node.Left, node.Right} {
+			if err = batch.Set(append(append([]byte{}, parentPrefix...), child...), hash); err != nil {
+				return nil, err
+			}
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// addNodePath stores the path from the leaf of hashes up to node.
+func addNodePath(batch dbm.Batch, node []byte, hashes [][]byte) error {
+	for i, hash := range hashes {
+		if !bytes.Equal(hash, node) {
+			continue
+		}
+		path := NodePath{Leaf: hashes[0], Path: hashes[1 : i+1]}
+		bz, err := path.Marshal()
+		if err != nil {
+			return err
+		}
+		return batch.Set(append(append([]byte{}, pathPrefix...), node...), bz)
+	}
+	return nil
+}
+
+// Response returns the response to the node or key query q built from the
+// stored nodes, if they lead up to the root of the store at the height of
+// the index. It returns false if q is neither or the index cannot answer it.
+func (x *NodeIndex) Response(q oracletypes.Query) (abci.ResponseQuery, bool, error) {
+	storeName, subpath, ok := storeQuery(q.Path)
+	if !ok || subpath != "node" && subpath != "key" {
+		return abci.ResponseQuery{}, false, nil
+	}
+	x.mtx.Lock()
+	root, ok := x.roots[storeName]
+	x.mtx.Unlock()
+	if !ok {
+		return abci.ResponseQuery{}, false, nil
+	}
+	path := &NodePath{}
+	if subpath == "node" {
+		stored, err := x.store.path(q.Data)
+		if err != nil || stored == nil {
+			return abci.ResponseQuery{}, false, err
+		}
+		path = stored
+	} else {
+		leaf, err := x.store.keyLeaf(storeName, q.Data)
+		if err != nil || leaf == nil {
+			return abci.ResponseQuery{}, false, err
+		}
+		path.Leaf = leaf
+	}
+	leaf, err := x.store.leaf(path.Leaf)
+	if err != nil || leaf == nil {
+		return abci.ResponseQuery{}, false, err
+	}
+
+	exist := &ics23.ExistenceProof{Key: leaf.Key, Value: leaf.Value, Leaf: iavlLeafOp(leaf.Prefix)}
+	hash, err := exist.Leaf.Apply(leaf.Key, leaf.Value)
+	if err != nil {
+		return abci.ResponseQuery{}, false, err
+	}
+	for i := 0; !bytes.Equal(hash, root.hash); i++ {
+		var parent []byte
+		if i < len(path.Path) {
+			parent = path.Path[i]
+		} else if parent, err = x.store.parent(hash); err != nil || parent == nil {
+			// the stored nodes lead to the root of another height
+			return abci.ResponseQuery{}, false, err
+		}
+		node, err := x.store.inner(parent)
+		if err != nil || node == nil {
+			return abci.ResponseQuery{}, false, err
+		}
+		op, err := innerOp(*node, hash)
+		if err != nil {
+			return abci.ResponseQuery{}, false, err
+		}
+		if hash, err = op.Apply(hash); err != nil {
+			return abci.ResponseQuery{}, false, err
+		}
+		exist.Path = append(exist.Path, op)
+	}
+
+	bz, err := (&ics23.CommitmentProof{Proof: &ics23.CommitmentProof_Exist{Exist: exist}}).Marshal()
+	if err != nil {
+		return abci.ResponseQuery{}, false, err
+	}
+	return abci.ResponseQuery{
+		Key:    leaf.Key,
+		Value:  append([]byte{}, leaf.Value...),
+		Height: root.height,
+		ProofOps: &tmcrypto.ProofOps{Ops: []tmcrypto.ProofOp{
+			{Type: storetypes.ProofOpIAVLCommitment, Key: leaf.Key, Data: bz},
+			root.op,
+		}},
+	}, true, nil
+}
+
+// storeQuery splits the path of a store query, e.g. "store/bank/node".
+func storeQuery(path string) (string, string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "store/"), "/")
+	if !strings.HasPrefix(path, "store/") || len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,96 @@
+package witness
+
+import (
+	"bytes"
+	"fmt"
+
+	ics23 "github.com/confio/ics23/go"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// PruneQueries returns a copy of b with only the queries whose QueryKey is
+// in used and, of their proofs, the nodes and ops the stateless tree visits:
+// the store proof of a root hash query, from which the tree reads the root,
+// and the path of a node query from the leaf up to the node, from which the
+// tree builds the node. The path above the node and the absence proof of
+// the root hash key are left out, so that even a bundle recorded by a single
+// execution shrinks.
+//
+// The pruned proofs are no longer complete on their own. The tree requests
+// the root of a store first and then the children of the nodes it holds, so
+// oracletypes.ProvenNodes verifies each of them against the nodes proven
+// before, and a pruned bundle is verified like any other.
+func PruneQueries(b *Bundle, used map[string]bool) (*Bundle, error) {
+	pruned := *b
+	pruned.Queries, pruned.Leaves, pruned.InnerNodes, pruned.Ops = nil, nil, nil, nil
+	builder := newBundleBuilder(&pruned)
+	for i, q := range b.Queries {
+		if !used[QueryKey(q.Path, q.Data)] {
+			continue
+		}
+		res, err := b.QueryResponse(i)
+		if err != nil {
+			return nil, err
+		}
+		oq := oracletypes.Query{Path: q.Path, Data: q.Data}
+		if res, err = treeProof(oq, res); err != nil {
+			return nil, fmt.Errorf("query %s %X: %w", q.Path, q.Data, err)
+		}
+		query, err := builder.newQuery(oq, res)
+		if err != nil {
+			return nil, fmt.Errorf("query %s %X: %w", q.Path, q.Data, err)
+		}
+		pruned.Queries = append(pruned.Queries, query)
+	}
+	return &pruned, nil
+}
+
+// treeProof cuts the proof of res down to what the stateless tree visits.
+// Proofs cut before are returned as they are.
+func treeProof(q oracletypes.Query, res abci.ResponseQuery) (abci.ResponseQuery, error) {
+	_, subpath, ok := storeQuery(q.Path)
+	if !ok || res.ProofOps == nil || len(res.ProofOps.Ops) < 2 {
+		return res, nil
+	}
+	ops := res.ProofOps.Ops
+	switch {
+	case subpath == "key" && bytes.Equal(q.Data, oracletypes.RootHashKey):
+		if ops[len(ops)-1].Type != storetypes.ProofOpSimpleMerkleCommitment {
+			return res, fmt.Errorf("root hash query has no store proof")
+		}
+		res.ProofOps = &tmcrypto.ProofOps{Ops: ops[len(ops)-1:]}
+	case subpath == "node":
+		proof := &ics23.CommitmentProof{}
+		if err := proof.Unmarshal(ops[0].Data); err != nil {
+			return res, err
+		}
+		exist := proof.GetExist()
+		if exist == nil || exist.Leaf == nil {
+			return res, fmt.Errorf("node query has no existence proof")
+		}
+		hash, err := exist.Leaf.Apply(exist.Key, exist.Value)
+		if err != nil {
+			return res, err
+		}
+		n := 0
+		for ; n < len(exist.Path) && !bytes.Equal(hash, q.Data); n++ {
+			if hash, err = exist.Path[n].Apply(hash); err != nil {
+				return res, err
+			}
+		}
+		if !bytes.Equal(hash, q.Data) {
+			return res, fmt.Errorf("proof does not pass through node %X", q.Data)
+		}
+		exist.Path = exist.Path[:n]
+		op := ops[0]
+		if op.Data, err = proof.Marshal(); err != nil {
+			return res, err
+		}
+		res.ProofOps = &tmcrypto.ProofOps{Ops: []tmcrypto.ProofOp{op}}
+	}
+	return res, nil
+}
@@ -0,0 +1,147 @@
+package witness
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// Access is an oracle request and its response.
+type Access struct {
+	// Phase is the phase of the execution that made the request, such as
+	// "deliver_tx", or empty if unknown. Requests made while loading the
+	// stores before InitChain have the phase "stateless_app".
+	Phase string `json:"phase,omitempty"`
+	// TxIndex is the index of the tx of a "deliver_tx" request.
+	TxIndex int `json:"tx_index,omitempty"`
+	// Request is the oracle key.
+	Request  string `json:"request"`
+	Response []byte `json:"response"`
+}
+
+// Recording is the witness of the execution of a block, i.e. the oracle
+// requests of the execution in order.
+type Recording struct {
+	Height   int64    `json:"height"`
+	Accesses []Access `json:"accesses"`
+
+	mtx sync.Mutex
+}
+
+// Add appends an access. It is safe for concurrent use.
+func (r *Recording) Add(a Access) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.Accesses = append(r.Accesses, a)
+}
+
+// Reset empties r for the execution of the block of height.
+func (r *Recording) Reset(height int64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.Height, r.Accesses = height, nil
+}
+
+// WriteFile writes r as JSON.
+func (r *Recording) WriteFile(name string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	bz, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, bz, 0o644)
+}
+
+// ReadRecording reads a recording written by WriteFile.
+func ReadRecording(name string) (*Recording, error) {
+	bz, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recording{}
+	if err = json.Unmarshal(bz, r); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", name, err)
+	}
+	return r, nil
+}
+
+// Read reads a recording file or a CacheHttp cache directory.
+func Read(name string) (*Recording, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadCacheDir(name)
+	}
+	return ReadRecording(name)
+}
+
+// ReadCacheDir reads the responses cached by CacheHttp in dir as a
+// recording without phases. ABCI queries, which are made at the height
+// below the block, are converted to requests of the block height, and the
+// other files keep their name as request. Accesses are ordered by request.
+func ReadCacheDir(dir string) (*Recording, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recording{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		response, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		request, height, err := cacheFileRequest(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache file %s: %w", name, err)
+		}
+		if strings.HasPrefix(name, "abci_query?") && r.Height == 0 {
+			r.Height = height
+		}
+		r.Accesses = append(r.Accesses, Access{Request: request, Response: response})
+	}
+	sort.Slice(r.Accesses, func(i, j int) bool {
+		return r.Accesses[i].Request < r.Accesses[j].Request
+	})
+	return r, nil
+}
+
+// cacheFileRequest returns the oracle request of a cache file name without
+// extension, and the height of the block it is about for ABCI queries.
+func cacheFileRequest(name string) (string, int64, error) {
+	if !strings.HasPrefix(name, "abci_query?") {
+		return name, 0, nil
+	}
+	m, err := url.ParseQuery(strings.TrimPrefix(name, "abci_query?"))
+	if err != nil {
+		return "", 0, err
+	}
+	queryHeight, err := strconv.ParseInt(m.Get("height"), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	data, err := hex.DecodeString(m.Get("data"))
+	if err != nil {
+		return "", 0, err
+	}
+	key, err := oracletypes.EncodeRequest(oracletypes.NewABCIQueryRequest(queryHeight+1, m.Get("path"), data))
+	if err != nil {
+		return "", 0, err
+	}
+	return string(key), queryHeight + 1, nil
+}
@@ -0,0 +1,50 @@
+package witness
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+func TestReadCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	// the file names of CacheHttp, ABCI queries are made at the height below
+	query := fmt.Sprintf("abci_query?path=%s&data=%x&height=%d&prove=true.json", url.QueryEscape("store/bank/key"), "a", 4)
+	write(query, `{"response":{}}`)
+	write("block?height=5.json", `{"block":{}}`)
+	write("notes.txt", "not a cache file")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub.json"), 0o755))
+
+	r, err := ReadCacheDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), r.Height)
+	queryReq := string(oracletypes.MustEncodeRequest(oracletypes.NewABCIQueryRequest(5, "store/bank/key", []byte("a"))))
+	// accesses are ordered by request, other files keep their name
+	require.Equal(t, []Access{
+		{Request: queryReq, Response: []byte(`{"response":{}}`)},
+		{Request: "block?height=5", Response: []byte(`{"block":{}}`)},
+	}, r.Accesses)
+
+	// Read tells cache directories from recording files
+	read, err := Read(dir)
+	require.NoError(t, err)
+	require.Equal(t, r.Accesses, read.Accesses)
+	name := filepath.Join(t.TempDir(), "witness.json")
+	require.NoError(t, r.WriteFile(name))
+	read, err = Read(name)
+	require.NoError(t, err)
+	require.Equal(t, r.Accesses, read.Accesses)
+
+	write("abci_query?path=store%2Fbank%2Fkey&data=zz&height=4&prove=true.json", "{}")
+	_, err = ReadCacheDir(dir)
+	require.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package witness
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	ics23 "github.com/confio/ics23/go"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// Usage sums up a part of a witness. Nodes and depths are those of the
+// IAVL proofs of ABCI queries.
+type Usage struct {
+	Requests int `json:"requests"`
+	Bytes    int `json:"bytes"`
+	// Nodes is the number of distinct nodes, as the proofs of neighboring
+	// keys share the nodes of their common ancestors.
+	Nodes int `json:"nodes"`
+	// Proofs is the number of IAVL existence proofs, a non-existence proof
+	// having up to two.
+	Proofs     int `json:"proofs"`
+	MaxDepth   int `json:"max_depth"`
+	TotalDepth int `json:"total_depth"`
+}
+
+// AvgDepth returns the average depth of the proofs.
+func (u *Usage) AvgDepth() float64 {
+	if u.Proofs == 0 {
+		return 0
+	}
+	return float64(u.TotalDepth) / float64(u.Proofs)
+}
+
+// add adds o to u but for its nodes, which are counted by nodeCounter.
+func (u *Usage) add(o Usage) {
+	u.Requests += o.Requests
+	u.Bytes += o.Bytes
+	u.Proofs += o.Proofs
+	u.TotalDepth += o.TotalDepth
+	if o.MaxDepth > u.MaxDepth {
+		u.MaxDepth = o.MaxDepth
+	}
+}
+
+// Stats breaks down a witness by request kind, by store of the ABCI
+// queries and by phase of the execution.
+type Stats struct {
+	Height int64             `json:"height"`
+	Total  Usage             `json:"total"`
+	Kinds  map[string]*Usage `json:"kinds"`
+	Stores map[string]*Usage `json:"stores"`
+	// Phases are keyed by phase, with the tx index for deliver_tx, e.g.
+	// "deliver_tx/3". Requests of unknown phase are keyed "unknown".
+	Phases map[string]*Usage `json:"phases"`
+}
+
+// Analyze returns the statistics of the witness r.
+func Analyze(r *Recording) (*Stats, error) {
+	s := &Stats{
+		Height: r.Height,
+		Kinds:  map[string]*Usage{},
+		Stores: map[string]*Usage{},
+		Phases: map[string]*Usage{},
+	}
+	nodes := nodeCounter{}
+	for _, a := range r.Accesses {
+		kind := a.Request
+		if i := strings.Index(kind, "?"); i >= 0 {
+			kind = kind[:i]
+		}
+		stores, err := analyzeAccess(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access %s: %w", a.Request, err)
+		}
+
+		u := Usage{Requests: 1, Bytes: len(a.Response)}
+		hashes := [][]byte{}
+		for store, su := range stores {
+			u.Proofs += su.Proofs
+			u.TotalDepth += su.TotalDepth
+			if su.MaxDepth > u.MaxDepth {
+				u.MaxDepth = su.MaxDepth
+			}
+			hashes = append(hashes, su.hashes...)
+			nodes.add(usage(s.Stores, store), su.Usage, su.hashes)
+		}
+		nodes.add(&s.Total, u, hashes)
+		nodes.add(usage(s.Kinds, kind), u, hashes)
+		nodes.add(usage(s.Phases, phaseKey(a)), u, hashes)
+	}
+	return s, nil
+}
+
+func usage(m map[string]*Usage, key string) *Usage {
+	if m[key] == nil {
+		m[key] = &Usage{}
+	}
+	return m[key]
+}
+
+// nodeCounter counts the distinct nodes of every Usage by node hash.
+type nodeCounter map[*Usage]map[string]bool
+
+// add adds o with the nodes of hashes to u.
+func (c nodeCounter) add(u *Usage, o Usage, hashes [][]byte) {
+	u.add(o)
+	if c[u] == nil {
+		c[u] = map[string]bool{}
+	}
+	for _, hash := range hashes {
+		if !c[u][string(hash)] {
+			c[u][string(hash)] = true
+			u.Nodes++
+		}
+	}
+}
+
+// proofUsage is the usage of the proofs of a response with the hashes of
+// their nodes.
+type proofUsage struct {
+	Usage
+	hashes [][]byte
+}
+
+func phaseKey(a Access) string {
+	switch a.Phase {
+	case "":
+		return "unknown"
+	case "deliver_tx":
+		return fmt.Sprintf("%s/%d", a.Phase, a.TxIndex)
+	}
+	return a.Phase
+}
+
+// analyzeAccess returns the usage of the stores queried by a. Requests
+// other than ABCI queries query no store.
+func analyzeAccess(a Access) (map[string]proofUsage, error) {
+	req, err := oracletypes.DecodeRequest([]byte(a.Request))
+	if err != nil || (req.Kind != oracletypes.KindABCIQuery && req.Kind != oracletypes.KindABCIQueryBatch) {
+		return nil, nil
+	}
+	responses := [][]byte{a.Response}
+	if req.Kind == oracletypes.KindABCIQueryBatch {
+		if responses, err = oracletypes.DecodeBatchResponse(a.Response); err != nil {
+			return nil, err
+		}
+		if len(responses) != len(req.Queries) {
+			return nil, fmt.Errorf("%d responses for %d queries", len(responses), len(req.Queries))
+		}
+	}
+
+	stores := map[string]proofUsage{}
+	for i, q := range req.Queries {
+		// paths are store/<name>/<subpath>
+		path := strings.Split(q.Path, "/")
+		if len(path) < 2 || path[0] != "store" {
+			continue
+		}
+		res := ctypes.ResultABCIQuery{}
+		if err = oracletypes.DecodeResponse(responses[i], &res); err != nil {
+			return nil, err
+		}
+		u, err := analyzeProofs(res)
+		if err != nil {
+			return nil, err
+		}
+		u.Requests, u.Bytes = 1, len(responses[i])
+		su := stores[path[1]]
+		su.add(u.Usage)
+		su.hashes = append(su.hashes, u.hashes...)
+		stores[path[1]] = su
+	}
+	return stores, nil
+}
+
+func analyzeProofs(res ctypes.ResultABCIQuery) (proofUsage, error) {
+	u := proofUsage{}
+	if res.Response.ProofOps == nil {
+		return u, nil
+	}
+	for _, op := range res.Response.ProofOps.Ops {
+		if op.Type != storetypes.ProofOpIAVLCommitment {
+			continue
+		}
+		proof := &ics23.CommitmentProof{}
+		if err := proof.Unmarshal(op.Data); err != nil {
+			return u, err
+		}
+		proofs := []*ics23.ExistenceProof{}
+		if exist := proof.GetExist(); exist != nil {
+			proofs = append(proofs, exist)
+		}
+		if nonexist := proof.GetNonexist(); nonexist != nil {
+			for _, p := range []*ics23.ExistenceProof{nonexist.Left, nonexist.Right} {
+				if p != nil {
+					proofs = append(proofs, p)
+				}
+			}
+		}
+		for _, p := range proofs {
+			if p.Leaf == nil {
+				return u, fmt.Errorf("existence proof of %X has no leaf", p.Key)
+			}
+			hash, err := p.Leaf.Apply(p.Key, p.Value)
+			if err != nil {
+				return u, err
+			}
+			u.hashes = append(u.hashes, hash)
+			for _, inner := range p.Path {
+				if hash, err = inner.Apply(hash); err != nil {
+					return u, err
+				}
+				u.hashes = append(u.hashes, hash)
+			}
+			depth := len(p.Path)
+			u.Proofs++
+			u.TotalDepth += depth
+			if depth > u.MaxDepth {
+				u.MaxDepth = depth
+			}
+		}
+	}
+	return u, nil
+}
+
+// WriteTable writes s as tables by kind, store and phase, sorted by bytes.
+func (s *Stats) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "height %d: %d requests, %d bytes, %d nodes\t\n", s.Height, s.Total.Requests, s.Total.Bytes, s.Total.Nodes)
+	for _, section := range []struct {
+		name  string
+		usage map[string]*Usage
+	}{{"KIND", s.Kinds}, {"STORE", s.Stores}, {"PHASE", s.Phases}} {
+		fmt.Fprintf(tw, "\t\n%s\tREQUESTS\tBYTES\tBYTES %%\tNODES\tMAX DEPTH\tAVG DEPTH\t\n", section.name)
+		keys := []string{}
+		for key := range section.usage {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			bi, bj := section.usage[keys[i]].Bytes, section.usage[keys[j]].Bytes
+			return bi > bj || (bi == bj && keys[i] < keys[j])
+		})
+		for _, key := range keys {
+			u := section.usage[key]
+			share := 0.0
+			if s.Total.Bytes > 0 {
+				share = 100 * float64(u.Bytes) / float64(s.Total.Bytes)
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%d\t%d\t%.1f\t\n", key, u.Requests, u.Bytes, share, u.Nodes, u.MaxDepth, u.AvgDepth())
+		}
+	}
+	return tw.Flush()
+}
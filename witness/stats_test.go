@@ -0,0 +1,105 @@
+package witness
+
+import (
+	"bytes"
+	"testing"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/iavl"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	dbm "github.com/tendermint/tm-db"
+
+	oracletypes "github.com/ulbqb/cosmos-stateless-poc/oracle/types"
+)
+
+// queryAccess returns the access of a key query of store at height answered
+// with the proof of key in tree.
+func queryAccess(t *testing.T, tree *iavl.MutableTree, height int64, store string, key []byte) Access {
+	value, err := tree.Get(key)
+	require.NoError(t, err)
+	proof, err := tree.GetMembershipProof(key)
+	require.NoError(t, err)
+	res := ctypes.ResultABCIQuery{Response: abci.ResponseQuery{
+		Key:      key,
+		Value:    value,
+		Height:   height - 1,
+		ProofOps: &tmcrypto.ProofOps{Ops: []tmcrypto.ProofOp{storetypes.NewIavlCommitmentOp(key, proof).ProofOp()}},
+	}}
+	bz, err := oracletypes.EncodeResponse(res)
+	require.NoError(t, err)
+	req := oracletypes.MustEncodeRequest(oracletypes.NewABCIQueryRequest(height, "store/"+store+"/key", key))
+	return Access{Request: string(req), Response: bz}
+}
+
+func newTestTree(t *testing.T, keys ...string) *iavl.MutableTree {
+	tree, err := iavl.NewMutableTree(dbm.NewMemDB(), 0, false)
+	require.NoError(t, err)
+	for _, key := range keys {
+		_, err = tree.Set([]byte(key), []byte(key))
+		require.NoError(t, err)
+	}
+	_, _, err = tree.SaveVersion()
+	require.NoError(t, err)
+	return tree
+}
+
+func TestAnalyze(t *testing.T) {
+	const height = 5
+	// a balanced tree of depth 2, the proofs of a and b share two nodes
+	tree := newTestTree(t, "a", "b", "c", "d")
+	block := Access{Phase: "stateless_app", Request: string(oracletypes.MustEncodeRequest(oracletypes.NewBlockRequest(height))), Response: []byte("{}")}
+	a := queryAccess(t, tree, height, "bank", []byte("a"))
+	a.Phase, a.TxIndex = "deliver_tx", 0
+	b := queryAccess(t, tree, height, "bank", []byte("b"))
+	b.Phase, b.TxIndex = "deliver_tx", 1
+	// the same query again adds no nodes
+	again := queryAccess(t, tree, height, "bank", []byte("a"))
+	again.Phase = "commit"
+
+	stats, err := Analyze(&Recording{Height: height, Accesses: []Access{block, a, b, again}})
+	require.NoError(t, err)
+	require.Equal(t, int64(height), stats.Height)
+	total := len(block.Response) + len(a.Response) + len(b.Response) + len(again.Response)
+	require.Equal(t, Usage{Requests: 4, Bytes: total, Nodes: 4, Proofs: 3, MaxDepth: 2, TotalDepth: 6}, stats.Total)
+	require.Equal(t, Usage{Requests: 1, Bytes: len(block.Response)}, *stats.Kinds["block"])
+	require.Equal(t, 4, stats.Kinds["abci_query"].Nodes)
+	require.Equal(t, Usage{Requests: 3, Bytes: total - len(block.Response), Nodes: 4, Proofs: 3, MaxDepth: 2, TotalDepth: 6}, *stats.Stores["bank"])
+	require.Len(t, stats.Stores, 1)
+	for _, phase := range []string{"deliver_tx/0", "deliver_tx/1", "commit"} {
+		require.Equal(t, 3, stats.Phases[phase].Nodes, phase)
+		require.Equal(t, 1, stats.Phases[phase].Proofs, phase)
+	}
+	require.Equal(t, 2.0, stats.Total.AvgDepth())
+
+	// a response that does not decode is reported
+	invalid := a
+	invalid.Response = []byte("invalid")
+	_, err = Analyze(&Recording{Accesses: []Access{invalid}})
+	require.Error(t, err)
+}
+
+func TestWriteTable(t *testing.T) {
+	tree := newTestTree(t, "a", "b", "c", "d")
+	small := queryAccess(t, tree, 5, "acc", []byte("a"))
+	small.Phase = "begin_block"
+	large := queryAccess(t, tree, 5, "bank", []byte("b"))
+	large.Phase, large.TxIndex = "deliver_tx", 2
+	large.Response = append(large.Response, bytes.Repeat([]byte(" "), 1000)...)
+	stats, err := Analyze(&Recording{Height: 5, Accesses: []Access{small, large}})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, stats.WriteTable(buf))
+	out := buf.String()
+	require.Contains(t, out, "height 5: 2 requests,")
+	require.Contains(t, out, " 4 nodes")
+	for _, header := range []string{"KIND", "STORE", "PHASE"} {
+		require.Contains(t, out, header+"  REQUESTS  BYTES  BYTES %  NODES  MAX DEPTH  AVG DEPTH")
+	}
+	// rows are sorted by bytes
+	require.Less(t, bytes.Index(buf.Bytes(), []byte("bank")), bytes.Index(buf.Bytes(), []byte("acc")))
+	require.Less(t, bytes.Index(buf.Bytes(), []byte("deliver_tx/2")), bytes.Index(buf.Bytes(), []byte("begin_block")))
+}